@@ -0,0 +1,146 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+type AdminClient struct {
+	xgrpcServer *xgrpc_server.XgrpcServer
+}
+
+type restResult struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type connection struct {
+	MetaInfo model.ConnectionInfo `json:"metaInfo"`
+}
+
+func NewAdminClient(xgrpcServer *xgrpc_server.XgrpcServer) *AdminClient {
+	return &AdminClient{xgrpcServer: xgrpcServer}
+}
+
+func (c *AdminClient) GetServerList() []constant.ServerConfig {
+	return c.xgrpcServer.GetServerList()
+}
+
+func (c *AdminClient) ListConnections(ctx context.Context, server constant.ServerConfig) ([]model.ConnectionInfo, error) {
+	body, err := c.call(ctx, server, http.MethodGet, constant.CONNECTION_LIST_PATH, nil)
+	if err != nil {
+		return nil, err
+	}
+	var connections map[string]connection
+	if err = json.Unmarshal(body, &connections); err != nil {
+		return nil, errors.Wrap(err, "decode connections failed")
+	}
+	result := make([]model.ConnectionInfo, 0, len(connections))
+	for id, conn := range connections {
+		if conn.MetaInfo.ConnectionId == "" {
+			conn.MetaInfo.ConnectionId = id
+		}
+		result = append(result, conn.MetaInfo)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ConnectionId < result[j].ConnectionId
+	})
+	return result, nil
+}
+
+func (c *AdminClient) ResetConnection(ctx context.Context, server constant.ServerConfig, connectionId string, redirectAddress string) error {
+	if connectionId == "" {
+		return errors.New("connectionId can not be empty")
+	}
+	params := map[string]string{"connectionId": connectionId}
+	if redirectAddress != "" {
+		params["redirectAddress"] = redirectAddress
+	}
+	_, err := c.call(ctx, server, http.MethodGet, constant.CONNECTION_RESET_PATH, params)
+	return err
+}
+
+func (c *AdminClient) ListClusterNodes(ctx context.Context, server constant.ServerConfig) ([]model.ClusterNode, error) {
+	body, err := c.call(ctx, server, http.MethodGet, constant.CLUSTER_NODES_PATH, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result restResult
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, errors.Wrap(err, "decode cluster nodes failed")
+	}
+	if result.Code != constant.RESPONSE_CODE_SUCCESS {
+		return nil, errors.Errorf("list cluster nodes failed, code:%d, message:%s", result.Code, result.Message)
+	}
+	var nodes []model.ClusterNode
+	if err = json.Unmarshal(result.Data, &nodes); err != nil {
+		return nil, errors.Wrap(err, "decode cluster nodes failed")
+	}
+	return nodes, nil
+}
+
+func (c *AdminClient) Readiness(ctx context.Context, server constant.ServerConfig) (model.HealthStatus, error) {
+	return c.checkHealth(ctx, server, constant.HEALTH_READINESS_PATH)
+}
+
+func (c *AdminClient) Liveness(ctx context.Context, server constant.ServerConfig) (model.HealthStatus, error) {
+	return c.checkHealth(ctx, server, constant.HEALTH_LIVENESS_PATH)
+}
+
+// checkHealth takes the response with a status code other than 2xx as unhealthy,
+// and only the failure of reaching the server is returned as the error.
+func (c *AdminClient) checkHealth(ctx context.Context, server constant.ServerConfig, api string) (model.HealthStatus, error) {
+	body, err := c.call(ctx, server, http.MethodGet, api, nil)
+	if httpErr, ok := http_agent.IsHttpError(err); ok {
+		message := strings.TrimSpace(string(httpErr.Body))
+		if message == "" {
+			message = strconv.Itoa(httpErr.StatusCode)
+		}
+		return model.HealthStatus{Healthy: false, Message: message}, nil
+	}
+	if err != nil {
+		return model.HealthStatus{}, err
+	}
+	return model.HealthStatus{Healthy: true, Message: string(body)}, nil
+}
+
+// call uses TimeoutMs of the client config as the timeout if the ctx has no deadline.
+func (c *AdminClient) call(ctx context.Context, server constant.ServerConfig, method string, api string, params map[string]string) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		if timeoutMs := c.xgrpcServer.GetClientConfig().TimeoutMs; timeoutMs > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+			defer cancel()
+		}
+	}
+	return c.xgrpcServer.CallServerApi(ctx, server, method, api, params)
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin_client
+
+import (
+	"context"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+// IAdminClient queries and manages the state of the xgrpc servers by the http management api.
+type IAdminClient interface {
+	// GetServerList returns the servers known by the client, which are the targets of the other operations.
+	GetServerList() []constant.ServerConfig
+	// ListConnections returns the client connections held by the server.
+	ListConnections(ctx context.Context, server constant.ServerConfig) ([]model.ConnectionInfo, error)
+	// ResetConnection asks the server to reset the connection, the client reconnects to redirectAddress
+	// if it's not empty, otherwise to any server.
+	ResetConnection(ctx context.Context, server constant.ServerConfig, connectionId string, redirectAddress string) error
+	// ListClusterNodes returns the members of the cluster that the server belongs to.
+	ListClusterNodes(ctx context.Context, server constant.ServerConfig) ([]model.ClusterNode, error)
+	// Readiness returns whether the server is ready to serve the requests.
+	Readiness(ctx context.Context, server constant.ServerConfig) (model.HealthStatus, error)
+	// Liveness returns whether the server is alive.
+	Liveness(ctx context.Context, server constant.ServerConfig) (model.HealthStatus, error)
+}
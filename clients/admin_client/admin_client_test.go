@@ -0,0 +1,122 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin_client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+func newTestAdminClient(t *testing.T, server *fakeAdminServer, clientConfig constant.ClientConfig) *AdminClient {
+	xgrpcServer, err := xgrpc_server.NewXgrpcServer([]constant.ServerConfig{server.ServerConfig()}, clientConfig,
+		&http_agent.HttpAgent{}, 3000, "")
+	assert.Nil(t, err)
+	return NewAdminClient(xgrpcServer)
+}
+
+func TestListAndResetConnections(t *testing.T) {
+	server := newfakeAdminServer()
+	defer server.Close()
+	server.PutConnection(model.ConnectionInfo{ConnectionId: "2", ClientIp: "127.0.0.2", AppName: "app"})
+	server.PutConnection(model.ConnectionInfo{ConnectionId: "1", ClientIp: "127.0.0.1", Labels: map[string]string{"module": "naming"}})
+	client := newTestAdminClient(t, server, constant.ClientConfig{})
+	srv := client.GetServerList()[0]
+
+	connections, err := client.ListConnections(context.Background(), srv)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(connections))
+	assert.Equal(t, "1", connections[0].ConnectionId)
+	assert.Equal(t, "naming", connections[0].Labels["module"])
+	assert.Equal(t, "app", connections[1].AppName)
+
+	assert.Nil(t, client.ResetConnection(context.Background(), srv, "1", "127.0.0.3:9848"))
+	redirectAddress, ok := server.GetRedirectAddress("1")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.3:9848", redirectAddress)
+	connections, err = client.ListConnections(context.Background(), srv)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(connections))
+
+	err = client.ResetConnection(context.Background(), srv, "1", "")
+	httpErr, ok := http_agent.IsHttpError(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.NotNil(t, client.ResetConnection(context.Background(), srv, "", ""))
+}
+
+func TestListClusterNodes(t *testing.T) {
+	server := newfakeAdminServer()
+	defer server.Close()
+	server.SetClusterNodes([]model.ClusterNode{{Ip: "127.0.0.1", Port: 8848, State: "UP", Address: "127.0.0.1:8848"}})
+	client := newTestAdminClient(t, server, constant.ClientConfig{})
+
+	nodes, err := client.ListClusterNodes(context.Background(), server.ServerConfig())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "UP", nodes[0].State)
+	assert.Equal(t, "127.0.0.1:8848", nodes[0].Address)
+}
+
+func TestReadinessAndLiveness(t *testing.T) {
+	server := newfakeAdminServer()
+	defer server.Close()
+	client := newTestAdminClient(t, server, constant.ClientConfig{})
+
+	status, err := client.Readiness(context.Background(), server.ServerConfig())
+	assert.Nil(t, err)
+	assert.True(t, status.Healthy)
+
+	server.SetReady(false)
+	status, err = client.Readiness(context.Background(), server.ServerConfig())
+	assert.Nil(t, err)
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "server is not healthy", status.Message)
+
+	status, err = client.Liveness(context.Background(), server.ServerConfig())
+	assert.Nil(t, err)
+	assert.True(t, status.Healthy)
+
+	// the unreachable server is reported as the error
+	srv := server.ServerConfig()
+	server.Close()
+	_, err = client.Liveness(context.Background(), srv)
+	assert.NotNil(t, err)
+}
+
+func TestAdminClientWithAccessToken(t *testing.T) {
+	server := newfakeAdminServer()
+	defer server.Close()
+	server.RequireAccessToken("token")
+
+	client := newTestAdminClient(t, server, constant.ClientConfig{})
+	_, err := client.ListConnections(context.Background(), server.ServerConfig())
+	httpErr, ok := http_agent.IsHttpError(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, httpErr.StatusCode)
+
+	client = newTestAdminClient(t, server, constant.ClientConfig{AuthCfg: constant.AuthConfig{AccessToken: "token"}})
+	_, err = client.ListConnections(context.Background(), server.ServerConfig())
+	assert.Nil(t, err)
+}
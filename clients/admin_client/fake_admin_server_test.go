@@ -0,0 +1,167 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin_client
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+// fakeAdminServer is an in-memory xgrpc server serving the http management api.
+type fakeAdminServer struct {
+	*httptest.Server
+	mux         sync.Mutex
+	connections map[string]model.ConnectionInfo
+	nodes       []model.ClusterNode
+	ready       bool
+	alive       bool
+	accessToken string
+	redirects   map[string]string
+}
+
+// newfakeAdminServer starts a fake server, which is ready and alive. Close it after using.
+func newfakeAdminServer() *fakeAdminServer {
+	s := &fakeAdminServer{
+		connections: map[string]model.ConnectionInfo{},
+		redirects:   map[string]string{},
+		ready:       true,
+		alive:       true,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(constant.WEB_CONTEXT+constant.CONNECTION_LIST_PATH, s.listConnections)
+	mux.HandleFunc(constant.WEB_CONTEXT+constant.CONNECTION_RESET_PATH, s.resetConnection)
+	mux.HandleFunc(constant.WEB_CONTEXT+constant.CLUSTER_NODES_PATH, s.listClusterNodes)
+	mux.HandleFunc(constant.WEB_CONTEXT+constant.HEALTH_READINESS_PATH, func(w http.ResponseWriter, r *http.Request) {
+		s.health(w, r, func() bool { return s.ready })
+	})
+	mux.HandleFunc(constant.WEB_CONTEXT+constant.HEALTH_LIVENESS_PATH, func(w http.ResponseWriter, r *http.Request) {
+		s.health(w, r, func() bool { return s.alive })
+	})
+	s.Server = httptest.NewServer(s.authenticate(mux))
+	return s
+}
+
+// ServerConfig returns the config to connect the fake server.
+func (s *fakeAdminServer) ServerConfig() constant.ServerConfig {
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.ParseUint(port, 10, 64)
+	return constant.ServerConfig{Scheme: constant.DEFAULT_SERVER_SCHEME, IpAddr: host, Port: p, ContextPath: constant.WEB_CONTEXT}
+}
+
+// RequireAccessToken rejects the requests without the accessToken with 403.
+func (s *fakeAdminServer) RequireAccessToken(accessToken string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.accessToken = accessToken
+}
+
+func (s *fakeAdminServer) PutConnection(conn model.ConnectionInfo) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.connections[conn.ConnectionId] = conn
+}
+
+// GetRedirectAddress returns the redirectAddress of the reset connection.
+func (s *fakeAdminServer) GetRedirectAddress(connectionId string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	address, ok := s.redirects[connectionId]
+	return address, ok
+}
+
+func (s *fakeAdminServer) SetClusterNodes(nodes []model.ClusterNode) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.nodes = nodes
+}
+
+func (s *fakeAdminServer) SetReady(ready bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ready = ready
+}
+
+func (s *fakeAdminServer) SetAlive(alive bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.alive = alive
+}
+
+func (s *fakeAdminServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mux.Lock()
+		accessToken := s.accessToken
+		s.mux.Unlock()
+		if accessToken != "" && r.URL.Query().Get(constant.KEY_ACCESS_TOKEN) != accessToken {
+			http.Error(w, "token invalid", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *fakeAdminServer) listConnections(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	connections := map[string]connection{}
+	for id, conn := range s.connections {
+		connections[id] = connection{MetaInfo: conn}
+	}
+	writeJson(w, connections)
+}
+
+func (s *fakeAdminServer) resetConnection(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	connectionId := r.URL.Query().Get("connectionId")
+	if _, ok := s.connections[connectionId]; !ok {
+		http.Error(w, "connection not found", http.StatusNotFound)
+		return
+	}
+	delete(s.connections, connectionId)
+	s.redirects[connectionId] = r.URL.Query().Get("redirectAddress")
+	_, _ = w.Write([]byte("success"))
+}
+
+func (s *fakeAdminServer) listClusterNodes(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	data, _ := json.Marshal(s.nodes)
+	writeJson(w, restResult{Code: constant.RESPONSE_CODE_SUCCESS, Message: "success", Data: data})
+}
+
+func (s *fakeAdminServer) health(w http.ResponseWriter, r *http.Request, healthy func() bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !healthy() {
+		http.Error(w, "server is not healthy", http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
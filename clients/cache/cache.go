@@ -0,0 +1,550 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+)
+
+// EvictionPolicy decides the entry evicted from a shard of the Cache when the Cache is full.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently used entry, the least recently used one of them if there're several.
+	LFU
+)
+
+// EvictionReason tells why an entry is removed from the Cache.
+type EvictionReason string
+
+const (
+	EvictionExpired  EvictionReason = "expired"
+	EvictionCapacity EvictionReason = "capacity"
+	EvictionDeleted  EvictionReason = "deleted"
+	EvictionReplaced EvictionReason = "replaced"
+)
+
+// ErrNoLoader is returned by GetOrLoad when neither the cache nor the call has a loader.
+var ErrNoLoader = errors.New("no loader of the cache")
+
+// CacheStats is the statistics of the Cache since it's created.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Loads     uint64
+	Evictions uint64
+}
+
+// CacheOption customizes the Cache created by NewCache.
+type CacheOption[K comparable, V any] func(c *Cache[K, V])
+
+// WithTTL expires the entries the duration after they're set, they never expire by default.
+func WithTTL[K comparable, V any](ttl time.Duration) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.ttl = ttl
+	}
+}
+
+// WithExpireAfterAccess expires the entries the duration after they're set or got, the entries in use are kept.
+func WithExpireAfterAccess[K comparable, V any](ttl time.Duration) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.ttl = ttl
+		c.expireAfterAccess = true
+	}
+}
+
+// WithMaxEntries bounds the number of the entries of all the shards, the entry evicted when the bound is reached is
+// chosen by the policy among the entries of the shard of the new one, use WithShards(1) if it must be chosen among all.
+func WithMaxEntries[K comparable, V any](maxEntries int, policy EvictionPolicy) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.maxEntries = maxEntries
+		c.policy = policy
+	}
+}
+
+// WithLoader sets the loader of GetOrLoad.
+func WithLoader[K comparable, V any](loader func(key K) (V, error)) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.loader = loader
+	}
+}
+
+// WithEvictionCallback calls the callback after an entry is removed, it must not block.
+func WithEvictionCallback[K comparable, V any](callback func(key K, value V, reason EvictionReason)) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.onEvicted = callback
+	}
+}
+
+// WithCacheName counts the hits, misses and evictions of the cache in the metrics labeled by the name.
+func WithCacheName[K comparable, V any](name string) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.name = name
+	}
+}
+
+// WithShards sets the number of the shards, SHARD_COUNT by default.
+func WithShards[K comparable, V any](shards int) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.shardCount = shards
+	}
+}
+
+// WithCleanupInterval removes the expired entries periodically, it's the TTL by default. The expired entries are
+// never returned, they're removed lazily if the interval isn't positive.
+func WithCleanupInterval[K comparable, V any](interval time.Duration) CacheOption[K, V] {
+	return func(c *Cache[K, V]) {
+		c.cleanupInterval = interval
+	}
+}
+
+// Cache is a generic cache sharded like ConcurrentMap, with the expiry, the bound of the entries, the loader whose
+// concurrent calls of the same key are merged, the eviction callback and the hit and miss metrics.
+type Cache[K comparable, V any] struct {
+	shards            []*cacheShard[K, V]
+	shardCount        int
+	ttl               time.Duration
+	expireAfterAccess bool
+	maxEntries        int
+	count             int64 // the number of the entries of all the shards
+	policy            EvictionPolicy
+	loader            func(key K) (V, error)
+	onEvicted         func(key K, value V, reason EvictionReason)
+	name              string
+	cleanupInterval   time.Duration
+	stats             CacheStats
+	done              chan struct{}
+	closeOnce         sync.Once
+}
+
+type cacheShard[K comparable, V any] struct {
+	mux   sync.Mutex
+	items map[K]*list.Element
+	order *list.List // the front is the most recently used
+	count *int64     // the count of the cache
+	calls map[K]*loadCall[V]
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expireAt  time.Time
+	frequency uint64
+}
+
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
+// NewCache create the cache, Close must be called to stop cleaning up the expired entries if TTL is set.
+func NewCache[K comparable, V any](opts ...CacheOption[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{shardCount: SHARD_COUNT, done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.shardCount <= 0 {
+		c.shardCount = SHARD_COUNT
+	}
+	c.shards = make([]*cacheShard[K, V], c.shardCount)
+	for i := range c.shards {
+		c.shards[i] = &cacheShard[K, V]{
+			items: map[K]*list.Element{},
+			order: list.New(),
+			count: &c.count,
+			calls: map[K]*loadCall[V]{},
+		}
+	}
+	if c.cleanupInterval == 0 {
+		c.cleanupInterval = c.ttl
+	}
+	if c.ttl > 0 && c.cleanupInterval > 0 {
+		go c.cleanup()
+	}
+	return c
+}
+
+// Get returns the value of the key if it's cached and not expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	shard := c.getShard(key)
+	shard.mux.Lock()
+	value, ok, expired := shard.get(key, time.Now(), c.accessTTL())
+	shard.mux.Unlock()
+	c.notify(expired)
+	c.record(ok)
+	return value, ok
+}
+
+// GetOrLoad returns the cached value of the key, or loads it by the loader of the cache and caches it.
+// The concurrent loads of the same key share the result of one call, and the errors are not cached.
+func (c *Cache[K, V]) GetOrLoad(key K) (V, error) {
+	return c.GetOrLoadWith(key, c.loader)
+}
+
+// GetOrLoadWith is GetOrLoad with the loader of the call instead of the cache.
+func (c *Cache[K, V]) GetOrLoadWith(key K, loader func(key K) (V, error)) (V, error) {
+	shard := c.getShard(key)
+	shard.mux.Lock()
+	value, ok, expired := shard.get(key, time.Now(), c.accessTTL())
+	if ok {
+		shard.mux.Unlock()
+		c.notify(expired)
+		c.record(true)
+		return value, nil
+	}
+	if call, loading := shard.calls[key]; loading {
+		shard.mux.Unlock()
+		c.notify(expired)
+		c.record(false)
+		<-call.done
+		return call.value, call.err
+	}
+	if loader == nil {
+		shard.mux.Unlock()
+		c.notify(expired)
+		c.record(false)
+		return value, ErrNoLoader
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	shard.calls[key] = call
+	shard.mux.Unlock()
+	c.notify(expired)
+	c.record(false)
+
+	atomic.AddUint64(&c.stats.Loads, 1)
+	call.value, call.err = c.load(key, loader)
+	shard.mux.Lock()
+	delete(shard.calls, key)
+	if call.err == nil {
+		expired = shard.set(key, call.value, c.expireAt(c.ttl), c.maxEntries, c.policy)
+	}
+	shard.mux.Unlock()
+	close(call.done)
+	c.notify(append(expired, c.evictOverflow(shard)...))
+	return call.value, call.err
+}
+
+// Set caches the value of the key with the TTL of the cache.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL caches the value of the key with the ttl, it never expires if the ttl isn't positive.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	shard := c.getShard(key)
+	shard.mux.Lock()
+	evictions := shard.set(key, value, c.expireAt(ttl), c.maxEntries, c.policy)
+	shard.mux.Unlock()
+	c.notify(append(evictions, c.evictOverflow(shard)...))
+}
+
+// Delete removes the key, and returns whether it's cached.
+func (c *Cache[K, V]) Delete(key K) bool {
+	shard := c.getShard(key)
+	shard.mux.Lock()
+	element, ok := shard.items[key]
+	var evictions []evicted[K, V]
+	if ok {
+		entry := shard.remove(element)
+		evictions = append(evictions, evicted[K, V]{key: key, value: entry.value, reason: EvictionDeleted})
+	}
+	shard.mux.Unlock()
+	c.notify(evictions)
+	return ok
+}
+
+// Len returns the number of the entries, including the expired ones not removed yet.
+func (c *Cache[K, V]) Len() int {
+	count := 0
+	for _, shard := range c.shards {
+		shard.mux.Lock()
+		count += len(shard.items)
+		shard.mux.Unlock()
+	}
+	return count
+}
+
+// Keys returns the keys of the entries not expired.
+func (c *Cache[K, V]) Keys() []K {
+	now := time.Now()
+	var keys []K
+	for _, shard := range c.shards {
+		shard.mux.Lock()
+		for key, element := range shard.items {
+			if !element.Value.(*cacheEntry[K, V]).expired(now) {
+				keys = append(keys, key)
+			}
+		}
+		shard.mux.Unlock()
+	}
+	return keys
+}
+
+// Purge removes all the entries.
+func (c *Cache[K, V]) Purge() {
+	for _, shard := range c.shards {
+		var evictions []evicted[K, V]
+		shard.mux.Lock()
+		for _, element := range shard.items {
+			entry := shard.remove(element)
+			evictions = append(evictions, evicted[K, V]{key: entry.key, value: entry.value, reason: EvictionDeleted})
+		}
+		shard.mux.Unlock()
+		c.notify(evictions)
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache[K, V]) Stats() CacheStats {
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.stats.Hits),
+		Misses:    atomic.LoadUint64(&c.stats.Misses),
+		Loads:     atomic.LoadUint64(&c.stats.Loads),
+		Evictions: atomic.LoadUint64(&c.stats.Evictions),
+	}
+}
+
+// Close stops cleaning up the expired entries, the cache is still usable.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// RemoveExpired removes the expired entries.
+func (c *Cache[K, V]) RemoveExpired() {
+	now := time.Now()
+	for _, shard := range c.shards {
+		var evictions []evicted[K, V]
+		shard.mux.Lock()
+		for _, element := range shard.items {
+			if entry := element.Value.(*cacheEntry[K, V]); entry.expired(now) {
+				shard.remove(element)
+				evictions = append(evictions, evicted[K, V]{key: entry.key, value: entry.value, reason: EvictionExpired})
+			}
+		}
+		shard.mux.Unlock()
+		c.notify(evictions)
+	}
+}
+
+func (c *Cache[K, V]) cleanup() {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.RemoveExpired()
+		}
+	}
+}
+
+func (c *Cache[K, V]) load(key K, loader func(key K) (V, error)) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("load %v panicked: %v", key, r)
+		}
+	}()
+	return loader(key)
+}
+
+// accessTTL returns the ttl renewed by the access, 0 if the entries expire after they're set.
+func (c *Cache[K, V]) accessTTL() time.Duration {
+	if c.expireAfterAccess {
+		return c.ttl
+	}
+	return 0
+}
+
+func (c *Cache[K, V]) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (c *Cache[K, V]) getShard(key K) *cacheShard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[uint(hashKey(key))%uint(len(c.shards))]
+}
+
+func (c *Cache[K, V]) record(hit bool) {
+	result := "miss"
+	if hit {
+		atomic.AddUint64(&c.stats.Hits, 1)
+		result = "hit"
+	} else {
+		atomic.AddUint64(&c.stats.Misses, 1)
+	}
+	if c.name != "" {
+		monitor.GetCacheRequestMonitor(c.name, result).Inc()
+	}
+}
+
+// evictOverflow evicts from the other shards than the one of the new entry until the bound is kept, which is
+// exceeded only if the shard of the new entry was empty.
+func (c *Cache[K, V]) evictOverflow(except *cacheShard[K, V]) []evicted[K, V] {
+	if c.maxEntries <= 0 {
+		return nil
+	}
+	var evictions []evicted[K, V]
+	for _, shard := range c.shards {
+		if atomic.LoadInt64(&c.count) <= int64(c.maxEntries) {
+			break
+		}
+		if shard == except {
+			continue
+		}
+		shard.mux.Lock()
+		if len(shard.items) > 0 {
+			evictions = append(evictions, shard.evict(c.policy)...)
+		}
+		shard.mux.Unlock()
+	}
+	return evictions
+}
+
+// notify calls the eviction callback out of the lock of the shard.
+func (c *Cache[K, V]) notify(evictions []evicted[K, V]) {
+	for _, e := range evictions {
+		if e.reason != EvictionReplaced {
+			atomic.AddUint64(&c.stats.Evictions, 1)
+			if c.name != "" {
+				monitor.GetCacheEvictionMonitor(c.name, string(e.reason)).Inc()
+			}
+		}
+		if c.onEvicted != nil {
+			c.onEvicted(e.key, e.value, e.reason)
+		}
+	}
+}
+
+func (s *cacheShard[K, V]) get(key K, now time.Time, accessTTL time.Duration) (value V, ok bool,
+	evictions []evicted[K, V]) {
+	element, ok := s.items[key]
+	if !ok {
+		return value, false, nil
+	}
+	entry := element.Value.(*cacheEntry[K, V])
+	if entry.expired(now) {
+		s.remove(element)
+		return value, false, []evicted[K, V]{{key: key, value: entry.value, reason: EvictionExpired}}
+	}
+	if accessTTL > 0 && !entry.expireAt.IsZero() {
+		entry.expireAt = now.Add(accessTTL)
+	}
+	entry.frequency++
+	s.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (s *cacheShard[K, V]) set(key K, value V, expireAt time.Time, maxEntries int, policy EvictionPolicy) []evicted[K, V] {
+	if element, ok := s.items[key]; ok {
+		entry := element.Value.(*cacheEntry[K, V])
+		old := entry.value
+		entry.value, entry.expireAt = value, expireAt
+		entry.frequency++
+		s.order.MoveToFront(element)
+		return []evicted[K, V]{{key: key, value: old, reason: EvictionReplaced}}
+	}
+	var evictions []evicted[K, V]
+	if maxEntries > 0 && len(s.items) > 0 && atomic.LoadInt64(s.count) >= int64(maxEntries) {
+		evictions = s.evict(policy)
+	}
+	s.items[key] = s.order.PushFront(&cacheEntry[K, V]{key: key, value: value, expireAt: expireAt, frequency: 1})
+	atomic.AddInt64(s.count, 1)
+	return evictions
+}
+
+// evict removes the expired entries, or the one chosen by the policy if none is expired.
+func (s *cacheShard[K, V]) evict(policy EvictionPolicy) []evicted[K, V] {
+	now := time.Now()
+	var evictions []evicted[K, V]
+	for element := s.order.Back(); element != nil; {
+		prev := element.Prev()
+		if entry := element.Value.(*cacheEntry[K, V]); entry.expired(now) {
+			s.remove(element)
+			evictions = append(evictions, evicted[K, V]{key: entry.key, value: entry.value, reason: EvictionExpired})
+		}
+		element = prev
+	}
+	if len(evictions) > 0 {
+		return evictions
+	}
+	victim := s.order.Back()
+	if policy == LFU {
+		for element := victim; element != nil; element = element.Prev() {
+			if element.Value.(*cacheEntry[K, V]).frequency < victim.Value.(*cacheEntry[K, V]).frequency {
+				victim = element
+			}
+		}
+	}
+	entry := s.remove(victim)
+	return []evicted[K, V]{{key: entry.key, value: entry.value, reason: EvictionCapacity}}
+}
+
+func (s *cacheShard[K, V]) remove(element *list.Element) *cacheEntry[K, V] {
+	entry := s.order.Remove(element).(*cacheEntry[K, V])
+	delete(s.items, entry.key)
+	atomic.AddInt64(s.count, -1)
+	return entry
+}
+
+func (e *cacheEntry[K, V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// hashKey hashes the key by fnv32 of its string form, the strings and integers are hashed without formatting.
+func hashKey(key interface{}) uint32 {
+	switch k := key.(type) {
+	case string:
+		return fnv32(k)
+	case int:
+		return uint32(k)
+	case int64:
+		return uint32(k)
+	case uint64:
+		return uint32(k)
+	case int32:
+		return uint32(k)
+	case uint32:
+		return k
+	default:
+		return fnv32(fmt.Sprint(k))
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+)
+
+func TestCacheGetAndSet(t *testing.T) {
+	c := NewCache[string, int](WithCacheName[string, int]("test_get_set"))
+	defer c.Close()
+	// the counters are shared by the caches of the same name, e.g. when running the test repeatedly.
+	hits := testutil.ToFloat64(monitor.GetCacheRequestMonitor("test_get_set", "hit"))
+	misses := testutil.ToFloat64(monitor.GetCacheRequestMonitor("test_get_set", "miss"))
+	deleted := testutil.ToFloat64(monitor.GetCacheEvictionMonitor("test_get_set", "deleted"))
+	c.Set("a", 1)
+	c.Set("a", 2)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.True(t, c.Delete("a"))
+	assert.False(t, c.Delete("a"))
+	assert.Equal(t, 0, c.Len())
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Evictions: 1}, c.Stats())
+	assert.Equal(t, hits+1, testutil.ToFloat64(monitor.GetCacheRequestMonitor("test_get_set", "hit")))
+	assert.Equal(t, misses+1, testutil.ToFloat64(monitor.GetCacheRequestMonitor("test_get_set", "miss")))
+	assert.Equal(t, deleted+1, testutil.ToFloat64(monitor.GetCacheEvictionMonitor("test_get_set", "deleted")))
+}
+
+func TestCacheTTL(t *testing.T) {
+	var mux sync.Mutex
+	var reasons []EvictionReason
+	c := NewCache[int, string](WithTTL[int, string](30*time.Millisecond),
+		WithEvictionCallback(func(key int, value string, reason EvictionReason) {
+			mux.Lock()
+			defer mux.Unlock()
+			reasons = append(reasons, reason)
+		}))
+	defer c.Close()
+	c.Set(1, "expiring")
+	c.SetWithTTL(2, "kept", 0)
+	assert.ElementsMatch(t, []int{1, 2}, c.Keys())
+
+	time.Sleep(50 * time.Millisecond)
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+	_, ok = c.Get(2)
+	assert.True(t, ok)
+	mux.Lock()
+	assert.Equal(t, []EvictionReason{EvictionExpired}, reasons)
+	mux.Unlock()
+
+	// the expired entries are cleaned up without being got
+	c.Set(3, "expiring")
+	assert.Eventually(t, func() bool { return c.Len() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestCacheExpireAfterAccess(t *testing.T) {
+	c := NewCache[string, int](WithExpireAfterAccess[string, int](60 * time.Millisecond))
+	defer c.Close()
+	c.Set("a", 1)
+	for i := 0; i < 4; i++ {
+		time.Sleep(30 * time.Millisecond)
+		_, ok := c.Get("a")
+		assert.True(t, ok)
+	}
+	time.Sleep(80 * time.Millisecond)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCacheLRU(t *testing.T) {
+	var evictedKeys []string
+	c := NewCache[string, int](WithMaxEntries[string, int](2, LRU), WithShards[string, int](1),
+		WithEvictionCallback(func(key string, value int, reason EvictionReason) {
+			if reason == EvictionCapacity {
+				evictedKeys = append(evictedKeys, key)
+			}
+		}))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+	assert.Equal(t, []string{"b"}, evictedKeys)
+	assert.ElementsMatch(t, []string{"a", "c"}, c.Keys())
+}
+
+func TestCacheLFU(t *testing.T) {
+	c := NewCache[string, int](WithMaxEntries[string, int](2, LFU), WithShards[string, int](1))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	c.Set("c", 3)
+	assert.ElementsMatch(t, []string{"a", "c"}, c.Keys())
+}
+
+func TestCacheMaxEntriesAcrossShards(t *testing.T) {
+	c := NewCache[int, int](WithMaxEntries[int, int](100, LRU))
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	assert.True(t, c.Len() <= 100)
+	assert.True(t, c.Len() > 0)
+}
+
+func TestCacheFilledToMaxEntries(t *testing.T) {
+	// the keys are spread over the 32 shards unevenly.
+	c := NewCache[string, int](WithMaxEntries[string, int](50, LRU))
+	for i := 0; i < 50; i++ {
+		c.Set("key-"+strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 50, c.Len())
+	assert.Equal(t, uint64(0), c.Stats().Evictions)
+
+	c.Set("key-50", 50)
+	assert.Equal(t, 50, c.Len())
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	c := NewCache[string, string](WithLoader(func(key string) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if key == "missing" {
+			return "", errors.New("not found")
+		}
+		return "value of " + key, nil
+	}))
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.GetOrLoad("a")
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	for _, result := range results {
+		assert.Equal(t, "value of a", result)
+	}
+	value, err := c.GetOrLoad("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "value of a", value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// the errors are not cached
+	_, err = c.GetOrLoad("missing")
+	assert.EqualError(t, err, "not found")
+	_, err = c.GetOrLoad("missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&loads))
+
+	_, err = NewCache[string, string]().GetOrLoad("a")
+	assert.Equal(t, ErrNoLoader, err)
+}
@@ -35,71 +35,73 @@ func GetFileName(cacheKey string, cacheDir string) string {
 	return cacheDir + string(os.PathSeparator) + cacheKey
 }
 
+// WriteServicesToFile writes the service to the cache file of the default DiskCache of the directory.
 func WriteServicesToFile(service *model.Service, cacheKey, cacheDir string) {
-	err := file.MkdirIfNecessary(cacheDir)
-	if err != nil {
-		logger.Errorf("mkdir cacheDir failed,cacheDir:%s,err:", cacheDir, err)
-		return
-	}
-	bytes, _ := json.Marshal(service)
-	domFileName := GetFileName(cacheKey, cacheDir)
-	err = ioutil.WriteFile(domFileName, bytes, 0666)
-	if err != nil {
-		logger.Errorf("failed to write name cache:%s ,value:%s ,err:%v", domFileName, string(bytes), err)
-	}
+	d, _ := NewDiskCache(cacheDir, constant.DiskCacheConfig{})
+	d.WriteService(service, cacheKey)
 }
 
+// ReadServicesFromFile reads the services from the cache files of the default DiskCache of the directory.
 func ReadServicesFromFile(cacheDir string) map[string]model.Service {
-	files, err := ioutil.ReadDir(cacheDir)
-	if err != nil {
-		logger.Errorf("read cacheDir:%s failed!err:%+v", cacheDir, err)
-		return nil
-	}
-	serviceMap := map[string]model.Service{}
-	for _, f := range files {
-		fileName := GetFileName(f.Name(), cacheDir)
-		b, err := ioutil.ReadFile(fileName)
-		if err != nil {
-			logger.Errorf("failed to read name cache file:%s,err:%+v ", fileName, err)
-			continue
-		}
+	d, _ := NewDiskCache(cacheDir, constant.DiskCacheConfig{})
+	return d.ReadServices()
+}
 
-		s := string(b)
-		service := util.JsonToService(s)
+// WriteConfigToFile writes the config to the cache file of the default DiskCache of the directory,
+// the file is removed if the content is empty.
+func WriteConfigToFile(cacheKey string, cacheDir string, content string) {
+	d, _ := NewDiskCache(cacheDir, constant.DiskCacheConfig{})
+	d.WriteConfig(cacheKey, content)
+}
 
+func ReadConfigFromFile(cacheKey string, cacheDir string) (string, error) {
+	d, _ := NewDiskCache(cacheDir, constant.DiskCacheConfig{})
+	return d.ReadConfig(cacheKey)
+}
+
+func (d *DiskCache) WriteService(service *model.Service, cacheKey string) {
+	bytes, _ := json.Marshal(service)
+	if err := d.Write(cacheKey, bytes); err != nil {
+		logger.Errorf("failed to write name cache:%s ,err:%v", GetFileName(cacheKey, d.dir), err)
+	}
+}
+
+// ReadServices reads all the services cached, the files which are not services are skipped.
+func (d *DiskCache) ReadServices() map[string]model.Service {
+	contents := d.ReadAll()
+	serviceMap := map[string]model.Service{}
+	for key, content := range contents {
+		service := util.JsonToService(string(content))
 		if service == nil {
+			logger.Warnf("skip the name cache file %s, it's not a service", GetFileName(key, d.dir))
 			continue
 		}
 		cacheKey := util.GetServiceCacheKey(util.GetGroupName(service.Name, service.GroupName), service.Clusters)
 		serviceMap[cacheKey] = *service
 	}
-
-	logger.Infof("finish loading name cache, total: %s", strconv.Itoa(len(files)))
+	logger.Infof("finish loading name cache, total: %s", strconv.Itoa(len(serviceMap)))
 	return serviceMap
 }
 
-func WriteConfigToFile(cacheKey string, cacheDir string, content string) {
-	file.MkdirIfNecessary(cacheDir)
-	fileName := GetFileName(cacheKey, cacheDir)
+// WriteConfig writes the config snapshot, the snapshot is removed if the content is empty.
+func (d *DiskCache) WriteConfig(cacheKey string, content string) {
+	fileName := GetFileName(cacheKey, d.dir)
 	if len(content) == 0 {
 		// delete config snapshot
-		if err := os.Remove(fileName); err != nil {
-			logger.Errorf("failed to delete config file,cache:%s ,value:%s ,err:%+v", fileName, content, err)
+		if err := d.Remove(cacheKey); err != nil {
+			logger.Errorf("failed to delete config file,cache:%s ,err:%+v", fileName, err)
 		}
 		return
 	}
-	err := ioutil.WriteFile(fileName, []byte(content), 0666)
-	if err != nil {
-		logger.Errorf("failed to write config  cache:%s ,value:%s ,err:%+v", fileName, content, err)
+	if err := d.Write(cacheKey, []byte(content)); err != nil {
+		logger.Errorf("failed to write config  cache:%s ,err:%+v", fileName, err)
 	}
-
 }
 
-func ReadConfigFromFile(cacheKey string, cacheDir string) (string, error) {
-	fileName := GetFileName(cacheKey, cacheDir)
-	b, err := ioutil.ReadFile(fileName)
+func (d *DiskCache) ReadConfig(cacheKey string) (string, error) {
+	b, err := d.Read(cacheKey)
 	if err != nil {
-		return "", errors.New(fmt.Sprintf("failed to read config cache file:%s,err:%+v ", fileName, err))
+		return "", errors.New(fmt.Sprintf("failed to read config cache file:%s,err:%+v ", GetFileName(cacheKey, d.dir), err))
 	}
 	return string(b), nil
 }
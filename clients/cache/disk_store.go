@@ -0,0 +1,325 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+)
+
+const (
+	// DISK_CACHE_SCHEMA_VERSION is the version of the cache file format, the files of a newer version are not read.
+	DISK_CACHE_SCHEMA_VERSION = 1
+
+	diskCacheMagic    = "#xgrpc-cache "
+	diskCacheFileMode = 0600
+	diskCacheDirMode  = 0700
+	tempFileSuffix    = ".tmp"
+	staleTempFileAge  = time.Minute
+)
+
+var (
+	// ErrCorruptedCache is returned when the cache file is truncated or its checksum mismatches.
+	ErrCorruptedCache = errors.New("cache file is corrupted")
+	// ErrUnsupportedCacheVersion is returned when the cache file is written by a newer schema version.
+	ErrUnsupportedCacheVersion = errors.New("unsupported cache file version")
+)
+
+// diskCacheHeader is the first line of the cache file, the payload follows it.
+type diskCacheHeader struct {
+	Version    int    `json:"version"`
+	Checksum   string `json:"checksum"`
+	Length     int    `json:"length"`
+	Encrypted  bool   `json:"encrypted"`
+	UpdateTime int64  `json:"updateTime"`
+}
+
+// DiskCache keeps the contents in the files of a directory, a file per key. Every file is written to a temp file
+// which is renamed afterwards, so a crash never leaves a partial file, and it starts with a header of the schema
+// version and the sha256 checksum of the payload, so the corrupted files are detected and removed.
+// The files are readable by the owner only, and encrypted by AES-GCM if EncryptKey of the config is set.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	aead    cipher.AEAD
+	mux     sync.Mutex
+}
+
+// NewDiskCache create the cache of the directory, the temp files left by the crashed writes are removed.
+func NewDiskCache(dir string, config constant.DiskCacheConfig) (*DiskCache, error) {
+	d := &DiskCache{dir: dir, maxSize: config.MaxSize}
+	if config.EncryptKey != "" {
+		key := sha256.Sum256([]byte(config.EncryptKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		if d.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	d.removeStaleTempFiles()
+	return d, nil
+}
+
+// Dir returns the directory of the cache.
+func (d *DiskCache) Dir() string {
+	return d.dir
+}
+
+// Write replaces the content of the key atomically, the least recently written files are evicted afterwards
+// if the total size of the directory exceeds MaxSize.
+func (d *DiskCache) Write(key string, content []byte) error {
+	payload, err := d.encrypt(content)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	header, _ := json.Marshal(diskCacheHeader{
+		Version:    DISK_CACHE_SCHEMA_VERSION,
+		Checksum:   hex.EncodeToString(sum[:]),
+		Length:     len(payload),
+		Encrypted:  d.aead != nil,
+		UpdateTime: time.Now().UnixNano() / int64(time.Millisecond),
+	})
+	data := make([]byte, 0, len(diskCacheMagic)+len(header)+1+len(payload))
+	data = append(data, diskCacheMagic...)
+	data = append(data, header...)
+	data = append(data, '\n')
+	data = append(data, payload...)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if err = os.MkdirAll(d.dir, diskCacheDirMode); err != nil {
+		return errors.Wrapf(err, "mkdir cache dir %s failed", d.dir)
+	}
+	if err = writeFileAtomically(GetFileName(key, d.dir), data); err != nil {
+		return err
+	}
+	d.evict(key)
+	return nil
+}
+
+// Read returns the content of the key. The file without the header is written before the header is introduced,
+// its content is returned as is.
+func (d *DiskCache) Read(key string) ([]byte, error) {
+	fileName := GetFileName(key, d.dir)
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(diskCacheMagic)) {
+		return data, nil
+	}
+	reader := bufio.NewReader(bytes.NewReader(data[len(diskCacheMagic):]))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrapf(ErrCorruptedCache, "read header of %s failed", fileName)
+	}
+	var header diskCacheHeader
+	if err = json.Unmarshal(line, &header); err != nil {
+		return nil, errors.Wrapf(ErrCorruptedCache, "parse header of %s failed", fileName)
+	}
+	if header.Version > DISK_CACHE_SCHEMA_VERSION {
+		return nil, errors.Wrapf(ErrUnsupportedCacheVersion, "version %d of %s", header.Version, fileName)
+	}
+	payload, _ := ioutil.ReadAll(reader)
+	sum := sha256.Sum256(payload)
+	if len(payload) != header.Length || hex.EncodeToString(sum[:]) != header.Checksum {
+		return nil, errors.Wrapf(ErrCorruptedCache, "checksum of %s mismatches", fileName)
+	}
+	if !header.Encrypted {
+		return payload, nil
+	}
+	return d.decrypt(fileName, payload)
+}
+
+// Remove removes the file of the key, it's not an error if the file doesn't exist.
+func (d *DiskCache) Remove(key string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if err := os.Remove(GetFileName(key, d.dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Keys returns the keys of the cache files, the failover files and the temp files are not included.
+func (d *DiskCache) Keys() ([]string, error) {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, f := range files {
+		if isCacheFile(f) {
+			keys = append(keys, f.Name())
+		}
+	}
+	return keys, nil
+}
+
+// ReadAll returns the contents of all keys. The corrupted files are removed, and the unreadable ones are skipped.
+func (d *DiskCache) ReadAll() map[string][]byte {
+	keys, err := d.Keys()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("read cache dir %s failed, err:%v", d.dir, err)
+		}
+		return nil
+	}
+	contents := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		content, err := d.Read(key)
+		if errors.Is(err, ErrCorruptedCache) {
+			logger.Warnf("remove the corrupted cache file %s, err:%v", GetFileName(key, d.dir), err)
+			_ = d.Remove(key)
+			continue
+		}
+		if err != nil {
+			logger.Warnf("skip the cache file %s, err:%v", GetFileName(key, d.dir), err)
+			continue
+		}
+		contents[key] = content
+	}
+	return contents
+}
+
+func (d *DiskCache) encrypt(content []byte) ([]byte, error) {
+	if d.aead == nil {
+		return content, nil
+	}
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return d.aead.Seal(nonce, nonce, content, nil), nil
+}
+
+func (d *DiskCache) decrypt(fileName string, payload []byte) ([]byte, error) {
+	if d.aead == nil {
+		return nil, errors.Errorf("cache file %s is encrypted but no EncryptKey is configured", fileName)
+	}
+	if len(payload) < d.aead.NonceSize() {
+		return nil, errors.Wrapf(ErrCorruptedCache, "payload of %s is too short", fileName)
+	}
+	nonce, ciphertext := payload[:d.aead.NonceSize()], payload[d.aead.NonceSize():]
+	content, err := d.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypt cache file %s failed, the EncryptKey may be changed", fileName)
+	}
+	return content, nil
+}
+
+// evict removes the least recently written files until the total size is within maxSize, the file of the key
+// just written is kept.
+func (d *DiskCache) evict(key string) {
+	if d.maxSize <= 0 {
+		return
+	}
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	var total int64
+	var candidates []os.FileInfo
+	for _, f := range files {
+		if !isCacheFile(f) {
+			continue
+		}
+		total += f.Size()
+		if f.Name() != key {
+			candidates = append(candidates, f)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ModTime().Before(candidates[j].ModTime())
+	})
+	for _, f := range candidates {
+		if total <= d.maxSize {
+			return
+		}
+		if err = os.Remove(GetFileName(f.Name(), d.dir)); err != nil {
+			logger.Warnf("evict cache file %s failed, err:%v", f.Name(), err)
+			continue
+		}
+		total -= f.Size()
+		logger.Infof("evict cache file %s, the size of %s exceeds %d", f.Name(), d.dir, d.maxSize)
+	}
+}
+
+func (d *DiskCache) removeStaleTempFiles() {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), tempFileSuffix) && time.Since(f.ModTime()) > staleTempFileAge {
+			_ = os.Remove(GetFileName(f.Name(), d.dir))
+		}
+	}
+}
+
+func isCacheFile(f os.FileInfo) bool {
+	return f.Mode().IsRegular() && !strings.HasSuffix(f.Name(), tempFileSuffix) &&
+		!strings.HasSuffix(f.Name(), constant.FAILOVER_FILE_SUFFIX)
+}
+
+// writeFileAtomically writes the data to a temp file of the same directory and renames it to the file.
+func writeFileAtomically(fileName string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".*"+tempFileSuffix)
+	if err != nil {
+		return errors.Wrapf(err, "create temp file of %s failed", fileName)
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, diskCacheFileMode)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return errors.Wrapf(err, "write file %s failed", fileName)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+func newTestDiskCache(t *testing.T, dir string, config constant.DiskCacheConfig) *DiskCache {
+	d, err := NewDiskCache(dir, config)
+	assert.Nil(t, err)
+	return d
+}
+
+func TestDiskCacheWriteAndRead(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	d := newTestDiskCache(t, dir, constant.DiskCacheConfig{})
+	assert.Nil(t, d.Write("app.yaml", []byte("a: 1")))
+	assert.Nil(t, d.Write("app.yaml", []byte("a: 2")))
+
+	content, err := d.Read("app.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 2", string(content))
+	data, _ := ioutil.ReadFile(GetFileName("app.yaml", dir))
+	assert.True(t, strings.HasPrefix(string(data), diskCacheMagic+`{"version":1,`))
+	if runtime.GOOS != "windows" {
+		info, _ := os.Stat(GetFileName("app.yaml", dir))
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	keys, _ := d.Keys()
+	assert.Equal(t, []string{"app.yaml"}, keys)
+
+	assert.Nil(t, d.Remove("app.yaml"))
+	assert.Nil(t, d.Remove("app.yaml"))
+	_, err = d.Read("app.yaml")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDiskCacheDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, constant.DiskCacheConfig{})
+	assert.Nil(t, d.Write("truncated", []byte("content of the config")))
+	assert.Nil(t, d.Write("modified", []byte("content of the config")))
+	assert.Nil(t, d.Write("newer", []byte("content of the config")))
+	data, _ := ioutil.ReadFile(GetFileName("truncated", dir))
+	assert.Nil(t, ioutil.WriteFile(GetFileName("truncated", dir), data[:len(data)-3], 0600))
+	assert.Nil(t, ioutil.WriteFile(GetFileName("modified", dir), append(data[:len(data)-1], 'X'), 0600))
+	newer := strings.Replace(string(data), `"version":1`, `"version":9`, 1)
+	assert.Nil(t, ioutil.WriteFile(GetFileName("newer", dir), []byte(newer), 0600))
+	// the files written before the header are read as is
+	assert.Nil(t, ioutil.WriteFile(GetFileName("legacy", dir), []byte("legacy content"), 0600))
+
+	_, err := d.Read("truncated")
+	assert.True(t, errors.Is(err, ErrCorruptedCache))
+	_, err = d.Read("modified")
+	assert.True(t, errors.Is(err, ErrCorruptedCache))
+	_, err = d.Read("newer")
+	assert.True(t, errors.Is(err, ErrUnsupportedCacheVersion))
+
+	contents := d.ReadAll()
+	assert.Equal(t, map[string][]byte{"legacy": []byte("legacy content")}, contents)
+	keys, _ := d.Keys()
+	assert.ElementsMatch(t, []string{"legacy", "newer"}, keys)
+}
+
+func TestDiskCacheEncryption(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, constant.DiskCacheConfig{EncryptKey: "secret"})
+	assert.Nil(t, d.Write("app.yaml", []byte("password: 123456")))
+	data, _ := ioutil.ReadFile(GetFileName("app.yaml", dir))
+	assert.NotContains(t, string(data), "123456")
+	content, err := d.Read("app.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "password: 123456", string(content))
+
+	_, err = newTestDiskCache(t, dir, constant.DiskCacheConfig{EncryptKey: "another"}).Read("app.yaml")
+	assert.NotNil(t, err)
+	_, err = newTestDiskCache(t, dir, constant.DiskCacheConfig{}).Read("app.yaml")
+	assert.NotNil(t, err)
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte(strings.Repeat("x", 50))
+	assert.Nil(t, newTestDiskCache(t, dir, constant.DiskCacheConfig{}).Write("a", content))
+	info, _ := os.Stat(GetFileName("a", dir))
+	// 3 files are kept at most
+	d := newTestDiskCache(t, dir, constant.DiskCacheConfig{MaxSize: info.Size()*3 + 10})
+	assert.Nil(t, ioutil.WriteFile(GetFileName("demo"+constant.FAILOVER_FILE_SUFFIX, dir), content, 0600))
+	now := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, d.Write(key, content))
+		modTime := now.Add(time.Duration(i-3) * time.Minute)
+		assert.Nil(t, os.Chtimes(GetFileName(key, dir), modTime, modTime))
+	}
+	assert.Nil(t, d.Write("d", content))
+
+	// the failover file is neither counted nor evicted
+	keys, _ := d.Keys()
+	assert.ElementsMatch(t, []string{"b", "c", "d"}, keys)
+	assert.FileExists(t, GetFileName("demo"+constant.FAILOVER_FILE_SUFFIX, dir))
+}
+
+func TestServicesCache(t *testing.T) {
+	dir := t.TempDir()
+	WriteServicesToFile(&model.Service{Name: "demo", GroupName: "app", Clusters: "a"}, "app@@demo@@a", dir)
+	assert.Nil(t, ioutil.WriteFile(GetFileName("app@@broken", dir), []byte("{"), 0600))
+	stale := GetFileName("app@@demo@@a.123"+tempFileSuffix, dir)
+	assert.Nil(t, ioutil.WriteFile(stale, []byte("{"), 0600))
+	old := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(stale, old, old))
+
+	services := ReadServicesFromFile(dir)
+	assert.Equal(t, 1, len(services))
+	assert.Equal(t, "demo", services["app@@demo@@a"].Name)
+	_, err := os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -17,11 +17,15 @@
 package clients
 
 import (
+	"github.com/allenliu88/xgrpc-client-go/clients/admin_client"
+	"github.com/allenliu88/xgrpc-client-go/clients/config_client"
+	"github.com/allenliu88/xgrpc-client-go/clients/naming_client"
 	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
 	"github.com/allenliu88/xgrpc-client-go/clients/xgrpc_client"
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
 	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
 	"github.com/allenliu88/xgrpc-client-go/vo"
 	"github.com/pkg/errors"
 )
@@ -58,6 +62,150 @@ func NewRpcClientManager(param vo.XgrpcClientParam) (rpcClientManager rpc_client
 	return rpc_client.NewRpcClientManager(serverConfig, clientConfig, httpAgent)
 }
 
+// NewMultiClusterManager use to create the manager of several clusters, each cluster has its own servers, auth and tls.
+func NewMultiClusterManager(params []vo.ClusterParam, opts ...rpc_client.MultiClusterOption) (*rpc_client.MultiClusterManager, error) {
+	clusters := make([]rpc_client.ClusterConfig, 0, len(params))
+	for _, param := range params {
+		xgrpcClient, err := setConfig(vo.XgrpcClientParam{ClientConfig: param.ClientConfig, ServerConfigs: param.ServerConfigs})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s", param.Name)
+		}
+		clientConfig, _ := xgrpcClient.GetClientConfig()
+		serverConfig, _ := xgrpcClient.GetServerConfig()
+		httpAgent, _ := xgrpcClient.GetHttpAgent()
+		clusters = append(clusters, rpc_client.ClusterConfig{
+			Name:          param.Name,
+			ServerConfigs: serverConfig,
+			ClientConfig:  clientConfig,
+			HttpAgent:     httpAgent,
+		})
+	}
+	if len(clusters) > 0 {
+		if err := initLogger(clusters[0].ClientConfig); err != nil {
+			return nil, err
+		}
+	}
+	return rpc_client.NewMultiClusterManager(clusters, opts...)
+}
+
+// CreateConfigClient use to create IConfigClient
+func CreateConfigClient(properties map[string]interface{}) (configClient config_client.IConfigClient, err error) {
+	param := getConfigParam(properties)
+	return NewConfigClient(param)
+}
+
+func NewConfigClient(param vo.XgrpcClientParam) (configClient config_client.IConfigClient, err error) {
+	xgrpcClient, err := setConfig(param)
+	if err != nil {
+		return
+	}
+
+	clientConfig, err := xgrpcClient.GetClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	serverConfig, err := xgrpcClient.GetServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	httpAgent, err := xgrpcClient.GetHttpAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = initLogger(clientConfig); err != nil {
+		return nil, err
+	}
+
+	rpcClientManager, err := rpc_client.NewRpcClientManager(serverConfig, clientConfig, httpAgent)
+	if err != nil {
+		return nil, err
+	}
+	client, err := config_client.NewConfigClient(rpcClientManager, clientConfig)
+	if err != nil {
+		rpcClientManager.Shutdown()
+		return nil, err
+	}
+	return client, nil
+}
+
+// CreateNamingClient use to create INamingClient
+func CreateNamingClient(properties map[string]interface{}) (namingClient naming_client.INamingClient, err error) {
+	param := getConfigParam(properties)
+	return NewNamingClient(param)
+}
+
+func NewNamingClient(param vo.XgrpcClientParam) (namingClient naming_client.INamingClient, err error) {
+	xgrpcClient, err := setConfig(param)
+	if err != nil {
+		return
+	}
+
+	clientConfig, err := xgrpcClient.GetClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	serverConfig, err := xgrpcClient.GetServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	httpAgent, err := xgrpcClient.GetHttpAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = initLogger(clientConfig); err != nil {
+		return nil, err
+	}
+
+	rpcClientManager, err := rpc_client.NewRpcClientManager(serverConfig, clientConfig, httpAgent)
+	if err != nil {
+		return nil, err
+	}
+	client, err := naming_client.NewNamingClient(rpcClientManager, clientConfig)
+	if err != nil {
+		rpcClientManager.Shutdown()
+		return nil, err
+	}
+	return client, nil
+}
+
+// CreateAdminClient use to create IAdminClient
+func CreateAdminClient(properties map[string]interface{}) (adminClient admin_client.IAdminClient, err error) {
+	param := getConfigParam(properties)
+	return NewAdminClient(param)
+}
+
+func NewAdminClient(param vo.XgrpcClientParam) (adminClient admin_client.IAdminClient, err error) {
+	xgrpcClient, err := setConfig(param)
+	if err != nil {
+		return
+	}
+
+	clientConfig, err := xgrpcClient.GetClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	serverConfig, err := xgrpcClient.GetServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	httpAgent, err := xgrpcClient.GetHttpAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = initLogger(clientConfig); err != nil {
+		return nil, err
+	}
+
+	xgrpcServer, err := xgrpc_server.NewXgrpcServer(serverConfig, clientConfig, httpAgent, clientConfig.TimeoutMs, clientConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return admin_client.NewAdminClient(xgrpcServer), nil
+}
+
 func getConfigParam(properties map[string]interface{}) (param vo.XgrpcClientParam) {
 
 	if clientConfigTmp, exist := properties[constant.KEY_CLIENT_CONFIG]; exist {
@@ -104,7 +252,7 @@ func setConfig(param vo.XgrpcClientParam) (iClient xgrpc_client.IXgrpcClient, er
 
 	if _, _err := client.GetHttpAgent(); _err != nil {
 		if clientCfg, err := client.GetClientConfig(); err == nil {
-			_ = client.SetHttpAgent(&http_agent.HttpAgent{TlsConfig: clientCfg.TLSCfg})
+			_ = client.SetHttpAgent(&http_agent.HttpAgent{TlsConfig: clientCfg.TLSCfg, TransportConfig: clientCfg.HttpTransportCfg})
 		}
 	}
 	iClient = client
@@ -0,0 +1,124 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_client
+
+import (
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+// cacheData is the listened config, the listeners are called when the md5 of the content changes.
+type cacheData struct {
+	mux            sync.Mutex
+	dataId         string
+	group          string
+	tenant         string
+	content        string
+	md5            string
+	listeners      []*cacheDataListener
+	syncWithServer bool
+}
+
+type cacheDataListener struct {
+	listener vo.Listener
+	lastMd5  string
+}
+
+func newCacheData(dataId, group, tenant, content string) *cacheData {
+	return &cacheData{
+		dataId:  dataId,
+		group:   group,
+		tenant:  tenant,
+		content: content,
+		md5:     util.Md5(content),
+	}
+}
+
+// addListener adds the listener of the current content, it is called on the next change.
+func (cd *cacheData) addListener(listener vo.Listener) {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	cd.listeners = append(cd.listeners, &cacheDataListener{listener: listener, lastMd5: cd.md5})
+	cd.syncWithServer = false
+}
+
+func (cd *cacheData) removeListeners() {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	cd.listeners = nil
+	cd.syncWithServer = false
+}
+
+func (cd *cacheData) hasListeners() bool {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	return len(cd.listeners) > 0
+}
+
+func (cd *cacheData) setContent(content string) {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	cd.content = content
+	cd.md5 = util.Md5(content)
+}
+
+func (cd *cacheData) isSyncWithServer() bool {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	return cd.syncWithServer
+}
+
+func (cd *cacheData) setSyncWithServer(sync bool) {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	cd.syncWithServer = sync
+}
+
+// markSyncIfUnchanged marks the cache synced if the md5 is still the listened one.
+func (cd *cacheData) markSyncIfUnchanged(md5 string) {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	if cd.md5 == md5 {
+		cd.syncWithServer = true
+	}
+}
+
+func (cd *cacheData) listenContext() model.ConfigListenContext {
+	cd.mux.Lock()
+	defer cd.mux.Unlock()
+	return model.ConfigListenContext{Group: cd.group, Md5: cd.md5, DataId: cd.dataId, Tenant: cd.tenant}
+}
+
+// checkListenersMd5 calls the listeners whose last notified md5 differs from the current one.
+func (cd *cacheData) checkListenersMd5() {
+	cd.mux.Lock()
+	var notified []vo.Listener
+	for _, l := range cd.listeners {
+		if l.lastMd5 != cd.md5 {
+			l.lastMd5 = cd.md5
+			notified = append(notified, l.listener)
+		}
+	}
+	tenant, group, dataId, content := cd.tenant, cd.group, cd.dataId, cd.content
+	cd.mux.Unlock()
+	for _, listener := range notified {
+		listener(tenant, group, dataId, content)
+	}
+}
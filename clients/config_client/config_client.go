@@ -0,0 +1,387 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_client
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/cache"
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+const (
+	SEARCH_ACCURATE = "accurate"
+	SEARCH_BLUR     = "blur"
+)
+
+// listenRetryInterval is the interval of retrying the unsynced configs, all configs are listened again
+// every constant.ALL_SYNC_INTERNAL.
+var listenRetryInterval = 5 * time.Second
+
+// redoListenKey is the key of the redo task listening all the configs again after reconnecting.
+const redoListenKey = "config:listen"
+
+type ConfigClient struct {
+	rpcClientManager rpc_client.IRpcClientManager
+	rpcClient        *rpc.RpcClient
+	clientConfig     constant.ClientConfig
+	configCacheDir   string
+	diskCache        *cache.DiskCache
+	cacheMap         cache.ConcurrentMap
+	listenExecute    chan struct{}
+	listenMux        sync.Mutex
+	done             chan struct{}
+	closeOnce        sync.Once
+}
+
+// NewConfigClient create the config client, which sends the requests by the rpc client of the config module.
+// The snapshots and the failover files are kept in the config directory of ClientConfig.CacheDir.
+func NewConfigClient(rpcClientManager rpc_client.IRpcClientManager, clientConfig constant.ClientConfig) (*ConfigClient, error) {
+	if rpcClientManager == nil {
+		return nil, errors.New("rpcClientManager can not be nil")
+	}
+	configCacheDir := filepath.Join(clientConfig.CacheDir, "config")
+	diskCache, err := cache.NewDiskCache(configCacheDir, clientConfig.DiskCacheCfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &ConfigClient{
+		rpcClientManager: rpcClientManager,
+		clientConfig:     clientConfig,
+		configCacheDir:   configCacheDir,
+		diskCache:        diskCache,
+		cacheMap:         cache.NewConcurrentMap(),
+		listenExecute:    make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	client.rpcClient = rpcClientManager.CreateRpcClient(constant.LABEL_MODULE_CONFIG, map[string]string{
+		constant.LABEL_MODULE: constant.LABEL_MODULE_CONFIG,
+	}, map[rpc.IServerRequestHandler]func() rpc_request.IRequest{
+		&ConfigChangeNotifyRequestHandler{client: client}: func() rpc_request.IRequest {
+			return rpc_request.NewConfigChangeNotifyRequest("", "", "")
+		},
+	})
+	client.rpcClient.GetRedoService().Register(redoListenKey, client.redoListen)
+	go client.startListen()
+	return client, nil
+}
+
+func (c *ConfigClient) GetConfig(param vo.ConfigParam) (string, error) {
+	if param.DataId == "" {
+		return "", errors.New("dataId can not be empty")
+	}
+	group := defaultGroup(param.Group)
+	tenant := c.clientConfig.NamespaceId
+	cacheKey := util.GetConfigCacheKey(param.DataId, group, tenant)
+	if content := cache.GetFailover(cacheKey, c.configCacheDir); content != "" {
+		logger.Warnf("get config from failover, dataId=%s, group=%s, tenant=%s", param.DataId, group, tenant)
+		return content, nil
+	}
+	response, err := c.queryConfig(rpc_client.WithRateLimitKey(context.Background(), cacheKey), param.DataId, group, tenant)
+	if errors.Is(err, rpc_client.ErrRateLimited) {
+		return "", errors.Wrapf(err, "get config too frequently, dataId=%s, group=%s, tenant=%s", param.DataId, group, tenant)
+	}
+	if err != nil {
+		content, cacheErr := c.diskCache.ReadConfig(cacheKey)
+		if cacheErr != nil {
+			return "", err
+		}
+		logger.Warnf("get config from snapshot, dataId=%s, group=%s, tenant=%s, err=%v", param.DataId, group, tenant, err)
+		return content, nil
+	}
+	return response.Content, nil
+}
+
+func (c *ConfigClient) PublishConfig(param vo.ConfigParam) (bool, error) {
+	if param.DataId == "" || param.Content == "" {
+		return false, errors.New("dataId and content can not be empty")
+	}
+	request := rpc_request.NewConfigPublishRequest(defaultGroup(param.Group), param.DataId, c.clientConfig.NamespaceId,
+		param.Content, param.CasMd5)
+	request.AdditionMap["tag"] = param.Tag
+	request.AdditionMap["appName"] = param.AppName
+	request.AdditionMap["betaIps"] = param.BetaIps
+	request.AdditionMap["type"] = param.Type
+	request.AdditionMap["encryptedDataKey"] = param.EncryptedDataKey
+	if _, err := c.request(request); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *ConfigClient) DeleteConfig(param vo.ConfigParam) (bool, error) {
+	if param.DataId == "" {
+		return false, errors.New("dataId can not be empty")
+	}
+	request := rpc_request.NewConfigRemoveRequest(defaultGroup(param.Group), param.DataId, c.clientConfig.NamespaceId, param.Tag)
+	if _, err := c.request(request); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListenConfig adds the OnChange listener, it is called when the content differs from the one at listening.
+// The listeners are called by the listen goroutine, they should not block.
+func (c *ConfigClient) ListenConfig(param vo.ConfigParam) error {
+	if param.DataId == "" || param.OnChange == nil {
+		return errors.New("dataId and onChange can not be empty")
+	}
+	group := defaultGroup(param.Group)
+	tenant := c.clientConfig.NamespaceId
+	cacheKey := util.GetConfigCacheKey(param.DataId, group, tenant)
+	// the listener is added while the shard is locked, so the cache can't be removed by the listen task meanwhile.
+	c.cacheMap.Upsert(cacheKey, nil, func(exist bool, valueInMap interface{}, newValue interface{}) interface{} {
+		var cd *cacheData
+		if exist {
+			cd = valueInMap.(*cacheData)
+		} else {
+			content, err := c.diskCache.ReadConfig(cacheKey)
+			if err != nil {
+				content = ""
+			}
+			cd = newCacheData(param.DataId, group, tenant, content)
+		}
+		cd.addListener(param.OnChange)
+		return cd
+	})
+	c.asyncNotifyListenConfig()
+	return nil
+}
+
+// CancelListenConfig removes all listeners of the config, the server stops notifying it.
+func (c *ConfigClient) CancelListenConfig(param vo.ConfigParam) error {
+	if param.DataId == "" {
+		return errors.New("dataId can not be empty")
+	}
+	cacheKey := util.GetConfigCacheKey(param.DataId, defaultGroup(param.Group), c.clientConfig.NamespaceId)
+	if v, ok := c.cacheMap.Get(cacheKey); ok {
+		v.(*cacheData).removeListeners()
+		c.asyncNotifyListenConfig()
+	}
+	return nil
+}
+
+func (c *ConfigClient) SearchConfig(param vo.SearchConfigParm) (*model.ConfigPage, error) {
+	if param.Search != SEARCH_ACCURATE && param.Search != SEARCH_BLUR {
+		return nil, errors.New("search must be accurate or blur")
+	}
+	if param.PageNo <= 0 {
+		param.PageNo = 1
+	}
+	if param.PageSize <= 0 {
+		param.PageSize = 10
+	}
+	request := rpc_request.NewConfigSearchRequest(param.Group, param.DataId, c.clientConfig.NamespaceId, param.Search,
+		param.PageNo, param.PageSize)
+	request.Tag = param.Tag
+	request.AppName = param.AppName
+	response, err := c.request(request)
+	if err != nil {
+		return nil, err
+	}
+	searchResponse, ok := response.(*rpc_response.ConfigSearchResponse)
+	if !ok {
+		return nil, errors.Errorf("unexpected response %s of ConfigSearchRequest", response.GetResponseType())
+	}
+	return &searchResponse.Page, nil
+}
+
+func (c *ConfigClient) CloseClient() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.rpcClientManager.Close(c.rpcClient)
+	})
+}
+
+// queryConfig queries the config from the server and keeps the snapshot, the snapshot is removed
+// when the config is not found.
+func (c *ConfigClient) queryConfig(ctx context.Context, dataId, group, tenant string) (*rpc_response.ConfigQueryResponse, error) {
+	response, err := c.rpcClientManager.RequestWithContext(ctx, c.rpcClient,
+		rpc_request.NewConfigQueryRequest(group, dataId, tenant), c.clientConfig.TimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := util.GetConfigCacheKey(dataId, group, tenant)
+	if response.GetErrorCode() == rpc_response.CONFIG_NOT_FOUND {
+		c.diskCache.WriteConfig(cacheKey, "")
+		return &rpc_response.ConfigQueryResponse{Response: &rpc_response.Response{ResultCode: response.GetResultCode(),
+			ErrorCode: response.GetErrorCode(), Message: response.GetMessage()}}, nil
+	}
+	if response.GetErrorCode() == rpc_response.CONFIG_QUERY_CONFLICT {
+		return nil, errors.Errorf("config is being modified, dataId=%s, group=%s, tenant=%s", dataId, group, tenant)
+	}
+	queryResponse, ok := response.(*rpc_response.ConfigQueryResponse)
+	if !response.IsSuccess() || !ok {
+		return nil, errors.Errorf("query config failed, dataId=%s, group=%s, tenant=%s, errorCode:%d, message:%s",
+			dataId, group, tenant, response.GetErrorCode(), response.GetMessage())
+	}
+	c.diskCache.WriteConfig(cacheKey, queryResponse.Content)
+	return queryResponse, nil
+}
+
+func (c *ConfigClient) request(request rpc_request.IRequest) (rpc_response.IResponse, error) {
+	response, err := c.rpcClientManager.Request(c.rpcClient, request, c.clientConfig.TimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsSuccess() {
+		return nil, errors.Errorf("request %s failed, errorCode:%d, message:%s", request.GetRequestType(),
+			response.GetErrorCode(), response.GetMessage())
+	}
+	return response, nil
+}
+
+func (c *ConfigClient) asyncNotifyListenConfig() {
+	select {
+	case c.listenExecute <- struct{}{}:
+	default:
+	}
+}
+
+func (c *ConfigClient) markAllUnsynced() {
+	for _, v := range c.cacheMap.Items() {
+		v.(*cacheData).setSyncWithServer(false)
+	}
+}
+
+// redoListen listens all the configs again, the changes during the disconnection are missed otherwise.
+func (c *ConfigClient) redoListen() error {
+	c.markAllUnsynced()
+	return c.executeConfigListen(false)
+}
+
+// startListen listens the unsynced configs on notification, and retries them periodically.
+func (c *ConfigClient) startListen() {
+	ticker := time.NewTicker(listenRetryInterval)
+	defer ticker.Stop()
+	lastAllSync := time.Now()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.listenExecute:
+			_ = c.executeConfigListen(false)
+		case <-ticker.C:
+			allSync := time.Since(lastAllSync) >= constant.ALL_SYNC_INTERNAL
+			if allSync {
+				lastAllSync = time.Now()
+			}
+			_ = c.executeConfigListen(allSync)
+		}
+	}
+}
+
+// executeConfigListen listens the unsynced configs or all configs, the changed ones are queried and
+// their listeners are called. The configs without listeners are removed. The first error is returned.
+func (c *ConfigClient) executeConfigListen(allSync bool) error {
+	c.listenMux.Lock()
+	defer c.listenMux.Unlock()
+	var (
+		listenCaches    []*cacheData
+		listenContexts  []model.ConfigListenContext
+		removedCaches   []*cacheData
+		removedContexts []model.ConfigListenContext
+		firstErr        error
+	)
+	for _, v := range c.cacheMap.Items() {
+		cd := v.(*cacheData)
+		if cd.isSyncWithServer() && !allSync {
+			continue
+		}
+		if cd.hasListeners() {
+			listenCaches = append(listenCaches, cd)
+			listenContexts = append(listenContexts, cd.listenContext())
+		} else {
+			removedCaches = append(removedCaches, cd)
+			removedContexts = append(removedContexts, cd.listenContext())
+		}
+	}
+
+	if len(listenContexts) > 0 {
+		response, err := c.request(rpc_request.NewConfigBatchListenRequest(true, listenContexts))
+		if err != nil {
+			logger.Errorf("listen configs failed, err=%v", err)
+			firstErr = err
+		} else if listenResponse, ok := response.(*rpc_response.ConfigChangeBatchListenResponse); ok {
+			changed := make(map[string]struct{}, len(listenResponse.ChangedConfigs))
+			for _, config := range listenResponse.ChangedConfigs {
+				changed[util.GetConfigCacheKey(config.DataId, config.Group, config.Tenant)] = struct{}{}
+			}
+			for i, cd := range listenCaches {
+				ctx := listenContexts[i]
+				if _, ok := changed[util.GetConfigCacheKey(ctx.DataId, ctx.Group, ctx.Tenant)]; ok {
+					if err = c.refreshContentAndCheck(cd); err != nil && firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					cd.markSyncIfUnchanged(ctx.Md5)
+				}
+			}
+		}
+	}
+
+	if len(removedContexts) > 0 {
+		if _, err := c.request(rpc_request.NewConfigBatchListenRequest(false, removedContexts)); err != nil {
+			logger.Errorf("cancel listening configs failed, err=%v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return firstErr
+		}
+		for i, cd := range removedCaches {
+			ctx := removedContexts[i]
+			cacheKey := util.GetConfigCacheKey(ctx.DataId, ctx.Group, ctx.Tenant)
+			c.cacheMap.RemoveCb(cacheKey, func(key string, v interface{}, exists bool) bool {
+				return exists && v == cd && !cd.hasListeners()
+			})
+		}
+	}
+	return firstErr
+}
+
+func (c *ConfigClient) refreshContentAndCheck(cd *cacheData) error {
+	ctx := cd.listenContext()
+	response, err := c.queryConfig(context.Background(), ctx.DataId, ctx.Group, ctx.Tenant)
+	if err != nil {
+		logger.Errorf("refresh config failed, dataId=%s, group=%s, tenant=%s, err=%v", ctx.DataId, ctx.Group, ctx.Tenant, err)
+		return err
+	}
+	cd.setContent(response.Content)
+	cd.checkListenersMd5()
+	cd.setSyncWithServer(true)
+	return nil
+}
+
+func defaultGroup(group string) string {
+	if group == "" {
+		return constant.DEFAULT_GROUP
+	}
+	return group
+}
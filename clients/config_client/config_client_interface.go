@@ -0,0 +1,74 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_client
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+//go:generate mockgen -destination ../../mock/mock_config_client_interface.go -package mock -source=./config_client_interface.go
+
+// IConfigClient gets, publishes and listens the configs of the configuration center.
+type IConfigClient interface {
+
+	// GetConfig use to get config from server, the failover file is read first and the snapshot is read
+	// when the servers are unreachable
+	// dataId  require
+	// group   require
+	// tenant ==>xgrpc.namespace optional
+	GetConfig(param vo.ConfigParam) (string, error)
+
+	// PublishConfig use to publish config to server
+	// dataId  require
+	// group   require
+	// content require
+	// tenant ==>xgrpc.namespace optional
+	// casMd5  optional,the config is only published when its md5 equals casMd5
+	PublishConfig(param vo.ConfigParam) (bool, error)
+
+	// DeleteConfig use to delete config
+	// dataId  require
+	// group   require
+	// tenant ==>xgrpc.namespace optional
+	DeleteConfig(param vo.ConfigParam) (bool, error)
+
+	// ListenConfig use to listen config change,it will callback OnChange() when config change
+	// dataId  require
+	// group   require
+	// onchange require
+	// tenant ==>xgrpc.namespace optional
+	ListenConfig(params vo.ConfigParam) (err error)
+
+	// CancelListenConfig use to cancel listen config change
+	// dataId  require
+	// group   require
+	// tenant ==>xgrpc.namespace optional
+	CancelListenConfig(params vo.ConfigParam) (err error)
+
+	// SearchConfig use to search xgrpc config
+	// search  require search=accurate--exact match  search=blur--fuzzy match
+	// group   option
+	// dataId  option
+	// tenant ==>xgrpc.namespace optional
+	// pageNo  option,default is 1
+	// pageSize option,default is 10
+	SearchConfig(param vo.SearchConfigParm) (*model.ConfigPage, error)
+
+	// CloseClient Close the GRPC client
+	CloseClient()
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_client
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/internal/fake_config_server"
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/util"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+func newTestConfigClient(t *testing.T, server *fake_config_server.FakeConfigServer) *ConfigClient {
+	clientConfig := constant.ClientConfig{TimeoutMs: 3000, NamespaceId: "public", CacheDir: t.TempDir()}
+	manager, err := rpc_client.NewRpcClientManager([]constant.ServerConfig{server.ServerConfig()}, clientConfig, &http_agent.HttpAgent{})
+	assert.Nil(t, err)
+	client, err := NewConfigClient(manager, clientConfig)
+	assert.Nil(t, err)
+	return client
+}
+
+func newTestConfigServer(t *testing.T) *fake_config_server.FakeConfigServer {
+	server, err := fake_config_server.NewFakeConfigServer()
+	assert.Nil(t, err)
+	return server
+}
+
+type change struct {
+	dataId  string
+	content string
+}
+
+func waitChange(t *testing.T, changes chan change) change {
+	select {
+	case c := <-changes:
+		return c
+	case <-time.After(3 * time.Second):
+		t.Fatal("config change not notified")
+		return change{}
+	}
+}
+
+func TestPublishGetAndDeleteConfig(t *testing.T) {
+	server := newTestConfigServer(t)
+	defer server.Stop()
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+
+	ok, err := client.PublishConfig(vo.ConfigParam{DataId: "publish.yaml", Content: "a: 1", Type: "yaml"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	item, exist := server.GetConfig("publish.yaml", constant.DEFAULT_GROUP, "public")
+	assert.True(t, exist)
+	assert.Equal(t, "a: 1", item.Content)
+	var publishRequest rpc_request.ConfigPublishRequest
+	assert.Nil(t, server.GetRequests("ConfigPublishRequest")[0].Decode(&publishRequest))
+	assert.Equal(t, "yaml", publishRequest.AdditionMap["type"])
+
+	content, err := client.GetConfig(vo.ConfigParam{DataId: "publish.yaml"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 1", content)
+	cacheKey := util.GetConfigCacheKey("publish.yaml", constant.DEFAULT_GROUP, "public")
+	snapshot, err := client.diskCache.ReadConfig(cacheKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 1", snapshot)
+
+	ok, err = client.DeleteConfig(vo.ConfigParam{DataId: "publish.yaml"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	content, err = client.GetConfig(vo.ConfigParam{DataId: "publish.yaml"})
+	assert.Nil(t, err)
+	assert.Equal(t, "", content)
+	_, err = client.diskCache.ReadConfig(cacheKey)
+	assert.NotNil(t, err)
+
+	_, err = client.PublishConfig(vo.ConfigParam{DataId: "publish.yaml"})
+	assert.NotNil(t, err)
+}
+
+func TestPublishConfigWithCasMd5(t *testing.T) {
+	server := newTestConfigServer(t)
+	defer server.Stop()
+	server.SetConfig("cas.yaml", constant.DEFAULT_GROUP, "public", "v1")
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+
+	_, err := client.PublishConfig(vo.ConfigParam{DataId: "cas.yaml", Content: "v2", CasMd5: util.Md5("v0")})
+	assert.NotNil(t, err)
+	ok, err := client.PublishConfig(vo.ConfigParam{DataId: "cas.yaml", Content: "v2", CasMd5: util.Md5("v1")})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	item, _ := server.GetConfig("cas.yaml", constant.DEFAULT_GROUP, "public")
+	assert.Equal(t, "v2", item.Content)
+}
+
+func TestGetConfigFromSnapshotAndFailover(t *testing.T) {
+	server := newTestConfigServer(t)
+	server.SetConfig("snapshot.yaml", "app", "public", "from server")
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+
+	content, err := client.GetConfig(vo.ConfigParam{DataId: "snapshot.yaml", Group: "app"})
+	assert.Nil(t, err)
+	assert.Equal(t, "from server", content)
+
+	server.Stop()
+	content, err = client.GetConfig(vo.ConfigParam{DataId: "snapshot.yaml", Group: "app"})
+	assert.Nil(t, err)
+	assert.Equal(t, "from server", content)
+	_, err = client.GetConfig(vo.ConfigParam{DataId: "missing.yaml", Group: "app"})
+	assert.NotNil(t, err)
+
+	cacheKey := util.GetConfigCacheKey("snapshot.yaml", "app", "public")
+	failover := filepath.Join(client.configCacheDir, cacheKey+constant.FAILOVER_FILE_SUFFIX)
+	assert.Nil(t, ioutil.WriteFile(failover, []byte("from failover"), 0666))
+	content, err = client.GetConfig(vo.ConfigParam{DataId: "snapshot.yaml", Group: "app"})
+	assert.Nil(t, err)
+	assert.Equal(t, "from failover", content)
+}
+
+func TestListenConfig(t *testing.T) {
+	server := newTestConfigServer(t)
+	defer server.Stop()
+	server.SetConfig("listen.yaml", constant.DEFAULT_GROUP, "public", "v1")
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+
+	changes := make(chan change, 8)
+	err := client.ListenConfig(vo.ConfigParam{DataId: "listen.yaml", OnChange: func(namespace, group, dataId, data string) {
+		changes <- change{dataId: dataId, content: data}
+	}})
+	assert.Nil(t, err)
+	// the config differs from the empty snapshot, so the listener is called with the current one.
+	assert.Equal(t, change{dataId: "listen.yaml", content: "v1"}, waitChange(t, changes))
+
+	server.PublishConfig("listen.yaml", constant.DEFAULT_GROUP, "public", "v2")
+	assert.Equal(t, change{dataId: "listen.yaml", content: "v2"}, waitChange(t, changes))
+
+	_, err = client.PublishConfig(vo.ConfigParam{DataId: "listen.yaml", Content: "v3"})
+	assert.Nil(t, err)
+	assert.Equal(t, change{dataId: "listen.yaml", content: "v3"}, waitChange(t, changes))
+
+	assert.Nil(t, client.CancelListenConfig(vo.ConfigParam{DataId: "listen.yaml"}))
+	assert.Eventually(t, func() bool {
+		_, ok := client.cacheMap.Get(util.GetConfigCacheKey("listen.yaml", constant.DEFAULT_GROUP, "public"))
+		return !ok
+	}, 3*time.Second, 10*time.Millisecond)
+	server.PublishConfig("listen.yaml", constant.DEFAULT_GROUP, "public", "v4")
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change %+v after cancel", c)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestListenConfigAfterReconnect(t *testing.T) {
+	server := newTestConfigServer(t)
+	defer server.Stop()
+	server.SetConfig("reconnect.yaml", constant.DEFAULT_GROUP, "public", "v1")
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+	changes := make(chan change, 8)
+	assert.Nil(t, client.ListenConfig(vo.ConfigParam{DataId: "reconnect.yaml", OnChange: func(namespace, group, dataId, data string) {
+		changes <- change{dataId: dataId, content: data}
+	}}))
+	assert.Equal(t, "v1", waitChange(t, changes).content)
+
+	// the change without notification is found by listening again after reconnecting.
+	server.SetConfig("reconnect.yaml", constant.DEFAULT_GROUP, "public", "v2")
+	server.ResetConnections()
+	assert.Equal(t, "v2", waitChange(t, changes).content)
+}
+
+func TestSearchConfig(t *testing.T) {
+	server := newTestConfigServer(t)
+	defer server.Stop()
+	server.SetConfig("app-a.yaml", "app", "public", "a")
+	server.SetConfig("app-b.yaml", "app", "public", "b")
+	server.SetConfig("db.yaml", "app", "public", "db")
+	server.SetConfig("app-c.yaml", "app", "other", "c")
+	client := newTestConfigClient(t, server)
+	defer client.CloseClient()
+
+	page, err := client.SearchConfig(vo.SearchConfigParm{Search: SEARCH_BLUR, DataId: "app-*", PageSize: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, page.TotalCount)
+	assert.Equal(t, 2, page.PagesAvailable)
+	assert.Equal(t, 1, len(page.PageItems))
+	assert.Equal(t, "app-a.yaml", page.PageItems[0].DataId)
+
+	page, err = client.SearchConfig(vo.SearchConfigParm{Search: SEARCH_ACCURATE, DataId: "db.yaml", Group: "app"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, page.TotalCount)
+	assert.Equal(t, "db", page.PageItems[0].Content)
+
+	_, err = client.SearchConfig(vo.SearchConfigParm{DataId: "db.yaml"})
+	assert.NotNil(t, err)
+}
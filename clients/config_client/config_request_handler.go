@@ -0,0 +1,53 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_client
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// ConfigChangeNotifyRequestHandler handles the change notification pushed by the server,
+// the changed config is queried again by the listen task.
+type ConfigChangeNotifyRequestHandler struct {
+	client *ConfigClient
+}
+
+func (c *ConfigChangeNotifyRequestHandler) Name() string {
+	return "ConfigChangeNotifyRequestHandler"
+}
+
+func (c *ConfigChangeNotifyRequestHandler) RequestReply(request rpc_request.IRequest, rpcClient *rpc.RpcClient) rpc_response.IResponse {
+	notifyRequest, ok := request.(*rpc_request.ConfigChangeNotifyRequest)
+	if !ok {
+		return nil
+	}
+	logger.Infof("%s config change notified, dataId=%s, group=%s, tenant=%s", rpcClient.Name,
+		notifyRequest.DataId, notifyRequest.Group, notifyRequest.Tenant)
+	cacheKey := util.GetConfigCacheKey(notifyRequest.DataId, notifyRequest.Group, notifyRequest.Tenant)
+	if v, ok := c.client.cacheMap.Get(cacheKey); ok {
+		v.(*cacheData).setSyncWithServer(false)
+		c.client.asyncNotifyListenConfig()
+	}
+	return &rpc_response.ConfigChangeNotifyResponse{
+		Response: &rpc_response.Response{ResultCode: constant.RESPONSE_CODE_SUCCESS, Success: true},
+	}
+}
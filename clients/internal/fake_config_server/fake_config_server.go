@@ -0,0 +1,181 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake_config_server
+
+import (
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/fake_server"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// FakeConfigServer is a fake_server.FakeServer keeping the configs in memory, the connected clients are
+// notified when a config is published or removed.
+type FakeConfigServer struct {
+	*fake_server.FakeServer
+	mux     sync.Mutex
+	configs map[string]model.ConfigItem
+}
+
+func NewFakeConfigServer() (*FakeConfigServer, error) {
+	server, err := fake_server.NewFakeServer()
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeConfigServer{FakeServer: server, configs: map[string]model.ConfigItem{}}
+	server.Handle("ConfigQueryRequest", s.handleQuery)
+	server.Handle("ConfigPublishRequest", s.handlePublish)
+	server.Handle("ConfigRemoveRequest", s.handleRemove)
+	server.Handle("ConfigBatchListenRequest", s.handleBatchListen)
+	server.Handle("ConfigSearchRequest", s.handleSearch)
+	return s, nil
+}
+
+// SetConfig sets the config without notifying the clients, it's removed if the content is empty.
+func (s *FakeConfigServer) SetConfig(dataId, group, tenant, content string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	key := util.GetConfigCacheKey(dataId, group, tenant)
+	if content == "" {
+		delete(s.configs, key)
+		return
+	}
+	s.configs[key] = model.ConfigItem{DataId: dataId, Group: group, Tenant: tenant, Content: content, Md5: util.Md5(content)}
+}
+
+// PublishConfig sets the config and notifies the clients.
+func (s *FakeConfigServer) PublishConfig(dataId, group, tenant, content string) {
+	s.SetConfig(dataId, group, tenant, content)
+	s.notify(dataId, group, tenant)
+}
+
+func (s *FakeConfigServer) GetConfig(dataId, group, tenant string) (model.ConfigItem, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	item, ok := s.configs[util.GetConfigCacheKey(dataId, group, tenant)]
+	return item, ok
+}
+
+func (s *FakeConfigServer) notify(dataId, group, tenant string) {
+	if _, err := s.Push(rpc_request.NewConfigChangeNotifyRequest(group, dataId, tenant), 3*time.Second); err != nil {
+		logger.Warnf("notify config change failed, dataId=%s, err=%v", dataId, err)
+	}
+}
+
+func (s *FakeConfigServer) handleQuery(request *fake_server.Request) rpc_response.IResponse {
+	var query rpc_request.ConfigQueryRequest
+	_ = request.Decode(&query)
+	item, ok := s.GetConfig(query.DataId, query.Group, query.Tenant)
+	if !ok {
+		return &rpc_response.ConfigQueryResponse{Response: &rpc_response.Response{ResultCode: 500,
+			ErrorCode: rpc_response.CONFIG_NOT_FOUND, Message: "config data not exist"}}
+	}
+	return &rpc_response.ConfigQueryResponse{Response: fake_server.SuccessResponse(), Content: item.Content, Md5: item.Md5}
+}
+
+func (s *FakeConfigServer) handlePublish(request *fake_server.Request) rpc_response.IResponse {
+	var publish rpc_request.ConfigPublishRequest
+	_ = request.Decode(&publish)
+	if publish.CasMd5 != "" {
+		if item, _ := s.GetConfig(publish.DataId, publish.Group, publish.Tenant); item.Md5 != publish.CasMd5 {
+			return &rpc_response.ConfigPublishResponse{Response: &rpc_response.Response{ResultCode: 500,
+				ErrorCode: 500, Message: "cas publish fail, md5 mismatch"}}
+		}
+	}
+	s.SetConfig(publish.DataId, publish.Group, publish.Tenant, publish.Content)
+	go s.notify(publish.DataId, publish.Group, publish.Tenant)
+	return &rpc_response.ConfigPublishResponse{Response: fake_server.SuccessResponse()}
+}
+
+func (s *FakeConfigServer) handleRemove(request *fake_server.Request) rpc_response.IResponse {
+	var remove rpc_request.ConfigRemoveRequest
+	_ = request.Decode(&remove)
+	s.SetConfig(remove.DataId, remove.Group, remove.Tenant, "")
+	go s.notify(remove.DataId, remove.Group, remove.Tenant)
+	return &rpc_response.ConfigRemoveResponse{Response: fake_server.SuccessResponse()}
+}
+
+// handleBatchListen answers the configs whose md5 differs from the listened one.
+func (s *FakeConfigServer) handleBatchListen(request *fake_server.Request) rpc_response.IResponse {
+	var listen rpc_request.ConfigBatchListenRequest
+	_ = request.Decode(&listen)
+	response := &rpc_response.ConfigChangeBatchListenResponse{Response: fake_server.SuccessResponse()}
+	if !listen.Listen {
+		return response
+	}
+	for _, ctx := range listen.ConfigListenContexts {
+		item, ok := s.GetConfig(ctx.DataId, ctx.Group, ctx.Tenant)
+		md5 := item.Md5
+		if !ok {
+			md5 = util.Md5("")
+		}
+		if md5 != ctx.Md5 {
+			response.ChangedConfigs = append(response.ChangedConfigs,
+				model.ConfigContext{Group: ctx.Group, DataId: ctx.DataId, Tenant: ctx.Tenant})
+		}
+	}
+	return response
+}
+
+func (s *FakeConfigServer) handleSearch(request *fake_server.Request) rpc_response.IResponse {
+	var search rpc_request.ConfigSearchRequest
+	_ = request.Decode(&search)
+	match := func(pattern, value string) bool {
+		if pattern == "" {
+			return true
+		}
+		// config_client.SEARCH_BLUR, which isn't imported since the config client tests use this server.
+		if search.Search == "blur" {
+			matched, _ := path.Match(pattern, value)
+			return matched
+		}
+		return pattern == value
+	}
+
+	s.mux.Lock()
+	var items []model.ConfigItem
+	for _, item := range s.configs {
+		if item.Tenant == search.Tenant && match(search.DataId, item.DataId) && match(search.Group, item.Group) {
+			items = append(items, item)
+		}
+	}
+	s.mux.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		return util.GetConfigCacheKey(items[i].DataId, items[i].Group, "") < util.GetConfigCacheKey(items[j].DataId, items[j].Group, "")
+	})
+
+	page := model.ConfigPage{TotalCount: len(items), PageNumber: search.PageNo}
+	if search.PageSize > 0 {
+		page.PagesAvailable = (len(items) + search.PageSize - 1) / search.PageSize
+		start := (search.PageNo - 1) * search.PageSize
+		if start >= 0 && start < len(items) {
+			end := start + search.PageSize
+			if end > len(items) {
+				end = len(items)
+			}
+			page.PageItems = items[start:end]
+		}
+	}
+	return &rpc_response.ConfigSearchResponse{Response: fake_server.SuccessResponse(), Page: page}
+}
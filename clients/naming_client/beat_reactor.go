@@ -0,0 +1,202 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/cache"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+// ErrInstanceNotFound is returned by IBeatSender when the server doesn't know the instance, it is registered again.
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// IBeatSender sends the beat of the ephemeral instance, and returns the interval of the next beat in milliseconds
+// if the server gives one.
+type IBeatSender interface {
+	SendBeat(beatInfo model.BeatInfo) (int64, error)
+}
+
+// BeatReactor keeps the ephemeral instances alive by sending their beats periodically.
+type BeatReactor struct {
+	beatMap      cache.ConcurrentMap
+	beatInterval time.Duration
+	sender       IBeatSender
+	register     func(beatInfo model.BeatInfo) error
+	mux          sync.Mutex
+	wg           sync.WaitGroup
+}
+
+type beatTask struct {
+	info *model.BeatInfo
+	stop chan struct{}
+}
+
+// NewBeatReactor create the reactor sending the beats by the sender every beatInterval milliseconds unless the
+// server or the metadata "preserved.heart.beat.interval" gives another, 5000 is used if it's not positive.
+// The register is called to register the instance again when it's not found by the server.
+func NewBeatReactor(sender IBeatSender, beatInterval int64, register func(beatInfo model.BeatInfo) error) *BeatReactor {
+	if beatInterval <= 0 {
+		beatInterval = 5 * 1000
+	}
+	return &BeatReactor{
+		beatMap:      cache.NewConcurrentMap(),
+		beatInterval: time.Duration(beatInterval) * time.Millisecond,
+		sender:       sender,
+		register:     register,
+	}
+}
+
+func buildKey(serviceName string, ip string, port uint64) string {
+	return serviceName + constant.NAMING_INSTANCE_ID_SPLITTER + ip + constant.NAMING_INSTANCE_ID_SPLITTER +
+		strconv.FormatUint(port, 10)
+}
+
+// AddBeatInfo starts sending the beats of the instance, the beats of the same instance added before are stopped.
+// The serviceName is the grouped name of util.GetGroupName.
+func (br *BeatReactor) AddBeatInfo(serviceName string, beatInfo *model.BeatInfo) {
+	key := buildKey(serviceName, beatInfo.Ip, beatInfo.Port)
+	info := *beatInfo
+	info.ServiceName = serviceName
+	info.Metadata = copyMetadata(beatInfo.Metadata)
+	if info.Period <= 0 {
+		info.Period = br.periodOf(info.Metadata)
+	}
+	info.Scheduled = true
+	atomic.StoreInt32(&info.State, model.StateRunning)
+	task := &beatTask{info: &info, stop: make(chan struct{})}
+
+	br.mux.Lock()
+	defer br.mux.Unlock()
+	if v, ok := br.beatMap.Get(key); ok {
+		stopTask(v.(*beatTask))
+	}
+	br.beatMap.Set(key, task)
+	monitor.GetDom2BeatSizeMonitor().Set(float64(br.beatMap.Count()))
+	br.wg.Add(1)
+	go br.sendInstanceBeat(key, task)
+}
+
+// RemoveBeatInfo stops sending the beats of the instance.
+func (br *BeatReactor) RemoveBeatInfo(serviceName string, ip string, port uint64) {
+	key := buildKey(serviceName, ip, port)
+	br.mux.Lock()
+	defer br.mux.Unlock()
+	if v, ok := br.beatMap.Pop(key); ok {
+		stopTask(v.(*beatTask))
+		logger.Infof("remove beat of instance %s", key)
+	}
+	monitor.GetDom2BeatSizeMonitor().Set(float64(br.beatMap.Count()))
+}
+
+// GetBeatInfos returns the beats being sent.
+func (br *BeatReactor) GetBeatInfos() []model.BeatInfo {
+	var infos []model.BeatInfo
+	for _, v := range br.beatMap.Items() {
+		infos = append(infos, v.(*beatTask).beatInfo())
+	}
+	return infos
+}
+
+// Close stops all the beats and waits for them.
+func (br *BeatReactor) Close() {
+	br.mux.Lock()
+	for _, key := range br.beatMap.Keys() {
+		if v, ok := br.beatMap.Pop(key); ok {
+			stopTask(v.(*beatTask))
+		}
+	}
+	monitor.GetDom2BeatSizeMonitor().Set(0)
+	br.mux.Unlock()
+	br.wg.Wait()
+}
+
+func (br *BeatReactor) periodOf(metadata map[string]string) time.Duration {
+	if interval, err := strconv.ParseInt(metadata[constant.HEART_BEAT_INTERVAL], 10, 64); err == nil && interval > 0 {
+		return time.Duration(interval) * time.Millisecond
+	}
+	return br.beatInterval
+}
+
+func (br *BeatReactor) sendInstanceBeat(key string, task *beatTask) {
+	defer br.wg.Done()
+	period := task.info.Period
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-task.stop:
+			logger.Infof("instance %s stop heartbeating", key)
+			return
+		case <-timer.C:
+		}
+		beatInfo := task.beatInfo()
+		interval, err := br.sender.SendBeat(beatInfo)
+		switch {
+		case errors.Is(err, ErrInstanceNotFound):
+			logger.Warnf("instance %s is not found by the server, register it again", key)
+			if err = br.register(beatInfo); err != nil {
+				logger.Errorf("register instance %s again failed, err:%v", key, err)
+			}
+		case err != nil:
+			logger.Errorf("send beat of instance %s failed, err:%v", key, err)
+		case interval > 0:
+			period = time.Duration(interval) * time.Millisecond
+		}
+		timer.Reset(period)
+	}
+}
+
+// beatInfo copies the beat, the state is left out as it's changed by stopTask concurrently.
+func (t *beatTask) beatInfo() model.BeatInfo {
+	return model.BeatInfo{
+		Ip:          t.info.Ip,
+		Port:        t.info.Port,
+		Weight:      t.info.Weight,
+		ServiceName: t.info.ServiceName,
+		Cluster:     t.info.Cluster,
+		Metadata:    t.info.Metadata,
+		Scheduled:   t.info.Scheduled,
+		Period:      t.info.Period,
+	}
+}
+
+func stopTask(task *beatTask) {
+	if atomic.CompareAndSwapInt32(&task.info.State, model.StateRunning, model.StateShutdown) {
+		close(task.stop)
+	}
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+type fakeBeatSender struct {
+	mux      sync.Mutex
+	beats    []model.BeatInfo
+	interval int64
+	err      error
+}
+
+func (s *fakeBeatSender) SendBeat(beatInfo model.BeatInfo) (int64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.beats = append(s.beats, beatInfo)
+	return s.interval, s.err
+}
+
+func (s *fakeBeatSender) count() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.beats)
+}
+
+func (s *fakeBeatSender) setResult(interval int64, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.interval, s.err = interval, err
+}
+
+func TestBeatReactorSendsBeats(t *testing.T) {
+	sender := &fakeBeatSender{}
+	reactor := NewBeatReactor(sender, 20, func(model.BeatInfo) error { return nil })
+	defer reactor.Close()
+
+	reactor.AddBeatInfo("DEFAULT_GROUP@@demo", &model.BeatInfo{Ip: "10.0.0.1", Port: 80})
+	assert.Eventually(t, func() bool { return sender.count() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "DEFAULT_GROUP@@demo", sender.beats[0].ServiceName)
+	assert.True(t, sender.beats[0].Scheduled)
+
+	// the interval answered by the server is used for the next beats
+	sender.setResult(time.Hour.Milliseconds(), nil)
+	time.Sleep(50 * time.Millisecond)
+	count := sender.count()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, count, sender.count())
+}
+
+func TestBeatReactorStopsRemovedBeats(t *testing.T) {
+	sender := &fakeBeatSender{}
+	reactor := NewBeatReactor(sender, 10, func(model.BeatInfo) error { return nil })
+	defer reactor.Close()
+
+	reactor.AddBeatInfo("DEFAULT_GROUP@@demo", &model.BeatInfo{Ip: "10.0.0.1", Port: 80})
+	// the beat of the same instance replaces the one before
+	reactor.AddBeatInfo("DEFAULT_GROUP@@demo", &model.BeatInfo{Ip: "10.0.0.1", Port: 80,
+		Metadata: map[string]string{constant.HEART_BEAT_INTERVAL: "20"}})
+	infos := reactor.GetBeatInfos()
+	assert.Equal(t, 1, len(infos))
+	assert.Equal(t, 20*time.Millisecond, infos[0].Period)
+	assert.Eventually(t, func() bool { return sender.count() >= 2 }, time.Second, 5*time.Millisecond)
+
+	reactor.RemoveBeatInfo("DEFAULT_GROUP@@demo", "10.0.0.1", 80)
+	assert.Equal(t, 0, len(reactor.GetBeatInfos()))
+	time.Sleep(30 * time.Millisecond)
+	count := sender.count()
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, count, sender.count())
+}
+
+func TestBeatReactorRegistersMissingInstance(t *testing.T) {
+	sender := &fakeBeatSender{err: ErrInstanceNotFound}
+	var registered int32
+	reactor := NewBeatReactor(sender, 10, func(beatInfo model.BeatInfo) error {
+		assert.Equal(t, "10.0.0.1", beatInfo.Ip)
+		atomic.AddInt32(&registered, 1)
+		return nil
+	})
+	defer reactor.Close()
+
+	reactor.AddBeatInfo("DEFAULT_GROUP@@demo", &model.BeatInfo{Ip: "10.0.0.1", Port: 80})
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&registered) > 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestHttpBeatSender(t *testing.T) {
+	var code int32 = 10200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, constant.WEB_CONTEXT+constant.SERVICE_BEAT_PATH, r.URL.Path)
+		assert.Equal(t, "public", r.FormValue("namespaceId"))
+		assert.Equal(t, "DEFAULT_GROUP@@demo", r.FormValue("serviceName"))
+		assert.Contains(t, r.FormValue("beat"), "10.0.0.1")
+		_, _ = w.Write([]byte(`{"clientBeatInterval":3000,"code":` + strconv.Itoa(int(atomic.LoadInt32(&code))) + `}`))
+	}))
+	defer server.Close()
+	address := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")
+	port, _ := strconv.ParseUint(address[1], 10, 64)
+	xgrpcServer, err := xgrpc_server.NewXgrpcServer([]constant.ServerConfig{{Scheme: "http", IpAddr: address[0], Port: port}},
+		constant.ClientConfig{TimeoutMs: 3000}, &http_agent.HttpAgent{}, 3000, "")
+	assert.Nil(t, err)
+	sender := NewHttpBeatSender(xgrpcServer, "public")
+
+	interval, err := sender.SendBeat(model.BeatInfo{Ip: "10.0.0.1", Port: 80, ServiceName: "DEFAULT_GROUP@@demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3000), interval)
+
+	atomic.StoreInt32(&code, constant.RESOURCE_NOT_FOUND)
+	_, err = sender.SendBeat(model.BeatInfo{Ip: "10.0.0.1", Port: 80, ServiceName: "DEFAULT_GROUP@@demo"})
+	assert.Equal(t, ErrInstanceNotFound, err)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// GrpcBeatSender sends the beats by the InstanceBeatRequest over the rpc client of the naming module.
+type GrpcBeatSender struct {
+	rpcClientManager rpc_client.IRpcClientManager
+	rpcClient        *rpc.RpcClient
+	namespace        string
+	timeoutMs        uint64
+}
+
+func NewGrpcBeatSender(rpcClientManager rpc_client.IRpcClientManager, rpcClient *rpc.RpcClient, namespace string,
+	timeoutMs uint64) *GrpcBeatSender {
+	return &GrpcBeatSender{
+		rpcClientManager: rpcClientManager,
+		rpcClient:        rpcClient,
+		namespace:        namespace,
+		timeoutMs:        timeoutMs,
+	}
+}
+
+func (s *GrpcBeatSender) SendBeat(beatInfo model.BeatInfo) (int64, error) {
+	serviceName, groupName := util.ParseGroupedName(beatInfo.ServiceName)
+	response, err := s.rpcClientManager.Request(s.rpcClient, rpc_request.NewInstanceBeatRequest(s.namespace, serviceName,
+		groupName, beatInfo), s.timeoutMs)
+	if err != nil {
+		return 0, err
+	}
+	if !response.IsSuccess() {
+		if response.GetErrorCode() == constant.RESOURCE_NOT_FOUND {
+			return 0, ErrInstanceNotFound
+		}
+		return 0, errors.Errorf("send beat failed, errorCode:%d, message:%s", response.GetErrorCode(), response.GetMessage())
+	}
+	if beatResponse, ok := response.(*rpc_response.InstanceBeatResponse); ok {
+		return beatResponse.ClientBeatInterval, nil
+	}
+	return 0, nil
+}
+
+// HttpBeatSender sends the beats to the beat api of the servers in turn, for the servers without the grpc port.
+type HttpBeatSender struct {
+	xgrpcServer *xgrpc_server.XgrpcServer
+	namespace   string
+}
+
+func NewHttpBeatSender(xgrpcServer *xgrpc_server.XgrpcServer, namespace string) *HttpBeatSender {
+	return &HttpBeatSender{xgrpcServer: xgrpcServer, namespace: namespace}
+}
+
+type beatResult struct {
+	ClientBeatInterval int64 `json:"clientBeatInterval"`
+	Code               int   `json:"code"`
+}
+
+func (s *HttpBeatSender) SendBeat(beatInfo model.BeatInfo) (int64, error) {
+	server, err := s.xgrpcServer.GetNextServer()
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	if timeoutMs := s.xgrpcServer.GetClientConfig().TimeoutMs; timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	body, err := s.xgrpcServer.CallServerApi(ctx, server, http.MethodPut, constant.SERVICE_BEAT_PATH, map[string]string{
+		"namespaceId": s.namespace,
+		"serviceName": beatInfo.ServiceName,
+		"beat":        util.ToJsonString(beatInfo),
+	})
+	if httpErr, ok := http_agent.IsHttpError(err); ok && httpErr.StatusCode == http.StatusNotFound {
+		return 0, ErrInstanceNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	var result beatResult
+	if err = json.Unmarshal(body, &result); err != nil {
+		return 0, errors.Wrapf(err, "unmarshal beat result %s failed", string(body))
+	}
+	if result.Code == constant.RESOURCE_NOT_FOUND {
+		return 0, ErrInstanceNotFound
+	}
+	return result.ClientBeatInterval, nil
+}
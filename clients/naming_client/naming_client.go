@@ -0,0 +1,389 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/naming_client/selector"
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+const DEFAULT_CLUSTER = "DEFAULT"
+
+type NamingClient struct {
+	rpcClientManager  rpc_client.IRpcClientManager
+	rpcClient         *rpc.RpcClient
+	clientConfig      constant.ClientConfig
+	serviceInfoHolder *ServiceInfoHolder
+	selector          *selector.Selector
+	beatSender        IBeatSender
+	beatReactor       *BeatReactor
+}
+
+// NamingClientOption customizes the naming client created by NewNamingClient.
+type NamingClientOption func(client *NamingClient)
+
+// WithBeatSender replaces the GrpcBeatSender sending the beats of the ephemeral instances, e.g. by the HttpBeatSender.
+func WithBeatSender(sender IBeatSender) NamingClientOption {
+	return func(client *NamingClient) {
+		client.beatSender = sender
+	}
+}
+
+// NewNamingClient create the naming client, which sends the requests by the rpc client of the naming module.
+// The subscribed services are cached and updated by the NotifySubscriberRequest pushed by the server, and the beats
+// of the registered ephemeral instances are sent every BeatInterval of the client config until they're deregistered.
+// The ephemeral instances and the subscriptions are redone by the redo service of the rpc client after reconnecting.
+func NewNamingClient(rpcClientManager rpc_client.IRpcClientManager, clientConfig constant.ClientConfig,
+	opts ...NamingClientOption) (*NamingClient, error) {
+	if rpcClientManager == nil {
+		return nil, errors.New("rpcClientManager can not be nil")
+	}
+	serviceInfoHolder, err := NewServiceInfoHolder(clientConfig.NamespaceId, clientConfig.CacheDir,
+		clientConfig.DiskCacheCfg, clientConfig.UpdateCacheWhenEmpty, clientConfig.NotLoadCacheAtStart)
+	if err != nil {
+		return nil, err
+	}
+	client := &NamingClient{
+		rpcClientManager:  rpcClientManager,
+		clientConfig:      clientConfig,
+		serviceInfoHolder: serviceInfoHolder,
+		selector:          selector.NewSelector(selector.NewWeightedRandom()),
+	}
+	client.rpcClient = rpcClientManager.CreateRpcClient(constant.LABEL_MODULE_NAMING, map[string]string{
+		constant.LABEL_MODULE: constant.LABEL_MODULE_NAMING,
+	}, map[rpc.IServerRequestHandler]func() rpc_request.IRequest{
+		&NamingPushRequestHandler{ServiceInfoHolder: client.serviceInfoHolder}: func() rpc_request.IRequest {
+			return &rpc_request.NotifySubscriberRequest{NamingRequest: rpc_request.NewNamingRequest("", "", "")}
+		},
+	})
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.beatSender == nil {
+		client.beatSender = NewGrpcBeatSender(rpcClientManager, client.rpcClient, clientConfig.NamespaceId,
+			clientConfig.TimeoutMs)
+	}
+	client.beatReactor = NewBeatReactor(client.beatSender, clientConfig.BeatInterval, client.registerBeatInstance)
+	return client, nil
+}
+
+func (c *NamingClient) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	if param.ServiceName == "" || param.Ip == "" {
+		return false, errors.New("serviceName and ip can not be empty")
+	}
+	if param.Weight <= 0 {
+		return false, errors.New("weight must be larger than 0")
+	}
+	instance := model.Instance{
+		Ip:          param.Ip,
+		Port:        param.Port,
+		Weight:      param.Weight,
+		Enable:      param.Enable,
+		Healthy:     param.Healthy,
+		Ephemeral:   param.Ephemeral,
+		ClusterName: defaultIfEmpty(param.ClusterName, DEFAULT_CLUSTER),
+		ServiceName: param.ServiceName,
+		Metadata:    param.Metadata,
+	}
+	ok, err := c.requestInstance(param.ServiceName, param.GroupName, rpc_request.REGISTER_INSTANCE, instance)
+	if ok && instance.Ephemeral {
+		c.keepEphemeralInstance(param.GroupName, instance)
+	}
+	return ok, err
+}
+
+func (c *NamingClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	if param.ServiceName == "" || param.Ip == "" {
+		return false, errors.New("serviceName and ip can not be empty")
+	}
+	instance := model.Instance{
+		Ip:          param.Ip,
+		Port:        param.Port,
+		Ephemeral:   param.Ephemeral,
+		ClusterName: defaultIfEmpty(param.Cluster, DEFAULT_CLUSTER),
+		ServiceName: param.ServiceName,
+	}
+	// the beat and the redo of the instance are stopped regardless of Ephemeral, which is false if it's not set.
+	groupedName := util.GetGroupName(param.ServiceName, defaultIfEmpty(param.GroupName, constant.DEFAULT_GROUP))
+	c.beatReactor.RemoveBeatInfo(groupedName, param.Ip, param.Port)
+	c.rpcClient.GetRedoService().Remove(redoInstanceKey(groupedName, param.Ip, param.Port))
+	return c.requestInstance(param.ServiceName, param.GroupName, rpc_request.DEREGISTER_INSTANCE, instance)
+}
+
+func (c *NamingClient) UpdateInstance(param vo.UpdateInstanceParam) (bool, error) {
+	if param.ServiceName == "" || param.Ip == "" {
+		return false, errors.New("serviceName and ip can not be empty")
+	}
+	if param.Weight <= 0 {
+		return false, errors.New("weight must be larger than 0")
+	}
+	instance := model.Instance{
+		Ip:          param.Ip,
+		Port:        param.Port,
+		Weight:      param.Weight,
+		Enable:      param.Enable,
+		Healthy:     param.Healthy,
+		Ephemeral:   param.Ephemeral,
+		ClusterName: defaultIfEmpty(param.ClusterName, DEFAULT_CLUSTER),
+		ServiceName: param.ServiceName,
+		Metadata:    param.Metadata,
+	}
+	ok, err := c.requestInstance(param.ServiceName, param.GroupName, rpc_request.UPDATE_INSTANCE, instance)
+	if ok && instance.Ephemeral {
+		c.keepEphemeralInstance(param.GroupName, instance)
+	}
+	return ok, err
+}
+
+func (c *NamingClient) GetService(param vo.GetServiceParam) (model.Service, error) {
+	return c.queryService(param.ServiceName, param.GroupName, param.Clusters)
+}
+
+func (c *NamingClient) SelectAllInstances(param vo.SelectAllInstancesParam) ([]model.Instance, error) {
+	service, err := c.queryService(param.ServiceName, param.GroupName, param.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	return service.Hosts, nil
+}
+
+func (c *NamingClient) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	service, err := c.queryService(param.ServiceName, param.GroupName, param.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	return selectInstances(service, param.HealthyOnly), nil
+}
+
+func (c *NamingClient) SelectOneHealthyInstance(param vo.SelectOneHealthInstanceParam) (*model.Instance, error) {
+	service, err := c.queryService(param.ServiceName, param.GroupName, param.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := c.selector.Select(service, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "no healthy instance of service %s", param.ServiceName)
+	}
+	return instance, nil
+}
+
+// Subscribe subscribes the service and calls back with the current instances, the subscribers are called
+// again when the service pushed by the server changes.
+func (c *NamingClient) Subscribe(param *vo.SubscribeParam) error {
+	if param == nil || param.ServiceName == "" || (param.SubscribeCallback == nil && param.InstanceChangeCallback == nil) {
+		return errors.New("serviceName and subscribeCallback can not be empty")
+	}
+	groupName := defaultIfEmpty(param.GroupName, constant.DEFAULT_GROUP)
+	clusters := strings.Join(param.Clusters, ",")
+	key := util.GetServiceCacheKey(util.GetGroupName(param.ServiceName, groupName), clusters)
+
+	service, ok := c.serviceInfoHolder.GetServiceInfoByKey(key)
+	if !ok || !c.serviceInfoHolder.subCallback.isSubscribed(key) {
+		subscribe := func() error {
+			return c.subscribe(param.ServiceName, groupName, clusters)
+		}
+		if err := subscribe(); err != nil {
+			return err
+		}
+		c.rpcClient.GetRedoService().Register(redoSubscribeKey(key), subscribe)
+		service, _ = c.serviceInfoHolder.GetServiceInfoByKey(key)
+	}
+	c.serviceInfoHolder.subCallback.addCallback(key, param)
+	notifySubscriber(param, model.InstanceChangeEvent{ServiceName: param.ServiceName, GroupName: groupName,
+		Clusters: clusters, Hosts: service.Hosts, Added: service.Hosts})
+	return nil
+}
+
+// Unsubscribe removes the subscriber with the same callbacks, the service is unsubscribed from the server when
+// the last one is removed.
+func (c *NamingClient) Unsubscribe(param *vo.SubscribeParam) error {
+	if param == nil || param.ServiceName == "" {
+		return errors.New("serviceName can not be empty")
+	}
+	groupName := defaultIfEmpty(param.GroupName, constant.DEFAULT_GROUP)
+	clusters := strings.Join(param.Clusters, ",")
+	key := util.GetServiceCacheKey(util.GetGroupName(param.ServiceName, groupName), clusters)
+	if remaining, removed := c.serviceInfoHolder.subCallback.removeCallback(key, param); !removed || remaining > 0 {
+		return nil
+	}
+	c.rpcClient.GetRedoService().Remove(redoSubscribeKey(key))
+	_, err := c.request(rpc_request.NewSubscribeServiceRequest(c.clientConfig.NamespaceId, param.ServiceName,
+		groupName, clusters, false))
+	return err
+}
+
+func (c *NamingClient) GetAllServicesInfo(param vo.GetAllServiceInfoParam) (model.ServiceList, error) {
+	namespace := param.NameSpace
+	if namespace == "" {
+		namespace = c.clientConfig.NamespaceId
+	}
+	pageNo, pageSize := int(param.PageNo), int(param.PageSize)
+	if pageNo == 0 {
+		pageNo = 1
+	}
+	if pageSize == 0 {
+		pageSize = 10
+	}
+	response, err := c.request(rpc_request.NewServiceListRequest(namespace, defaultIfEmpty(param.GroupName, constant.DEFAULT_GROUP),
+		pageNo, pageSize))
+	if err != nil {
+		return model.ServiceList{}, err
+	}
+	serviceListResponse := response.(*rpc_response.ServiceListResponse)
+	return model.ServiceList{Count: int64(serviceListResponse.Count), Doms: serviceListResponse.ServiceNames}, nil
+}
+
+func (c *NamingClient) CloseClient() {
+	c.beatReactor.Close()
+	c.rpcClientManager.Close(c.rpcClient)
+}
+
+func (c *NamingClient) requestInstance(serviceName, groupName, requestType string, instance model.Instance) (bool, error) {
+	_, err := c.request(rpc_request.NewInstanceRequest(c.clientConfig.NamespaceId, serviceName,
+		defaultIfEmpty(groupName, constant.DEFAULT_GROUP), requestType, instance))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// keepEphemeralInstance sends the beats of the registered ephemeral instance, and registers it again after
+// reconnecting as the server removes it with the connection.
+func (c *NamingClient) keepEphemeralInstance(groupName string, instance model.Instance) {
+	groupName = defaultIfEmpty(groupName, constant.DEFAULT_GROUP)
+	groupedName := util.GetGroupName(instance.ServiceName, groupName)
+	c.beatReactor.AddBeatInfo(groupedName, buildBeatInfo(instance))
+	c.rpcClient.GetRedoService().Register(redoInstanceKey(groupedName, instance.Ip, instance.Port), func() error {
+		_, err := c.requestInstance(instance.ServiceName, groupName, rpc_request.REGISTER_INSTANCE, instance)
+		return err
+	})
+}
+
+// subscribe subscribes the service from the server and caches it.
+func (c *NamingClient) subscribe(serviceName, groupName, clusters string) error {
+	response, err := c.request(rpc_request.NewSubscribeServiceRequest(c.clientConfig.NamespaceId, serviceName,
+		groupName, clusters, true))
+	if err != nil {
+		return err
+	}
+	service := response.(*rpc_response.SubscribeServiceResponse).ServiceInfo
+	service.Name, service.GroupName, service.Clusters = serviceName, groupName, clusters
+	c.serviceInfoHolder.ProcessService(&service)
+	return nil
+}
+
+// registerBeatInstance registers the instance of the beat again, the instance is removed by the server when the
+// beats are lost for a while, e.g. during the disconnection.
+func (c *NamingClient) registerBeatInstance(beatInfo model.BeatInfo) error {
+	serviceName, groupName := util.ParseGroupedName(beatInfo.ServiceName)
+	_, err := c.requestInstance(serviceName, groupName, rpc_request.REGISTER_INSTANCE, model.Instance{
+		Ip:          beatInfo.Ip,
+		Port:        beatInfo.Port,
+		Weight:      beatInfo.Weight,
+		Enable:      true,
+		Healthy:     true,
+		Ephemeral:   true,
+		ClusterName: beatInfo.Cluster,
+		ServiceName: serviceName,
+		Metadata:    beatInfo.Metadata,
+	})
+	return err
+}
+
+func (c *NamingClient) queryService(serviceName, groupName string, clusters []string) (model.Service, error) {
+	if serviceName == "" {
+		return model.Service{}, errors.New("serviceName can not be empty")
+	}
+	groupName = defaultIfEmpty(groupName, constant.DEFAULT_GROUP)
+	key := util.GetServiceCacheKey(util.GetGroupName(serviceName, groupName), strings.Join(clusters, ","))
+	cached, ok := c.serviceInfoHolder.GetServiceInfoByKey(key)
+	if ok && c.serviceInfoHolder.subCallback.isSubscribed(key) {
+		return cached, nil
+	}
+	response, err := c.request(rpc_request.NewServiceQueryRequest(c.clientConfig.NamespaceId, serviceName,
+		groupName, strings.Join(clusters, ","), false))
+	if err != nil {
+		if ok {
+			logger.Warnf("query service %s failed, the cached one is returned, err:%v", key, err)
+			return cached, nil
+		}
+		return model.Service{}, err
+	}
+	return response.(*rpc_response.QueryServiceResponse).ServiceInfo, nil
+}
+
+func (c *NamingClient) request(request rpc_request.IRequest) (rpc_response.IResponse, error) {
+	response, err := c.rpcClientManager.Request(c.rpcClient, request, c.clientConfig.TimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsSuccess() {
+		logger.Warnf("naming request %s failed, errorCode:%d, message:%s", request.GetRequestType(),
+			response.GetErrorCode(), response.GetMessage())
+		return nil, errors.Errorf("request %s failed, errorCode:%d, message:%s", request.GetRequestType(),
+			response.GetErrorCode(), response.GetMessage())
+	}
+	return response, nil
+}
+
+// selectInstances returns the enabled instances of the health with the positive weight.
+func selectInstances(service model.Service, healthy bool) []model.Instance {
+	var result []model.Instance
+	for _, host := range service.Hosts {
+		if host.Healthy == healthy && host.Enable && host.Weight > 0 {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+func redoInstanceKey(groupedName string, ip string, port uint64) string {
+	return "naming:instance:" + buildKey(groupedName, ip, port)
+}
+
+func redoSubscribeKey(serviceKey string) string {
+	return "naming:subscribe:" + serviceKey
+}
+
+func buildBeatInfo(instance model.Instance) *model.BeatInfo {
+	return &model.BeatInfo{
+		Ip:       instance.Ip,
+		Port:     instance.Port,
+		Weight:   instance.Weight,
+		Cluster:  instance.ClusterName,
+		Metadata: instance.Metadata,
+	}
+}
+
+func defaultIfEmpty(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
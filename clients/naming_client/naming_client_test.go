@@ -0,0 +1,375 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/fake_server"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+// fakeNamingServer keeps the instances of the services registered to the fake server.
+type fakeNamingServer struct {
+	*fake_server.FakeServer
+	mux       sync.Mutex
+	instances map[string][]model.Instance
+}
+
+func newFakeNamingServer(t *testing.T) *fakeNamingServer {
+	server, err := fake_server.NewFakeServer()
+	assert.Nil(t, err)
+	s := &fakeNamingServer{FakeServer: server, instances: map[string][]model.Instance{}}
+	server.Handle("InstanceRequest", s.handleInstance)
+	server.Handle("ServiceQueryRequest", func(request *fake_server.Request) rpc_response.IResponse {
+		var query rpc_request.ServiceQueryRequest
+		_ = request.Decode(&query)
+		return &rpc_response.QueryServiceResponse{Response: fake_server.SuccessResponse(),
+			ServiceInfo: s.service(query.ServiceName, query.GroupName)}
+	})
+	server.Handle("SubscribeServiceRequest", func(request *fake_server.Request) rpc_response.IResponse {
+		var subscribe rpc_request.SubscribeServiceRequest
+		_ = request.Decode(&subscribe)
+		return &rpc_response.SubscribeServiceResponse{Response: fake_server.SuccessResponse(),
+			ServiceInfo: s.service(subscribe.ServiceName, subscribe.GroupName)}
+	})
+	server.Handle("InstanceBeatRequest", func(request *fake_server.Request) rpc_response.IResponse {
+		var beat rpc_request.InstanceBeatRequest
+		_ = request.Decode(&beat)
+		for _, instance := range s.service(beat.ServiceName, beat.GroupName).Hosts {
+			if instance.Ip == beat.BeatInfo.Ip && instance.Port == beat.BeatInfo.Port {
+				return &rpc_response.InstanceBeatResponse{Response: fake_server.SuccessResponse()}
+			}
+		}
+		return &rpc_response.InstanceBeatResponse{Response: &rpc_response.Response{ResultCode: 500,
+			ErrorCode: constant.RESOURCE_NOT_FOUND, Message: "instance not found"}}
+	})
+	server.Handle("ServiceListRequest", func(request *fake_server.Request) rpc_response.IResponse {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		var names []string
+		for name := range s.instances {
+			names = append(names, name[strings.Index(name, constant.SERVICE_INFO_SPLITER)+len(constant.SERVICE_INFO_SPLITER):])
+		}
+		return &rpc_response.ServiceListResponse{Response: fake_server.SuccessResponse(), Count: len(names), ServiceNames: names}
+	})
+	return s
+}
+
+func (s *fakeNamingServer) handleInstance(request *fake_server.Request) rpc_response.IResponse {
+	var instanceRequest rpc_request.InstanceRequest
+	_ = request.Decode(&instanceRequest)
+	key := util.GetGroupName(instanceRequest.ServiceName, instanceRequest.GroupName)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var instances []model.Instance
+	for _, instance := range s.instances[key] {
+		if instance.Ip != instanceRequest.Instance.Ip || instance.Port != instanceRequest.Instance.Port {
+			instances = append(instances, instance)
+		}
+	}
+	if instanceRequest.Type != rpc_request.DEREGISTER_INSTANCE {
+		instances = append(instances, instanceRequest.Instance)
+	}
+	s.instances[key] = instances
+	return &rpc_response.InstanceResponse{Response: fake_server.SuccessResponse()}
+}
+
+// clear removes all the instances, like the server does with the instances of the lost connections.
+func (s *fakeNamingServer) clear() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.instances = map[string][]model.Instance{}
+}
+
+func (s *fakeNamingServer) service(serviceName, groupName string) model.Service {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return model.Service{Name: serviceName, GroupName: groupName, Hosts: s.instances[util.GetGroupName(serviceName, groupName)]}
+}
+
+func newTestNamingClient(t *testing.T, server *fakeNamingServer) *NamingClient {
+	clientConfig := constant.ClientConfig{TimeoutMs: 3000, NamespaceId: "public", CacheDir: t.TempDir()}
+	manager, err := rpc_client.NewRpcClientManager([]constant.ServerConfig{server.ServerConfig()}, clientConfig, &http_agent.HttpAgent{})
+	assert.Nil(t, err)
+	client, err := NewNamingClient(manager, clientConfig)
+	assert.Nil(t, err)
+	return client
+}
+
+func TestRegisterAndSelectInstances(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	ok, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: true, Healthy: true, Ephemeral: true})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.2", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: true, Healthy: false, Ephemeral: true})
+	assert.Nil(t, err)
+
+	requests := server.GetRequests("InstanceRequest")
+	assert.Equal(t, 2, len(requests))
+	var instanceRequest rpc_request.InstanceRequest
+	assert.Nil(t, requests[0].Decode(&instanceRequest))
+	assert.Equal(t, "public", instanceRequest.Namespace)
+	assert.Equal(t, constant.DEFAULT_GROUP, instanceRequest.GroupName)
+	assert.Equal(t, rpc_request.REGISTER_INSTANCE, instanceRequest.Type)
+	assert.Equal(t, DEFAULT_CLUSTER, instanceRequest.Instance.ClusterName)
+
+	instances, err := client.SelectAllInstances(vo.SelectAllInstancesParam{ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(instances))
+	instances, err = client.SelectInstances(vo.SelectInstancesParam{ServiceName: "demo", HealthyOnly: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(instances))
+	assert.Equal(t, "10.0.0.1", instances[0].Ip)
+	instances, err = client.SelectInstances(vo.SelectInstancesParam{ServiceName: "demo", HealthyOnly: false})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(instances))
+	assert.Equal(t, "10.0.0.2", instances[0].Ip)
+
+	instance, err := client.SelectOneHealthyInstance(vo.SelectOneHealthInstanceParam{ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", instance.Ip)
+
+	_, err = client.UpdateInstance(vo.UpdateInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: false, Healthy: true, Ephemeral: true})
+	assert.Nil(t, err)
+	_, err = client.SelectOneHealthyInstance(vo.SelectOneHealthInstanceParam{ServiceName: "demo"})
+	assert.NotNil(t, err)
+
+	ok, err = client.DeregisterInstance(vo.DeregisterInstanceParam{Ip: "10.0.0.2", Port: 80, ServiceName: "demo", Ephemeral: true})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	service, err := client.GetService(vo.GetServiceParam{ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(service.Hosts))
+
+	serviceList, err := client.GetAllServicesInfo(vo.GetAllServiceInfoParam{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), serviceList.Count)
+	assert.Equal(t, []string{"demo"}, serviceList.Doms)
+}
+
+func TestRegisterInstanceValidation(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	_, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo"})
+	assert.NotNil(t, err)
+	_, err = client.RegisterInstance(vo.RegisterInstanceParam{Port: 80, ServiceName: "demo", Weight: 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(server.GetRequests("InstanceRequest")))
+}
+
+func TestSubscribeAndUnsubscribe(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+	_, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Weight: 1, Enable: true, Healthy: true, Ephemeral: true})
+	assert.Nil(t, err)
+
+	var received []model.Instance
+	first := &vo.SubscribeParam{ServiceName: "demo", SubscribeCallback: func(services []model.Instance, err error) {
+		received = services
+	}}
+	second := &vo.SubscribeParam{ServiceName: "demo", SubscribeCallback: func(services []model.Instance, err error) {}}
+	assert.Nil(t, client.Subscribe(first))
+	assert.Nil(t, client.Subscribe(second))
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, "10.0.0.1", received[0].Ip)
+	assert.NotNil(t, client.Subscribe(&vo.SubscribeParam{ServiceName: "demo"}))
+
+	// the second subscriber gets the cached service.
+	assert.Equal(t, 1, len(server.GetRequests("SubscribeServiceRequest")))
+
+	// an unknown subscriber doesn't unsubscribe the service.
+	assert.Nil(t, client.Unsubscribe(&vo.SubscribeParam{ServiceName: "demo",
+		SubscribeCallback: func(services []model.Instance, err error) {}}))
+	assert.Equal(t, 1, len(server.GetRequests("SubscribeServiceRequest")))
+
+	// the subscriber is matched by the callback.
+	assert.Nil(t, client.Unsubscribe(&vo.SubscribeParam{ServiceName: "demo", SubscribeCallback: first.SubscribeCallback}))
+	assert.Equal(t, 1, len(server.GetRequests("SubscribeServiceRequest")))
+	assert.Nil(t, client.Unsubscribe(second))
+	requests := server.GetRequests("SubscribeServiceRequest")
+	assert.Equal(t, 2, len(requests))
+	var subscribeRequest rpc_request.SubscribeServiceRequest
+	assert.Nil(t, requests[1].Decode(&subscribeRequest))
+	assert.False(t, subscribeRequest.Subscribe)
+
+	assert.Nil(t, client.Unsubscribe(second))
+	assert.Equal(t, 2, len(server.GetRequests("SubscribeServiceRequest")))
+}
+
+func TestRequestFailure(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	server.Handle("ServiceQueryRequest", func(request *fake_server.Request) rpc_response.IResponse {
+		return &rpc_response.QueryServiceResponse{Response: &rpc_response.Response{ResultCode: 500, ErrorCode: 403, Message: "forbidden"}}
+	})
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	_, err := client.GetService(vo.GetServiceParam{ServiceName: "demo"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "forbidden")
+}
+
+func TestPushUpdatesSubscribedService(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+	first := model.Instance{Ip: "10.0.0.1", Port: 80, Weight: 1, Enable: true, Healthy: true, ClusterName: DEFAULT_CLUSTER}
+	_, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: first.Ip, Port: first.Port, ServiceName: "demo",
+		Weight: first.Weight, Enable: true, Healthy: true})
+	assert.Nil(t, err)
+
+	events := make(chan model.InstanceChangeEvent, 8)
+	assert.Nil(t, client.Subscribe(&vo.SubscribeParam{ServiceName: "demo", InstanceChangeCallback: func(event model.InstanceChangeEvent) {
+		events <- event
+	}}))
+	event := <-events
+	assert.Equal(t, 1, len(event.Added))
+
+	second := model.Instance{Ip: "10.0.0.2", Port: 80, Weight: 1, Enable: true, Healthy: true, ClusterName: DEFAULT_CLUSTER}
+	modified := first
+	modified.Weight = 5
+	push := func(hosts ...model.Instance) {
+		_, err := server.Push(rpc_request.NewNotifySubscriberRequest(model.Service{Name: "demo",
+			GroupName: constant.DEFAULT_GROUP, Hosts: hosts}), 3*time.Second)
+		assert.Nil(t, err)
+	}
+	push(modified, second)
+	event = <-events
+	assert.Equal(t, []model.Instance{second}, event.Added)
+	assert.Equal(t, []model.Instance{modified}, event.Modified)
+	assert.Empty(t, event.Removed)
+	assert.Equal(t, 2, len(event.Hosts))
+
+	push(second)
+	event = <-events
+	assert.Equal(t, []model.Instance{modified}, event.Removed)
+
+	// the subscribed service is read from the cache.
+	instances, err := client.SelectAllInstances(vo.SelectAllInstancesParam{ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Instance{second}, instances)
+	assert.Equal(t, 0, len(server.GetRequests("ServiceQueryRequest")))
+
+	// the empty service is ignored without UpdateCacheWhenEmpty.
+	push()
+	instances, err = client.SelectAllInstances(vo.SelectAllInstancesParam{ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(instances))
+}
+
+func TestEphemeralInstanceBeats(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	_, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: true, Healthy: true, Ephemeral: true, Metadata: map[string]string{constant.HEART_BEAT_INTERVAL: "50"}})
+	assert.Nil(t, err)
+	_, err = client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.2", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: true, Healthy: true, Ephemeral: false})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return len(server.GetRequests("InstanceBeatRequest")) >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+	var beat rpc_request.InstanceBeatRequest
+	assert.Nil(t, server.GetRequests("InstanceBeatRequest")[0].Decode(&beat))
+	assert.Equal(t, "demo", beat.ServiceName)
+	assert.Equal(t, constant.DEFAULT_GROUP, beat.GroupName)
+	assert.Equal(t, "10.0.0.1", beat.BeatInfo.Ip)
+	assert.Equal(t, 1, len(client.beatReactor.GetBeatInfos()))
+
+	// the instances are registered again after reconnecting
+	server.clear()
+	server.ResetConnections()
+	assert.Eventually(t, func() bool {
+		return len(server.service("demo", constant.DEFAULT_GROUP).Hosts) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = client.DeregisterInstance(vo.DeregisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Ephemeral: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(client.beatReactor.GetBeatInfos()))
+}
+
+func TestDeregisterInstanceWithoutEphemeral(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	_, err := client.RegisterInstance(vo.RegisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo",
+		Weight: 10, Enable: true, Healthy: true, Ephemeral: true})
+	assert.Nil(t, err)
+	redoKey := redoInstanceKey(util.GetGroupName("demo", constant.DEFAULT_GROUP), "10.0.0.1", 80)
+	assert.Equal(t, 1, len(client.beatReactor.GetBeatInfos()))
+	assert.True(t, client.rpcClient.GetRedoService().IsRegistered(redoKey))
+
+	// Ephemeral isn't set.
+	_, err = client.DeregisterInstance(vo.DeregisterInstanceParam{Ip: "10.0.0.1", Port: 80, ServiceName: "demo"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(client.beatReactor.GetBeatInfos()))
+	assert.False(t, client.rpcClient.GetRedoService().IsRegistered(redoKey))
+}
+
+func TestSubscribeAfterReconnect(t *testing.T) {
+	server := newFakeNamingServer(t)
+	defer server.Stop()
+	client := newTestNamingClient(t, server)
+	defer client.CloseClient()
+
+	param := &vo.SubscribeParam{ServiceName: "demo", SubscribeCallback: func([]model.Instance, error) {}}
+	assert.Nil(t, client.Subscribe(param))
+	assert.Equal(t, 1, len(server.GetRequests("SubscribeServiceRequest")))
+
+	server.ResetConnections()
+	assert.Eventually(t, func() bool {
+		return len(server.GetRequests("SubscribeServiceRequest")) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the unsubscribed service is not subscribed again
+	assert.Nil(t, client.Unsubscribe(param))
+	assert.False(t, client.rpcClient.GetRedoService().IsRegistered(redoSubscribeKey("DEFAULT_GROUP@@demo")))
+}
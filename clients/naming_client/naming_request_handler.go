@@ -0,0 +1,47 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+// NamingPushRequestHandler updates the cached service with the NotifySubscriberRequest pushed by the server.
+type NamingPushRequestHandler struct {
+	ServiceInfoHolder *ServiceInfoHolder
+}
+
+func (c *NamingPushRequestHandler) Name() string {
+	return "NamingPushRequestHandler"
+}
+
+func (c *NamingPushRequestHandler) RequestReply(request rpc_request.IRequest, rpcClient *rpc.RpcClient) rpc_response.IResponse {
+	notifySubscriberRequest, ok := request.(*rpc_request.NotifySubscriberRequest)
+	if !ok {
+		return nil
+	}
+	logger.Infof("%s receive service change of %s@@%s", rpcClient.Name, notifySubscriberRequest.ServiceInfo.GroupName,
+		notifySubscriberRequest.ServiceInfo.Name)
+	c.ServiceInfoHolder.ProcessService(&notifySubscriberRequest.ServiceInfo)
+	return &rpc_response.NotifySubscriberResponse{
+		Response: &rpc_response.Response{ResultCode: constant.RESPONSE_CODE_SUCCESS, Success: true},
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selector
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+// Balancer picks one of the candidates, nil is returned if there is none.
+type Balancer interface {
+	Pick(instances []model.Instance, key string) *model.Instance
+}
+
+// InstanceKey identifies the instance by the InstanceId, or by the ip, port and cluster if the id is empty.
+func InstanceKey(instance model.Instance) string {
+	if instance.InstanceId != "" {
+		return instance.InstanceId
+	}
+	return instance.Ip + constant.NAMING_INSTANCE_ID_SPLITTER + strconv.FormatUint(instance.Port, 10) +
+		constant.NAMING_INSTANCE_ID_SPLITTER + instance.ClusterName
+}
+
+type weightedRandom struct {
+	mux  sync.Mutex
+	rand *rand.Rand
+}
+
+// NewWeightedRandom picks the instances randomly in proportion to their weights.
+func NewWeightedRandom() Balancer {
+	return &weightedRandom{rand: rand.New(rand.NewSource(rand.Int63()))}
+}
+
+func (b *weightedRandom) Pick(instances []model.Instance, key string) *model.Instance {
+	var total float64
+	for _, instance := range instances {
+		total += instance.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	b.mux.Lock()
+	r := b.rand.Float64() * total
+	b.mux.Unlock()
+	for i := range instances {
+		r -= instances[i].Weight
+		if r < 0 {
+			return &instances[i]
+		}
+	}
+	return &instances[len(instances)-1]
+}
+
+type smoothWeightedRoundRobin struct {
+	mux            sync.Mutex
+	currentWeights map[string]float64
+}
+
+// NewSmoothWeightedRoundRobin picks the instances in turn in proportion to their weights, the picks of an instance
+// are spread evenly instead of in a burst, like the smooth weighted round-robin of nginx.
+func NewSmoothWeightedRoundRobin() Balancer {
+	return &smoothWeightedRoundRobin{currentWeights: map[string]float64{}}
+}
+
+func (b *smoothWeightedRoundRobin) Pick(instances []model.Instance, key string) *model.Instance {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	var (
+		total    float64
+		selected = -1
+		present  = make(map[string]struct{}, len(instances))
+	)
+	for i, instance := range instances {
+		if instance.Weight <= 0 {
+			continue
+		}
+		instanceKey := InstanceKey(instance)
+		present[instanceKey] = struct{}{}
+		b.currentWeights[instanceKey] += instance.Weight
+		total += instance.Weight
+		if selected < 0 || b.currentWeights[instanceKey] > b.currentWeights[InstanceKey(instances[selected])] {
+			selected = i
+		}
+	}
+	// forget the instances which are gone, they start over when they come back.
+	for instanceKey := range b.currentWeights {
+		if _, ok := present[instanceKey]; !ok {
+			delete(b.currentWeights, instanceKey)
+		}
+	}
+	if selected < 0 {
+		return nil
+	}
+	b.currentWeights[InstanceKey(instances[selected])] -= total
+	return &instances[selected]
+}
+
+// DefaultReplicas is the number of the virtual nodes of an instance in the hash ring.
+const DefaultReplicas = 160
+
+type consistentHash struct {
+	replicas int
+	mux      sync.Mutex
+	ringKey  string
+	ring     []uint32
+	nodes    map[uint32]string
+}
+
+// NewConsistentHash picks the instance by the hash of the key, the same key is mapped to the same instance and
+// only the keys of the changed instances are remapped when the instances change. The ring has the replicas
+// virtual nodes per instance, DefaultReplicas is used if it is not positive.
+func NewConsistentHash(replicas int) Balancer {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &consistentHash{replicas: replicas}
+}
+
+func (b *consistentHash) Pick(instances []model.Instance, key string) *model.Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+	byKey := make(map[string]int, len(instances))
+	keys := make([]string, 0, len(instances))
+	for i, instance := range instances {
+		instanceKey := InstanceKey(instance)
+		byKey[instanceKey] = i
+		keys = append(keys, instanceKey)
+	}
+	sort.Strings(keys)
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if ringKey := strings.Join(keys, ","); ringKey != b.ringKey {
+		b.build(keys)
+		b.ringKey = ringKey
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i] >= hash })
+	if i == len(b.ring) {
+		i = 0
+	}
+	return &instances[byKey[b.nodes[b.ring[i]]]]
+}
+
+// build rebuilds the ring of the instances, it is only called when the instances change.
+func (b *consistentHash) build(keys []string) {
+	b.ring = make([]uint32, 0, len(keys)*b.replicas)
+	b.nodes = make(map[uint32]string, len(keys)*b.replicas)
+	for _, instanceKey := range keys {
+		for i := 0; i < b.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + constant.NAMING_INSTANCE_ID_SPLITTER + instanceKey))
+			if _, ok := b.nodes[hash]; ok {
+				continue
+			}
+			b.nodes[hash] = instanceKey
+			b.ring = append(b.ring, hash)
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i] < b.ring[j] })
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selector
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+const (
+	LABEL_ZONE          = "zone"
+	SELECTOR_TYPE_NONE  = "none"
+	SELECTOR_TYPE_LABEL = "label"
+)
+
+// Filter returns the candidates left from the instances.
+type Filter func(instances []model.Instance) []model.Instance
+
+func filterBy(instances []model.Instance, match func(instance model.Instance) bool) []model.Instance {
+	var result []model.Instance
+	for _, instance := range instances {
+		if match(instance) {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+func matchLabels(instance model.Instance, labels map[string]string) bool {
+	for k, v := range labels {
+		if value, ok := instance.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// LabelFilter keeps the instances whose metadata have all the labels.
+func LabelFilter(labels map[string]string) Filter {
+	return func(instances []model.Instance) []model.Instance {
+		return filterBy(instances, func(instance model.Instance) bool {
+			return matchLabels(instance, labels)
+		})
+	}
+}
+
+// ClusterFilter keeps the instances of the clusters.
+func ClusterFilter(clusters ...string) Filter {
+	return func(instances []model.Instance) []model.Instance {
+		return filterBy(instances, func(instance model.Instance) bool {
+			for _, cluster := range clusters {
+				if instance.ClusterName == cluster {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// MetadataAffinity prefers the instances whose metadata have all the labels,
+// the instances are kept as they are if none of them matches.
+func MetadataAffinity(labels map[string]string) Filter {
+	return func(instances []model.Instance) []model.Instance {
+		if preferred := LabelFilter(labels)(instances); len(preferred) > 0 {
+			return preferred
+		}
+		return instances
+	}
+}
+
+// ZoneAffinity prefers the instances in the zone, which is the "zone" of the metadata.
+func ZoneAffinity(zone string) Filter {
+	if zone == "" {
+		return func(instances []model.Instance) []model.Instance { return instances }
+	}
+	return MetadataAffinity(map[string]string{LABEL_ZONE: zone})
+}
+
+// ExpressionFilter keeps the instances matching the selector. The "label" expression is a comma separated list
+// of the requirements on the metadata, all of which must be met. A requirement is one of key=value, key==value,
+// key!=value, key in (v1,v2), key notin (v1,v2), key (exists) and !key (not exists).
+// The "none" selector keeps all instances.
+func ExpressionFilter(selector model.ExpressionSelector) (Filter, error) {
+	switch selector.Type {
+	case "", SELECTOR_TYPE_NONE:
+		return func(instances []model.Instance) []model.Instance { return instances }, nil
+	case SELECTOR_TYPE_LABEL:
+	default:
+		return nil, errors.Errorf("unsupported selector type %s", selector.Type)
+	}
+	requirements, err := parseExpression(selector.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(instances []model.Instance) []model.Instance {
+		return filterBy(instances, func(instance model.Instance) bool {
+			for _, requirement := range requirements {
+				if !requirement(instance.Metadata) {
+					return false
+				}
+			}
+			return true
+		})
+	}, nil
+}
+
+type requirement func(metadata map[string]string) bool
+
+func parseExpression(expression string) ([]requirement, error) {
+	var requirements []requirement
+	for _, term := range splitTerms(expression) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+// splitTerms splits the expression by the commas out of the parentheses.
+func splitTerms(expression string) []string {
+	var (
+		terms []string
+		depth int
+		start int
+	)
+	for i, c := range expression {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, expression[start:])
+}
+
+func parseRequirement(term string) (requirement, error) {
+	if i := strings.Index(term, "!="); i >= 0 {
+		key, value := strings.TrimSpace(term[:i]), strings.TrimSpace(term[i+2:])
+		return func(metadata map[string]string) bool { return metadata[key] != value }, checkKey(key, term)
+	}
+	if i := strings.Index(term, "="); i >= 0 {
+		key, value := strings.TrimSpace(term[:i]), strings.TrimSpace(strings.TrimPrefix(term[i+1:], "="))
+		return func(metadata map[string]string) bool {
+			v, ok := metadata[key]
+			return ok && v == value
+		}, checkKey(key, term)
+	}
+	if fields := strings.Fields(term); len(fields) >= 2 && (fields[1] == "in" || fields[1] == "notin") {
+		key := fields[0]
+		set := strings.TrimSpace(strings.TrimPrefix(term, key))
+		set = strings.TrimSpace(strings.TrimPrefix(set, fields[1]))
+		if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+			return nil, errors.Errorf("invalid set of the requirement %s", term)
+		}
+		values := map[string]struct{}{}
+		for _, value := range strings.Split(set[1:len(set)-1], ",") {
+			values[strings.TrimSpace(value)] = struct{}{}
+		}
+		in := fields[1] == "in"
+		return func(metadata map[string]string) bool {
+			v, ok := metadata[key]
+			if !ok {
+				return !in
+			}
+			_, contains := values[v]
+			return contains == in
+		}, nil
+	}
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		return func(metadata map[string]string) bool {
+			_, ok := metadata[key]
+			return !ok
+		}, checkKey(key, term)
+	}
+	if strings.ContainsAny(term, " ()") {
+		return nil, errors.Errorf("invalid requirement %s", term)
+	}
+	return func(metadata map[string]string) bool {
+		_, ok := metadata[term]
+		return ok
+	}, nil
+}
+
+func checkKey(key string, term string) error {
+	if key == "" || strings.ContainsAny(key, " ()!") {
+		return errors.Errorf("invalid key of the requirement %s", term)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selector
+
+import (
+	"errors"
+
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+// ErrNoInstance is returned when no instance is left after filtering.
+var ErrNoInstance = errors.New("no available instance")
+
+// Selector selects an instance of the service, the candidates are filtered by the health, the filters and then
+// balanced by the Balancer.
+type Selector struct {
+	balancer         Balancer
+	filters          []Filter
+	protectThreshold float64
+}
+
+type Option func(s *Selector)
+
+// WithFilters appends the filters, which are applied in order.
+func WithFilters(filters ...Filter) Option {
+	return func(s *Selector) {
+		s.filters = append(s.filters, filters...)
+	}
+}
+
+// WithProtectThreshold selects among the unhealthy instances too when the ratio of the healthy ones is not larger
+// than the threshold, so that the few healthy ones are not overwhelmed. The default is 0, which disables it.
+func WithProtectThreshold(threshold float64) Option {
+	return func(s *Selector) {
+		s.protectThreshold = threshold
+	}
+}
+
+func NewSelector(balancer Balancer, opts ...Option) *Selector {
+	s := &Selector{balancer: balancer}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Select returns one of the candidates, the key is used by the balancers like ConsistentHash.
+func (s *Selector) Select(service model.Service, key string) (*model.Instance, error) {
+	candidates := s.Candidates(service)
+	if len(candidates) == 0 {
+		return nil, ErrNoInstance
+	}
+	instance := s.balancer.Pick(candidates, key)
+	if instance == nil {
+		return nil, ErrNoInstance
+	}
+	return instance, nil
+}
+
+// Candidates returns the enabled instances with the positive weight after the filters. They are the healthy ones
+// unless the service reaches the protection threshold, which is either marked by the server as
+// ReachProtectionThreshold or computed with WithProtectThreshold.
+func (s *Selector) Candidates(service model.Service) []model.Instance {
+	var available, healthy []model.Instance
+	for _, instance := range service.Hosts {
+		if !instance.Enable || instance.Weight <= 0 {
+			continue
+		}
+		available = append(available, instance)
+		if instance.Healthy {
+			healthy = append(healthy, instance)
+		}
+	}
+	candidates := healthy
+	if service.ReachProtectionThreshold || s.reachProtectThreshold(len(healthy), len(service.Hosts)) {
+		candidates = available
+	}
+	for _, filter := range s.filters {
+		candidates = filter(candidates)
+	}
+	return candidates
+}
+
+func (s *Selector) reachProtectThreshold(healthy, total int) bool {
+	if s.protectThreshold <= 0 || total == 0 {
+		return false
+	}
+	return float64(healthy)/float64(total) <= s.protectThreshold
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selector
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+func instance(ip string, weight float64, healthy bool, metadata map[string]string) model.Instance {
+	return model.Instance{Ip: ip, Port: 80, Weight: weight, Healthy: healthy, Enable: true, ClusterName: "DEFAULT",
+		Metadata: metadata}
+}
+
+func TestSelectHealthyCandidates(t *testing.T) {
+	disabled := instance("10.0.0.3", 1, true, nil)
+	disabled.Enable = false
+	service := model.Service{Hosts: []model.Instance{
+		instance("10.0.0.1", 1, true, nil),
+		instance("10.0.0.2", 1, false, nil),
+		disabled,
+		instance("10.0.0.4", 0, true, nil),
+	}}
+	s := NewSelector(NewWeightedRandom())
+	for i := 0; i < 10; i++ {
+		selected, err := s.Select(service, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "10.0.0.1", selected.Ip)
+	}
+
+	_, err := s.Select(model.Service{Hosts: []model.Instance{instance("10.0.0.2", 1, false, nil)}}, "")
+	assert.Equal(t, ErrNoInstance, err)
+}
+
+func TestProtectThreshold(t *testing.T) {
+	service := model.Service{Hosts: []model.Instance{
+		instance("10.0.0.1", 1, true, nil),
+		instance("10.0.0.2", 1, false, nil),
+		instance("10.0.0.3", 1, false, nil),
+	}}
+	assert.Equal(t, 1, len(NewSelector(NewWeightedRandom()).Candidates(service)))
+	assert.Equal(t, 3, len(NewSelector(NewWeightedRandom(), WithProtectThreshold(0.5)).Candidates(service)))
+	assert.Equal(t, 1, len(NewSelector(NewWeightedRandom(), WithProtectThreshold(0.3)).Candidates(service)))
+
+	service.ReachProtectionThreshold = true
+	assert.Equal(t, 3, len(NewSelector(NewWeightedRandom()).Candidates(service)))
+}
+
+func TestWeightedRandom(t *testing.T) {
+	instances := []model.Instance{instance("10.0.0.1", 1, true, nil), instance("10.0.0.2", 3, true, nil)}
+	b := NewWeightedRandom()
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[b.Pick(instances, "").Ip]++
+	}
+	assert.InDelta(t, 1000, counts["10.0.0.1"], 200)
+	assert.InDelta(t, 3000, counts["10.0.0.2"], 200)
+	assert.Nil(t, b.Pick(nil, ""))
+}
+
+func TestSmoothWeightedRoundRobin(t *testing.T) {
+	instances := []model.Instance{
+		instance("a", 5, true, nil),
+		instance("b", 1, true, nil),
+		instance("c", 1, true, nil),
+	}
+	b := NewSmoothWeightedRoundRobin()
+	var picked string
+	for i := 0; i < 7; i++ {
+		picked += b.Pick(instances, "").Ip
+	}
+	// the sequence of nginx, the picks of a are interleaved with the others.
+	assert.Equal(t, "aabacaa", picked)
+
+	// the removed instance is forgotten.
+	picked = ""
+	for i := 0; i < 4; i++ {
+		picked += b.Pick(instances[1:], "").Ip
+	}
+	assert.Equal(t, "bcbc", picked)
+	assert.Nil(t, b.Pick(nil, ""))
+}
+
+func TestConsistentHash(t *testing.T) {
+	var instances []model.Instance
+	for i := 0; i < 5; i++ {
+		instances = append(instances, instance("10.0.0."+strconv.Itoa(i), 1, true, nil))
+	}
+	b := NewConsistentHash(0)
+	mapping := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := "user-" + strconv.Itoa(i)
+		mapping[key] = b.Pick(instances, key).Ip
+		assert.Equal(t, mapping[key], b.Pick(instances, key).Ip)
+	}
+
+	// only the keys of the removed instance are remapped.
+	remaining := instances[1:]
+	for key, ip := range mapping {
+		picked := b.Pick(remaining, key).Ip
+		if ip != instances[0].Ip {
+			assert.Equal(t, ip, picked)
+		} else {
+			assert.NotEqual(t, ip, picked)
+		}
+	}
+	assert.Nil(t, b.Pick(nil, "key"))
+}
+
+func TestSelectWithFilters(t *testing.T) {
+	service := model.Service{Hosts: []model.Instance{
+		instance("10.0.0.1", 1, true, map[string]string{LABEL_ZONE: "a", "env": "prod"}),
+		instance("10.0.0.2", 1, true, map[string]string{LABEL_ZONE: "b", "env": "prod"}),
+		instance("10.0.0.3", 1, true, map[string]string{LABEL_ZONE: "b", "env": "test"}),
+	}}
+	s := NewSelector(NewWeightedRandom(), WithFilters(LabelFilter(map[string]string{"env": "prod"}), ZoneAffinity("b")))
+	for i := 0; i < 10; i++ {
+		selected, err := s.Select(service, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "10.0.0.2", selected.Ip)
+	}
+
+	// the affinity keeps all instances if none is in the zone.
+	assert.Equal(t, 3, len(NewSelector(NewWeightedRandom(), WithFilters(ZoneAffinity("c"))).Candidates(service)))
+	assert.Equal(t, 2, len(NewSelector(NewWeightedRandom(), WithFilters(ClusterFilter("DEFAULT"), ZoneAffinity("b"))).Candidates(service)))
+	assert.Equal(t, 0, len(NewSelector(NewWeightedRandom(), WithFilters(ClusterFilter("other"))).Candidates(service)))
+}
+
+func TestExpressionFilter(t *testing.T) {
+	instances := []model.Instance{
+		instance("1", 1, true, map[string]string{"env": "prod", "zone": "a"}),
+		instance("2", 1, true, map[string]string{"env": "prod", "zone": "b", "canary": "true"}),
+		instance("3", 1, true, map[string]string{"env": "test"}),
+	}
+	ips := func(expression string) string {
+		filter, err := ExpressionFilter(model.ExpressionSelector{Type: SELECTOR_TYPE_LABEL, Expression: expression})
+		assert.Nil(t, err)
+		var result string
+		for _, instance := range filter(instances) {
+			result += instance.Ip
+		}
+		return result
+	}
+	assert.Equal(t, "12", ips("env=prod"))
+	assert.Equal(t, "12", ips("env == prod"))
+	assert.Equal(t, "3", ips("env!=prod"))
+	assert.Equal(t, "12", ips("zone in (a, b)"))
+	assert.Equal(t, "23", ips("zone notin (a)"))
+	assert.Equal(t, "2", ips("env=prod, canary"))
+	assert.Equal(t, "1", ips("env=prod,!canary"))
+	assert.Equal(t, "123", ips(""))
+
+	filter, err := ExpressionFilter(model.ExpressionSelector{Type: SELECTOR_TYPE_NONE, Expression: "env=prod"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(filter(instances)))
+	_, err = ExpressionFilter(model.ExpressionSelector{Type: "unknown"})
+	assert.NotNil(t, err)
+	_, err = ExpressionFilter(model.ExpressionSelector{Type: SELECTOR_TYPE_LABEL, Expression: "zone in a"})
+	assert.NotNil(t, err)
+	_, err = ExpressionFilter(model.ExpressionSelector{Type: SELECTOR_TYPE_LABEL, Expression: "=prod"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/cache"
+	"github.com/allenliu88/xgrpc-client-go/clients/naming_client/selector"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/file"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// ServiceInfoHolder keeps the subscribed services in memory and in the naming directory of CacheDir,
+// the subscribers are called with the difference when a service changes.
+type ServiceInfoHolder struct {
+	ServiceInfoMap       cache.ConcurrentMap
+	mux                  sync.Mutex
+	diskCache            *cache.DiskCache
+	updateCacheWhenEmpty bool
+	subCallback          *subscribeCallback
+}
+
+// NewServiceInfoHolder create the holder of the namespace, the services are persisted in the naming directory of
+// the cache directory, and loaded at start unless notLoadCacheAtStart.
+func NewServiceInfoHolder(namespace, cacheDir string, diskCacheCfg constant.DiskCacheConfig, updateCacheWhenEmpty,
+	notLoadCacheAtStart bool) (*ServiceInfoHolder, error) {
+	diskCache, err := cache.NewDiskCache(filepath.Join(cacheDir, "naming", namespace), diskCacheCfg)
+	if err != nil {
+		return nil, err
+	}
+	holder := &ServiceInfoHolder{
+		ServiceInfoMap:       cache.NewConcurrentMap(),
+		diskCache:            diskCache,
+		updateCacheWhenEmpty: updateCacheWhenEmpty,
+		subCallback:          newSubscribeCallback(),
+	}
+	if !notLoadCacheAtStart {
+		holder.loadCacheFromDisk()
+	}
+	return holder, nil
+}
+
+func (s *ServiceInfoHolder) loadCacheFromDisk() {
+	if !file.IsExistFile(s.diskCache.Dir()) {
+		return
+	}
+	for cacheKey, service := range s.diskCache.ReadServices() {
+		s.ServiceInfoMap.Set(cacheKey, service)
+	}
+}
+
+// ProcessService updates the cached service and notifies the subscribers if it changes. The service without
+// instances is ignored unless updateCacheWhenEmpty, it's more likely a failure of the server than an empty service.
+func (s *ServiceInfoHolder) ProcessService(service *model.Service) {
+	if service == nil || service.Name == "" {
+		return
+	}
+	cacheKey := util.GetServiceCacheKey(util.GetGroupName(service.Name, service.GroupName), service.Clusters)
+	s.mux.Lock()
+	old, exist := s.GetServiceInfoByKey(cacheKey)
+	if len(service.Hosts) == 0 && !s.updateCacheWhenEmpty {
+		s.mux.Unlock()
+		logger.Warnf("ignore the empty service %s, the cache isn't updated", cacheKey)
+		return
+	}
+	if exist && old.LastRefTime > service.LastRefTime {
+		s.mux.Unlock()
+		logger.Warnf("ignore the outdated service %s, lastRefTime %d is older than %d", cacheKey,
+			service.LastRefTime, old.LastRefTime)
+		return
+	}
+	s.ServiceInfoMap.Set(cacheKey, *service)
+	event := diffInstances(old.Hosts, service.Hosts)
+	changed := !exist || len(event.Added) > 0 || len(event.Removed) > 0 || len(event.Modified) > 0
+	if changed {
+		s.diskCache.WriteService(service, cacheKey)
+	}
+	s.mux.Unlock()
+
+	if changed {
+		logger.Infof("service %s changed, added:%d, removed:%d, modified:%d", cacheKey,
+			len(event.Added), len(event.Removed), len(event.Modified))
+		event.ServiceName, event.GroupName, event.Clusters = service.Name, service.GroupName, service.Clusters
+		event.Hosts = service.Hosts
+		s.subCallback.serviceChanged(cacheKey, event)
+	}
+}
+
+func (s *ServiceInfoHolder) GetServiceInfo(serviceName, groupName, clusters string) (model.Service, bool) {
+	return s.GetServiceInfoByKey(util.GetServiceCacheKey(util.GetGroupName(serviceName, groupName), clusters))
+}
+
+func (s *ServiceInfoHolder) GetServiceInfoByKey(cacheKey string) (model.Service, bool) {
+	v, ok := s.ServiceInfoMap.Get(cacheKey)
+	if !ok {
+		return model.Service{}, false
+	}
+	return v.(model.Service), true
+}
+
+// diffInstances returns the instances added to, removed from and modified in the old ones.
+func diffInstances(oldHosts, newHosts []model.Instance) model.InstanceChangeEvent {
+	var event model.InstanceChangeEvent
+	oldInstances := make(map[string]model.Instance, len(oldHosts))
+	for _, instance := range oldHosts {
+		oldInstances[selector.InstanceKey(instance)] = instance
+	}
+	newInstances := make(map[string]struct{}, len(newHosts))
+	for _, instance := range newHosts {
+		key := selector.InstanceKey(instance)
+		newInstances[key] = struct{}{}
+		old, ok := oldInstances[key]
+		if !ok {
+			event.Added = append(event.Added, instance)
+		} else if !reflect.DeepEqual(old, instance) {
+			event.Modified = append(event.Modified, instance)
+		}
+	}
+	for _, instance := range oldHosts {
+		if _, ok := newInstances[selector.InstanceKey(instance)]; !ok {
+			event.Removed = append(event.Removed, instance)
+		}
+	}
+	return event
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+func TestDiffInstances(t *testing.T) {
+	a := model.Instance{Ip: "10.0.0.1", Port: 80, ClusterName: "DEFAULT", Weight: 1}
+	b := model.Instance{Ip: "10.0.0.2", Port: 80, ClusterName: "DEFAULT", Weight: 1}
+	c := model.Instance{Ip: "10.0.0.3", Port: 80, ClusterName: "DEFAULT", Weight: 1}
+	modifiedB := b
+	modifiedB.Weight = 2
+
+	event := diffInstances([]model.Instance{a, b}, []model.Instance{modifiedB, c})
+	assert.Equal(t, []model.Instance{c}, event.Added)
+	assert.Equal(t, []model.Instance{a}, event.Removed)
+	assert.Equal(t, []model.Instance{modifiedB}, event.Modified)
+
+	event = diffInstances([]model.Instance{a}, []model.Instance{a})
+	assert.Empty(t, event.Added)
+	assert.Empty(t, event.Removed)
+	assert.Empty(t, event.Modified)
+
+	// the instances with ids are identified by the id.
+	withId := a
+	withId.InstanceId = "id"
+	event = diffInstances([]model.Instance{withId}, []model.Instance{{InstanceId: "id", Ip: "10.0.0.9"}})
+	assert.Equal(t, 1, len(event.Modified))
+}
+
+func newTestServiceInfoHolder(t *testing.T, namespace, cacheDir string, updateCacheWhenEmpty,
+	notLoadCacheAtStart bool) *ServiceInfoHolder {
+	holder, err := NewServiceInfoHolder(namespace, cacheDir, constant.DiskCacheConfig{}, updateCacheWhenEmpty,
+		notLoadCacheAtStart)
+	assert.Nil(t, err)
+	return holder
+}
+
+func TestProcessServiceNotifiesChanges(t *testing.T) {
+	holder := newTestServiceInfoHolder(t, "public", t.TempDir(), false, true)
+	var events []model.InstanceChangeEvent
+	holder.subCallback.addCallback("DEFAULT_GROUP@@demo", &vo.SubscribeParam{ServiceName: "demo",
+		InstanceChangeCallback: func(event model.InstanceChangeEvent) {
+			events = append(events, event)
+		}})
+	instance := model.Instance{Ip: "10.0.0.1", Port: 80, Weight: 1}
+
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP", Hosts: []model.Instance{instance}, LastRefTime: 1})
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "demo", events[0].ServiceName)
+	assert.Equal(t, []model.Instance{instance}, events[0].Added)
+
+	// unchanged, outdated and empty services don't notify.
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP", Hosts: []model.Instance{instance}, LastRefTime: 2})
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP", LastRefTime: 1})
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP", LastRefTime: 3})
+	assert.Equal(t, 1, len(events))
+	service, ok := holder.GetServiceInfo("demo", "DEFAULT_GROUP", "")
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(service.Hosts))
+	assert.Equal(t, uint64(2), service.LastRefTime)
+}
+
+func TestProcessEmptyServiceWhenUpdateCacheWhenEmpty(t *testing.T) {
+	holder := newTestServiceInfoHolder(t, "public", t.TempDir(), true, true)
+	var events []model.InstanceChangeEvent
+	holder.subCallback.addCallback("DEFAULT_GROUP@@demo", &vo.SubscribeParam{ServiceName: "demo",
+		InstanceChangeCallback: func(event model.InstanceChangeEvent) {
+			events = append(events, event)
+		}})
+	instance := model.Instance{Ip: "10.0.0.1", Port: 80, Weight: 1}
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP", Hosts: []model.Instance{instance}})
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "DEFAULT_GROUP"})
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, []model.Instance{instance}, events[1].Removed)
+	service, _ := holder.GetServiceInfo("demo", "DEFAULT_GROUP", "")
+	assert.Empty(t, service.Hosts)
+}
+
+func TestServiceInfoHolderPersistence(t *testing.T) {
+	cacheDir := t.TempDir()
+	holder := newTestServiceInfoHolder(t, "public", cacheDir, false, false)
+	holder.ProcessService(&model.Service{Name: "demo", GroupName: "app", Clusters: "a,b",
+		Hosts: []model.Instance{{Ip: "10.0.0.1", Port: 80}}})
+
+	loaded := newTestServiceInfoHolder(t, "public", cacheDir, false, false)
+	service, ok := loaded.GetServiceInfo("demo", "app", "a,b")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", service.Hosts[0].Ip)
+
+	notLoaded := newTestServiceInfoHolder(t, "public", cacheDir, false, true)
+	_, ok = notLoaded.GetServiceInfo("demo", "app", "a,b")
+	assert.False(t, ok)
+	other := newTestServiceInfoHolder(t, "other", cacheDir, false, false)
+	_, ok = other.GetServiceInfo("demo", "app", "a,b")
+	assert.False(t, ok)
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package naming_client
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+// subscribeCallback keeps the subscribers by the service cache key.
+type subscribeCallback struct {
+	mux         sync.RWMutex
+	subscribers map[string][]*vo.SubscribeParam
+}
+
+func newSubscribeCallback() *subscribeCallback {
+	return &subscribeCallback{subscribers: map[string][]*vo.SubscribeParam{}}
+}
+
+func (s *subscribeCallback) isSubscribed(cacheKey string) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return len(s.subscribers[cacheKey]) > 0
+}
+
+func (s *subscribeCallback) addCallback(cacheKey string, param *vo.SubscribeParam) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.subscribers[cacheKey] = append(s.subscribers[cacheKey], param)
+}
+
+// removeCallback removes the subscriber with the same callbacks, and returns the number of the remaining ones
+// and whether a subscriber was removed.
+func (s *subscribeCallback) removeCallback(cacheKey string, param *vo.SubscribeParam) (int, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	subscribers := s.subscribers[cacheKey]
+	for i, subscriber := range subscribers {
+		if sameSubscriber(subscriber, param) {
+			subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+			if len(subscribers) == 0 {
+				delete(s.subscribers, cacheKey)
+			} else {
+				s.subscribers[cacheKey] = subscribers
+			}
+			return len(subscribers), true
+		}
+	}
+	return len(subscribers), false
+}
+
+// sameSubscriber matches the subscribers by the callbacks, so the service can be unsubscribed with a new param.
+func sameSubscriber(subscriber, param *vo.SubscribeParam) bool {
+	if subscriber == param {
+		return true
+	}
+	return sameFunc(subscriber.SubscribeCallback, param.SubscribeCallback) &&
+		sameFunc(subscriber.InstanceChangeCallback, param.InstanceChangeCallback)
+}
+
+func sameFunc(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsNil() || vb.IsNil() {
+		return va.IsNil() && vb.IsNil()
+	}
+	return va.Pointer() == vb.Pointer()
+}
+
+// serviceChanged calls all subscribers of the service with the change.
+func (s *subscribeCallback) serviceChanged(cacheKey string, event model.InstanceChangeEvent) {
+	s.mux.RLock()
+	subscribers := append([]*vo.SubscribeParam(nil), s.subscribers[cacheKey]...)
+	s.mux.RUnlock()
+	for _, subscriber := range subscribers {
+		notifySubscriber(subscriber, event)
+	}
+}
+
+func notifySubscriber(subscriber *vo.SubscribeParam, event model.InstanceChangeEvent) {
+	if subscriber.SubscribeCallback != nil {
+		subscriber.SubscribeCallback(event.Hosts, nil)
+	}
+	if subscriber.InstanceChangeCallback != nil {
+		subscriber.InstanceChangeCallback(event)
+	}
+}
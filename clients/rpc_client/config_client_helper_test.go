@@ -0,0 +1,57 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rpc_client_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/config_client"
+	"github.com/allenliu88/xgrpc-client-go/clients/internal/fake_config_server"
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/vo"
+)
+
+// the config tests are in package rpc_client_test, since the config client imports rpc_client.
+var localConfigTest = vo.ConfigParam{
+	DataId:  "dataId",
+	Group:   "group",
+	Content: "content",
+}
+
+// createConfigClientTest creates the config client connected to a fake_config_server.FakeConfigServer, which lives until the tests end.
+func createConfigClientTest() *config_client.ConfigClient {
+	server, err := fake_config_server.NewFakeConfigServer()
+	if err != nil {
+		panic(err)
+	}
+	clientConfig := constant.ClientConfig{
+		TimeoutMs:   3000,
+		NamespaceId: "public",
+		CacheDir:    filepath.Join(os.TempDir(), "xgrpc-rpc-client-test"),
+	}
+	manager, err := rpc_client.NewRpcClientManager([]constant.ServerConfig{server.ServerConfig()}, clientConfig, &http_agent.HttpAgent{})
+	if err != nil {
+		panic(err)
+	}
+	client, err := config_client.NewConfigClient(manager, clientConfig)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
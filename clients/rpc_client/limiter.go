@@ -13,43 +13,210 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
+
 package rpc_client
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 
-	"github.com/allenliu88/xgrpc-client-go/clients/cache"
+	"github.com/pkg/errors"
 	"golang.org/x/time/rate"
+
+	"github.com/allenliu88/xgrpc-client-go/clients/cache"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+)
+
+const (
+	RATE_LIMIT_SCOPE_GLOBAL       = "global"
+	RATE_LIMIT_SCOPE_REQUEST_TYPE = "requestType"
+	RATE_LIMIT_SCOPE_KEY          = "key"
+
+	// limiterIdleTimeout is the duration after which the limiter of a key not checked is removed.
+	limiterIdleTimeout = 10 * time.Minute
 )
 
-type rateLimiterCheck struct {
-	rateLimiterCache cache.ConcurrentMap // cache
-	mux              sync.Mutex
+// defaultKeyLimit is the limit of each key if it's not configured, 5 times per second and the burst is 5.
+var defaultKeyLimit = constant.RateLimit{Rate: 5, Burst: 5}
+
+// ErrRateLimited is matched by errors.Is with the errors of the requests rejected by the client-side rate limits.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is the error of the rejected request, Scope is the limit exceeded and Key is the request type
+// or the key of the limit.
+type RateLimitError struct {
+	Scope string
+	Key   string
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Key == "" {
+		return "request is rate limited by the " + e.Scope + " limit"
+	}
+	return "request is rate limited by the " + e.Scope + " limit of " + e.Key
 }
 
-var checker rateLimiterCheck
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
 
-func init() {
-	checker = rateLimiterCheck{
-		rateLimiterCache: cache.NewConcurrentMap(),
-		mux:              sync.Mutex{},
+type rateLimitKey struct{}
+
+// WithRateLimitKey returns the context whose request is limited by the per-key limit of the key as well.
+func WithRateLimitKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, key)
+}
+
+func rateLimitKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(rateLimitKey{}).(string)
+	return key
+}
+
+// RateLimiter limits the requests by the global limit, the limit of the request type and the limit of the key
+// of RateLimitConfig. A request takes a permit of every limit it's subject to, or none of them if it's rejected.
+type RateLimiter struct {
+	block        bool
+	global       *rate.Limiter
+	requestTypes map[string]*rate.Limiter
+	keyLimit     constant.RateLimit
+	keys         *cache.Cache[string, *rate.Limiter]
+}
+
+func NewRateLimiter(config constant.RateLimitConfig) (*RateLimiter, error) {
+	l := &RateLimiter{
+		global:       newLimiter(config.Global),
+		requestTypes: map[string]*rate.Limiter{},
+		keyLimit:     config.PerKey,
+	}
+	switch config.Mode {
+	case "", constant.RATE_LIMIT_MODE_FAIL_FAST:
+	case constant.RATE_LIMIT_MODE_BLOCK:
+		l.block = true
+	default:
+		return nil, errors.Errorf("unknown rate limit mode %s", config.Mode)
+	}
+	for requestType, limit := range config.RequestTypes {
+		if limiter := newLimiter(limit); limiter != nil {
+			l.requestTypes[requestType] = limiter
+		}
 	}
+	if l.keyLimit.Rate == 0 {
+		l.keyLimit = defaultKeyLimit
+	}
+	l.keys = cache.NewCache(cache.WithExpireAfterAccess[string, *rate.Limiter](limiterIdleTimeout))
+	return l, nil
 }
 
-// IsLimited return true when request is limited
+// Acquire takes the permits of the request, the key is ignored if it's empty. It fails with *RateLimitError at
+// once in the fail-fast mode, and waits for the permits until ctx is done in the block mode; it fails at once
+// too if the permits are not available before the deadline of ctx.
+func (l *RateLimiter) Acquire(ctx context.Context, clientName string, requestType string, key string) error {
+	var limiters []*rate.Limiter
+	var scopes []*RateLimitError
+	if l.global != nil {
+		limiters = append(limiters, l.global)
+		scopes = append(scopes, &RateLimitError{Scope: RATE_LIMIT_SCOPE_GLOBAL})
+	}
+	if limiter, ok := l.requestTypes[requestType]; ok {
+		limiters = append(limiters, limiter)
+		scopes = append(scopes, &RateLimitError{Scope: RATE_LIMIT_SCOPE_REQUEST_TYPE, Key: requestType})
+	}
+	if limiter := l.keyLimiter(key); limiter != nil {
+		limiters = append(limiters, limiter)
+		scopes = append(scopes, &RateLimitError{Scope: RATE_LIMIT_SCOPE_KEY, Key: key})
+	}
+	if len(limiters) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	var delay time.Duration
+	var limited *RateLimitError
+	for i, limiter := range limiters {
+		r := limiter.ReserveN(now, 1)
+		if !r.OK() {
+			cancel()
+			return l.reject(clientName, requestType, scopes[i])
+		}
+		reservations = append(reservations, r)
+		if d := r.DelayFrom(now); d > delay {
+			delay, limited = d, scopes[i]
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	if !l.block {
+		cancel()
+		return l.reject(clientName, requestType, limited)
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		cancel()
+		return l.reject(clientName, requestType, limited)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		monitor.GetThrottledMonitor(clientName, requestType, limited.Scope, monitor.ThrottleDelayed).Inc()
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return l.reject(clientName, requestType, limited)
+	}
+}
+
+// Close stops removing the idle limiters of the keys.
+func (l *RateLimiter) Close() {
+	l.keys.Close()
+}
+
+func (l *RateLimiter) reject(clientName string, requestType string, err *RateLimitError) error {
+	monitor.GetThrottledMonitor(clientName, requestType, err.Scope, monitor.ThrottleRejected).Inc()
+	return err
+}
+
+func (l *RateLimiter) keyLimiter(key string) *rate.Limiter {
+	if key == "" || l.keyLimit.Rate < 0 {
+		return nil
+	}
+	limiter, _ := l.keys.GetOrLoadWith(key, func(string) (*rate.Limiter, error) {
+		return newLimiter(l.keyLimit), nil
+	})
+	return limiter
+}
+
+// newLimiter returns nil if the rate isn't positive, the burst is the rate rounded up if it's not positive.
+func newLimiter(limit constant.RateLimit) *rate.Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(limit.Rate))
+	}
+	return rate.NewLimiter(rate.Limit(limit.Rate), burst)
+}
+
+var (
+	defaultLimiter     *RateLimiter
+	defaultLimiterOnce sync.Once
+)
+
+// IsLimited return true when the key exceeds 5 times per second. The limiter is created on the first call, so
+// removing its idle keys doesn't start unless it's used.
 func IsLimited(checkKey string) bool {
-	checker.mux.Lock()
-	defer checker.mux.Unlock()
-	var limiter *rate.Limiter
-	lm, exist := checker.rateLimiterCache.Get(checkKey)
-	if !exist {
-		// define a new limiter,allow 5 times per second,and reserve stock is 5.
-		limiter = rate.NewLimiter(rate.Limit(5), 5)
-		checker.rateLimiterCache.Set(checkKey, limiter)
-	} else {
-		limiter = lm.(*rate.Limiter)
-	}
-	add := time.Now().Add(time.Second)
-	return !limiter.AllowN(add, 1)
+	defaultLimiterOnce.Do(func() {
+		defaultLimiter, _ = NewRateLimiter(constant.RateLimitConfig{})
+	})
+	return !defaultLimiter.keyLimiter(checkKey).Allow()
 }
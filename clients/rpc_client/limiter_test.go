@@ -13,11 +13,16 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-package rpc_client
+package rpc_client_test
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/allenliu88/xgrpc-client-go/clients/rpc_client"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/vo"
 
 	"github.com/stretchr/testify/assert"
@@ -45,3 +50,73 @@ func TestLimiter(t *testing.T) {
 		}
 	}
 }
+
+func TestRateLimiterFailFast(t *testing.T) {
+	limiter, err := rpc_client.NewRateLimiter(constant.RateLimitConfig{
+		RequestTypes: map[string]constant.RateLimit{"ConfigQueryRequest": {Rate: 1, Burst: 2}},
+		PerKey:       constant.RateLimit{Rate: -1},
+	})
+	assert.Nil(t, err)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", ""))
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", ""))
+	err = limiter.Acquire(ctx, "client", "ConfigQueryRequest", "")
+	assert.True(t, errors.Is(err, rpc_client.ErrRateLimited))
+	var limitErr *rpc_client.RateLimitError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, rpc_client.RATE_LIMIT_SCOPE_REQUEST_TYPE, limitErr.Scope)
+	assert.Equal(t, "ConfigQueryRequest", limitErr.Key)
+	// the other request types are not limited.
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigPublishRequest", "key"))
+	}
+}
+
+func TestRateLimiterGlobalAndKey(t *testing.T) {
+	limiter, err := rpc_client.NewRateLimiter(constant.RateLimitConfig{
+		Global: constant.RateLimit{Rate: 1, Burst: 3},
+		PerKey: constant.RateLimit{Rate: 1, Burst: 1},
+	})
+	assert.Nil(t, err)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", "a"))
+	var limitErr *rpc_client.RateLimitError
+	assert.True(t, errors.As(limiter.Acquire(ctx, "client", "ConfigQueryRequest", "a"), &limitErr))
+	assert.Equal(t, rpc_client.RATE_LIMIT_SCOPE_KEY, limitErr.Scope)
+	// the rejected request doesn't take the global permit.
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", "b"))
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", "c"))
+	assert.True(t, errors.As(limiter.Acquire(ctx, "client", "ConfigQueryRequest", "d"), &limitErr))
+	assert.Equal(t, rpc_client.RATE_LIMIT_SCOPE_GLOBAL, limitErr.Scope)
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	limiter, err := rpc_client.NewRateLimiter(constant.RateLimitConfig{
+		Mode:   constant.RATE_LIMIT_MODE_BLOCK,
+		Global: constant.RateLimit{Rate: 20, Burst: 1},
+	})
+	assert.Nil(t, err)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", ""))
+	start := time.Now()
+	assert.Nil(t, limiter.Acquire(ctx, "client", "ConfigQueryRequest", ""))
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+
+	// fails at once if the permit isn't available before the deadline.
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.True(t, errors.Is(limiter.Acquire(ctx, "client", "ConfigQueryRequest", ""), rpc_client.ErrRateLimited))
+	assert.True(t, time.Since(start) < 10*time.Millisecond)
+}
+
+func TestNewRateLimiterUnknownMode(t *testing.T) {
+	_, err := rpc_client.NewRateLimiter(constant.RateLimitConfig{Mode: "unknown"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc_client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+// LABEL_CLUSTER routes the request to the named cluster, it takes precedence over the RoutingFunc.
+const LABEL_CLUSTER = "cluster"
+
+var ErrClusterNotFound = errors.New("cluster not found")
+
+// ClusterConfig describes a cluster, each cluster has its own servers, auth and tls.
+type ClusterConfig struct {
+	Name          string
+	ServerConfigs []constant.ServerConfig
+	ClientConfig  constant.ClientConfig
+	HttpAgent     http_agent.IHttpAgent // optional, the HttpAgent with the tls of ClientConfig is used if nil
+}
+
+// RoutingFunc returns the name of the cluster which serves the request with the labels,
+// the default cluster is used if it returns empty.
+type RoutingFunc func(labels map[string]string) string
+
+// FailoverPolicy decides whether the failed request is sent to the other clusters.
+type FailoverPolicy struct {
+	Enabled bool
+	// Clusters are tried in order after the routed one, all the other clusters in the configured order are tried if empty.
+	Clusters []string
+	// MaxAttempts limits the clusters tried by a request including the routed one, it's unlimited if not positive.
+	MaxAttempts int
+	// ShouldFailover decides whether to try the next cluster, the request fails over only on the error if nil.
+	ShouldFailover func(response rpc_response.IResponse, err error) bool
+}
+
+type MultiClusterOption func(*MultiClusterManager)
+
+// WithRoutingFunc sets the function routing the requests by the labels.
+func WithRoutingFunc(routingFunc RoutingFunc) MultiClusterOption {
+	return func(m *MultiClusterManager) {
+		m.routingFunc = routingFunc
+	}
+}
+
+// WithDefaultCluster sets the cluster of the requests not routed, it's the first cluster by default.
+func WithDefaultCluster(clusterName string) MultiClusterOption {
+	return func(m *MultiClusterManager) {
+		m.defaultCluster = clusterName
+	}
+}
+
+func WithFailoverPolicy(policy FailoverPolicy) MultiClusterOption {
+	return func(m *MultiClusterManager) {
+		m.failoverPolicy = policy
+	}
+}
+
+// WithServerRequestHandlers sets the handlers registered to the clients of every cluster.
+func WithServerRequestHandlers(handlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) MultiClusterOption {
+	return func(m *MultiClusterManager) {
+		m.serverRequestHandlers = handlers
+	}
+}
+
+// MultiClusterManager holds the named clusters and routes the requests among them.
+type MultiClusterManager struct {
+	clusterNames          []string
+	managers              map[string]IRpcClientManager
+	routingFunc           RoutingFunc
+	defaultCluster        string
+	failoverPolicy        FailoverPolicy
+	serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest
+	mux                   sync.Mutex
+	clients               map[string]*rpc.RpcClient
+}
+
+func NewMultiClusterManager(clusters []ClusterConfig, opts ...MultiClusterOption) (*MultiClusterManager, error) {
+	if len(clusters) == 0 {
+		return nil, errors.New("clusters are empty")
+	}
+	m := &MultiClusterManager{
+		managers: make(map[string]IRpcClientManager, len(clusters)),
+		clients:  make(map[string]*rpc.RpcClient, len(clusters)),
+	}
+	if err := m.init(clusters, opts); err != nil {
+		// the managers created before the error are not used any more.
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MultiClusterManager) init(clusters []ClusterConfig, opts []MultiClusterOption) error {
+	for _, cluster := range clusters {
+		if cluster.Name == "" {
+			return errors.New("cluster name can not be empty")
+		}
+		if _, ok := m.managers[cluster.Name]; ok {
+			return errors.Errorf("duplicated cluster %s", cluster.Name)
+		}
+		httpAgent := cluster.HttpAgent
+		if httpAgent == nil {
+			httpAgent = &http_agent.HttpAgent{TlsConfig: cluster.ClientConfig.TLSCfg, TransportConfig: cluster.ClientConfig.HttpTransportCfg}
+		}
+		manager, err := NewRpcClientManager(cluster.ServerConfigs, cluster.ClientConfig, httpAgent)
+		if err != nil {
+			return errors.Wrapf(err, "create the manager of cluster %s failed", cluster.Name)
+		}
+		m.managers[cluster.Name] = manager
+		m.clusterNames = append(m.clusterNames, cluster.Name)
+	}
+	m.defaultCluster = m.clusterNames[0]
+	for _, opt := range opts {
+		opt(m)
+	}
+	if _, ok := m.managers[m.defaultCluster]; !ok {
+		return errors.Wrapf(ErrClusterNotFound, "default cluster %s", m.defaultCluster)
+	}
+	for _, name := range m.failoverPolicy.Clusters {
+		if _, ok := m.managers[name]; !ok {
+			return errors.Wrapf(ErrClusterNotFound, "failover cluster %s", name)
+		}
+	}
+	return nil
+}
+
+// ClusterNames returns the names of the clusters in the configured order.
+func (m *MultiClusterManager) ClusterNames() []string {
+	return append([]string(nil), m.clusterNames...)
+}
+
+// GetManager returns the IRpcClientManager of the cluster.
+func (m *MultiClusterManager) GetManager(clusterName string) (IRpcClientManager, error) {
+	manager, ok := m.managers[clusterName]
+	if !ok {
+		return nil, errors.Wrapf(ErrClusterNotFound, "cluster %s", clusterName)
+	}
+	return manager, nil
+}
+
+// Route returns the cluster of the request with the labels, by LABEL_CLUSTER, the RoutingFunc and the default cluster in order.
+func (m *MultiClusterManager) Route(labels map[string]string) (string, error) {
+	clusterName := labels[LABEL_CLUSTER]
+	if clusterName == "" && m.routingFunc != nil {
+		clusterName = m.routingFunc(labels)
+	}
+	if clusterName == "" {
+		clusterName = m.defaultCluster
+	}
+	if _, ok := m.managers[clusterName]; !ok {
+		return "", errors.Wrapf(ErrClusterNotFound, "cluster %s", clusterName)
+	}
+	return clusterName, nil
+}
+
+// GetRpcClient returns the shared client of the cluster, which is created on the first use.
+func (m *MultiClusterManager) GetRpcClient(clusterName string) (*rpc.RpcClient, error) {
+	manager, err := m.GetManager(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if client, ok := m.clients[clusterName]; ok {
+		return client, nil
+	}
+	client := manager.GetRpcClient(map[string]string{LABEL_CLUSTER: clusterName}, m.serverRequestHandlers)
+	m.clients[clusterName] = client
+	return client, nil
+}
+
+// Request sends the request to the cluster routed by the labels, and fails over to the other clusters if the policy permits.
+func (m *MultiClusterManager) Request(ctx context.Context, labels map[string]string, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
+	clusterName, err := m.Route(labels)
+	if err != nil {
+		return nil, err
+	}
+	return m.RequestCluster(ctx, clusterName, request, timeoutMills)
+}
+
+// RequestCluster sends the request to the named cluster, and fails over to the other clusters if the policy permits.
+// The headers injected by a cluster, e.g. the credentials and the signature, and the request id generated by it are
+// removed before the request is sent to the next cluster.
+func (m *MultiClusterManager) RequestCluster(ctx context.Context, clusterName string, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
+	var response rpc_response.IResponse
+	var err error
+	headers := make(map[string]string, len(request.GetHeaders()))
+	for k, v := range request.GetHeaders() {
+		headers[k] = v
+	}
+	requestId := request.GetRequestId()
+	for i, name := range m.failoverClusters(clusterName) {
+		if i > 0 {
+			logger.Warnf("request %s fails over from cluster %s to %s, error:%+v", request.GetRequestType(), clusterName, name, err)
+			resetHeaders(request.GetHeaders(), headers)
+			request.SetRequestId(requestId)
+		}
+		response, err = m.requestOnce(ctx, name, request, timeoutMills)
+		if !m.shouldFailover(response, err) || ctx.Err() != nil {
+			return response, err
+		}
+	}
+	return response, err
+}
+
+func (m *MultiClusterManager) requestOnce(ctx context.Context, clusterName string, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
+	manager, err := m.GetManager(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := m.GetRpcClient(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return manager.RequestWithContext(ctx, client, request, timeoutMills)
+}
+
+// resetHeaders restores the headers of the request to the ones before the first attempt.
+func resetHeaders(current map[string]string, original map[string]string) {
+	for k := range current {
+		delete(current, k)
+	}
+	for k, v := range original {
+		current[k] = v
+	}
+}
+
+// failoverClusters returns the clusters tried in order, starting with the routed one.
+func (m *MultiClusterManager) failoverClusters(clusterName string) []string {
+	result := []string{clusterName}
+	if !m.failoverPolicy.Enabled {
+		return result
+	}
+	candidates := m.failoverPolicy.Clusters
+	if len(candidates) == 0 {
+		candidates = m.clusterNames
+	}
+	for _, name := range candidates {
+		if m.failoverPolicy.MaxAttempts > 0 && len(result) >= m.failoverPolicy.MaxAttempts {
+			break
+		}
+		if name != clusterName {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func (m *MultiClusterManager) shouldFailover(response rpc_response.IResponse, err error) bool {
+	if m.failoverPolicy.ShouldFailover != nil {
+		return m.failoverPolicy.ShouldFailover(response, err)
+	}
+	return err != nil
+}
+
+// Close shuts down the clients and the managers of all clusters.
+func (m *MultiClusterManager) Close() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for clusterName, client := range m.clients {
+		m.managers[clusterName].Close(client)
+		delete(m.clients, clusterName)
+	}
+	for _, manager := range m.managers {
+		manager.Shutdown()
+	}
+}
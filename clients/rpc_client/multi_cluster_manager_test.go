@@ -0,0 +1,173 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc_client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+type fakeRpcClientManager struct {
+	IRpcClientManager
+	name     string
+	err      error
+	requests int
+	headers  []map[string]string
+}
+
+func (f *fakeRpcClientManager) GetRpcClient(labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient {
+	return &rpc.RpcClient{Name: f.name}
+}
+
+func (f *fakeRpcClientManager) RequestWithContext(ctx context.Context, rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
+	f.requests++
+	headers := map[string]string{}
+	for k, v := range request.GetHeaders() {
+		headers[k] = v
+	}
+	f.headers = append(f.headers, headers)
+	// inject the credential and the request id of the cluster like RpcClientManager.
+	request.GetHeaders()[constant.KEY_ACCESS_TOKEN] = f.name
+	if request.GetRequestId() == "" {
+		request.SetRequestId(f.name)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{Success: true, Message: f.name}}, nil
+}
+
+func newFakeMultiClusterManager(policy FailoverPolicy, managers ...*fakeRpcClientManager) *MultiClusterManager {
+	m := &MultiClusterManager{
+		managers:       map[string]IRpcClientManager{},
+		clients:        map[string]*rpc.RpcClient{},
+		failoverPolicy: policy,
+	}
+	for _, manager := range managers {
+		m.managers[manager.name] = manager
+		m.clusterNames = append(m.clusterNames, manager.name)
+	}
+	m.defaultCluster = m.clusterNames[0]
+	return m
+}
+
+func TestNewMultiClusterManager(t *testing.T) {
+	servers := []constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}}
+	m, err := NewMultiClusterManager([]ClusterConfig{
+		{Name: "hangzhou", ServerConfigs: servers},
+		{Name: "shanghai", ServerConfigs: servers, ClientConfig: constant.ClientConfig{NamespaceId: "tenant"}},
+	}, WithDefaultCluster("shanghai"), WithRoutingFunc(func(labels map[string]string) string {
+		return labels["region"]
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hangzhou", "shanghai"}, m.ClusterNames())
+
+	clusterName, err := m.Route(map[string]string{"region": "hangzhou"})
+	assert.Nil(t, err)
+	assert.Equal(t, "hangzhou", clusterName)
+	clusterName, err = m.Route(map[string]string{"region": "hangzhou", LABEL_CLUSTER: "shanghai"})
+	assert.Nil(t, err)
+	assert.Equal(t, "shanghai", clusterName)
+	clusterName, err = m.Route(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "shanghai", clusterName)
+	_, err = m.Route(map[string]string{"region": "beijing"})
+	assert.True(t, errors.Is(err, ErrClusterNotFound))
+
+	_, err = NewMultiClusterManager([]ClusterConfig{{Name: "hangzhou", ServerConfigs: servers}, {Name: "hangzhou", ServerConfigs: servers}})
+	assert.NotNil(t, err)
+	_, err = NewMultiClusterManager([]ClusterConfig{{Name: "hangzhou", ServerConfigs: servers}}, WithDefaultCluster("beijing"))
+	assert.True(t, errors.Is(err, ErrClusterNotFound))
+	_, err = NewMultiClusterManager([]ClusterConfig{{Name: "hangzhou", ServerConfigs: servers}},
+		WithFailoverPolicy(FailoverPolicy{Enabled: true, Clusters: []string{"beijing"}}))
+	assert.True(t, errors.Is(err, ErrClusterNotFound))
+}
+
+func TestMultiClusterRequestWithoutFailover(t *testing.T) {
+	hangzhou := &fakeRpcClientManager{name: "hangzhou", err: errors.New("unavailable")}
+	shanghai := &fakeRpcClientManager{name: "shanghai"}
+	m := newFakeMultiClusterManager(FailoverPolicy{}, hangzhou, shanghai)
+
+	_, err := m.Request(context.Background(), nil, rpc_request.NewHealthCheckRequest(), 3000)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, shanghai.requests)
+
+	response, err := m.Request(context.Background(), map[string]string{LABEL_CLUSTER: "shanghai"}, rpc_request.NewHealthCheckRequest(), 3000)
+	assert.Nil(t, err)
+	assert.Equal(t, "shanghai", response.GetMessage())
+}
+
+func TestMultiClusterRequestFailover(t *testing.T) {
+	hangzhou := &fakeRpcClientManager{name: "hangzhou", err: errors.New("unavailable")}
+	shanghai := &fakeRpcClientManager{name: "shanghai", err: errors.New("unavailable")}
+	beijing := &fakeRpcClientManager{name: "beijing"}
+	m := newFakeMultiClusterManager(FailoverPolicy{Enabled: true}, hangzhou, shanghai, beijing)
+
+	response, err := m.Request(context.Background(), nil, rpc_request.NewHealthCheckRequest(), 3000)
+	assert.Nil(t, err)
+	assert.Equal(t, "beijing", response.GetMessage())
+	assert.Equal(t, []int{1, 1, 1}, []int{hangzhou.requests, shanghai.requests, beijing.requests})
+
+	m.failoverPolicy = FailoverPolicy{Enabled: true, Clusters: []string{"beijing"}}
+	response, err = m.RequestCluster(context.Background(), "shanghai", rpc_request.NewHealthCheckRequest(), 3000)
+	assert.Nil(t, err)
+	assert.Equal(t, "beijing", response.GetMessage())
+	assert.Equal(t, []int{1, 2, 2}, []int{hangzhou.requests, shanghai.requests, beijing.requests})
+
+	m.failoverPolicy = FailoverPolicy{Enabled: true, MaxAttempts: 2}
+	_, err = m.Request(context.Background(), nil, rpc_request.NewHealthCheckRequest(), 3000)
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{2, 3, 2}, []int{hangzhou.requests, shanghai.requests, beijing.requests})
+
+	m.failoverPolicy = FailoverPolicy{Enabled: true, ShouldFailover: func(response rpc_response.IResponse, err error) bool {
+		return false
+	}}
+	_, err = m.Request(context.Background(), nil, rpc_request.NewHealthCheckRequest(), 3000)
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{3, 3, 2}, []int{hangzhou.requests, shanghai.requests, beijing.requests})
+}
+
+func TestMultiClusterFailoverResetsHeaders(t *testing.T) {
+	hangzhou := &fakeRpcClientManager{name: "hangzhou", err: errors.New("unavailable")}
+	shanghai := &fakeRpcClientManager{name: "shanghai"}
+	m := newFakeMultiClusterManager(FailoverPolicy{Enabled: true}, hangzhou, shanghai)
+
+	request := rpc_request.NewHealthCheckRequest()
+	request.PutAllHeaders(map[string]string{"k": "v"})
+	_, err := m.Request(context.Background(), nil, request, 3000)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{{"k": "v"}}, shanghai.headers)
+	assert.Equal(t, "shanghai", request.GetRequestId())
+}
+
+func TestNewMultiClusterManagerFailsPartway(t *testing.T) {
+	servers := []constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}}
+	m, err := NewMultiClusterManager([]ClusterConfig{
+		{Name: "hangzhou", ServerConfigs: servers},
+		{Name: "shanghai", ServerConfigs: servers, ClientConfig: constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "bogus"}}},
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, m)
+}
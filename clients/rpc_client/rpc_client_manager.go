@@ -1,6 +1,8 @@
 package rpc_client
 
 import (
+	"context"
+
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
@@ -8,7 +10,14 @@ import (
 
 type IRpcClientManager interface {
 	Request(rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error)
+	RequestWithContext(ctx context.Context, rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error)
 	CreateRpcClient(taskId string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient
 	GetRpcClient(labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient
+	// CreateRpcClientForTenant is CreateRpcClient of the tenant, the clients of different tenants are never shared.
+	CreateRpcClientForTenant(tenant string, taskId string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient
+	// GetRpcClientForTenant is GetRpcClient of the tenant, the clients of different tenants are never shared.
+	GetRpcClientForTenant(tenant string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient
 	Close(rpcClient *rpc.RpcClient)
+	// Shutdown releases the resources of the manager, e.g. the rate limiter, the clients are closed by Close.
+	Shutdown()
 }
@@ -17,15 +17,19 @@
 package rpc_client
 
 import (
+	"context"
 	"strconv"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/allenliu88/xgrpc-client-go/common/monitor"
 	"github.com/allenliu88/xgrpc-client-go/inner/uuid"
 
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/common/tracing"
 
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
@@ -37,13 +41,20 @@ type RpcClientManager struct {
 	xgrpcServer  *xgrpc_server.XgrpcServer
 	clientConfig constant.ClientConfig
 	uid          string
+	limiter      *RateLimiter
 }
 
 func NewRpcClientManager(serverConfig []constant.ServerConfig, clientConfig constant.ClientConfig, httpAgent http_agent.IHttpAgent) (IRpcClientManager, error) {
 	rpcClientManager := RpcClientManager{}
 	var err error
 	rpcClientManager.xgrpcServer, err = xgrpc_server.NewXgrpcServer(serverConfig, clientConfig, httpAgent, clientConfig.TimeoutMs, clientConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
 	rpcClientManager.clientConfig = clientConfig
+	if err = monitor.Register(clientConfig.MetricsRegisterer); err != nil {
+		return nil, err
+	}
 
 	uid, err := uuid.NewV4()
 	if err != nil {
@@ -51,23 +62,59 @@ func NewRpcClientManager(serverConfig []constant.ServerConfig, clientConfig cons
 	}
 
 	rpcClientManager.uid = uid.String()
-	return &rpcClientManager, err
+	if rpcClientManager.limiter, err = NewRateLimiter(clientConfig.RateLimitCfg); err != nil {
+		return nil, err
+	}
+	return &rpcClientManager, nil
 }
 
 func (cp *RpcClientManager) Request(rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
+	return cp.RequestWithContext(context.Background(), rpcClient, request, timeoutMills)
+}
+
+// RequestWithContext send the request in a span which is a child of the span in ctx. The request is rejected with
+// ErrRateLimited if it exceeds the limits of ClientConfig.RateLimitCfg, the limit of the key is applied if ctx is
+// returned by WithRateLimitKey.
+func (cp *RpcClientManager) RequestWithContext(ctx context.Context, rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) (rpc_response.IResponse, error) {
 	start := time.Now()
+	ctx, span := tracing.StartSpan(ctx, "xgrpc.request "+request.GetRequestType(), trace.SpanKindInternal,
+		tracing.RequestAttributes(rpcClient.Name, request)...)
+	if err := cp.acquire(ctx, rpcClient, request, timeoutMills); err != nil {
+		tracing.EndSpan(span, err)
+		return nil, err
+	}
+	monitor.GetRequestInFlightMonitor(rpcClient.Name, rpcClient.Tenant, request.GetRequestType()).Inc()
 	cp.xgrpcServer.InjectSecurityInfo(request.GetHeaders())
 	cp.injectCommHeader(request.GetHeaders())
-	cp.xgrpcServer.InjectSkAk(request.GetHeaders(), cp.clientConfig)
-	// TODO
-	// signHeaders := xgrpc_server.GetSignHeadersFromRequest(request.(rpc_request.IConfigRequest), cp.clientConfig.SecretKey)
-	// request.PutAllHeaders(signHeaders)
-	// TODO Config Limiter
-	response, err := rpcClient.Request(request, int64(timeoutMills))
-	monitor.GetConfigRequestMonitor(constant.GRPC, request.GetRequestType(), rpc_response.GetGrpcResponseStatusCode(response)).Observe(float64(time.Now().Nanosecond() - start.Nanosecond()))
+	cp.xgrpcServer.InjectSkAk(request.GetHeaders(), cp.xgrpcServer.GetClientConfig())
+	cp.xgrpcServer.SignRequest(request, cp.xgrpcServer.GetClientConfig())
+	response, err := rpcClient.RequestWithContext(ctx, request, int64(timeoutMills))
+	span.SetAttributes(tracing.RequestIdKey.String(request.GetRequestId()))
+	span.SetAttributes(tracing.ResponseAttributes(response)...)
+	tracing.EndSpan(span, err)
+	monitor.GetRequestInFlightMonitor(rpcClient.Name, rpcClient.Tenant, request.GetRequestType()).Dec()
+	code := rpc_response.GetGrpcResponseStatusCode(response)
+	monitor.ObserveWithRequestId(monitor.GetRequestDurationMonitor(rpcClient.Name, rpcClient.Tenant, request.GetRequestType(), code),
+		time.Since(start).Seconds(), request.GetRequestId())
+	if err != nil || !response.IsSuccess() {
+		monitor.GetRequestErrorMonitor(rpcClient.Name, rpcClient.Tenant, request.GetRequestType(), code).Inc()
+	}
 	return response, err
 }
 
+// acquire waits for the permits of the request at most timeoutMills if ctx has no deadline.
+func (cp *RpcClientManager) acquire(ctx context.Context, rpcClient *rpc.RpcClient, request rpc_request.IRequest, timeoutMills uint64) error {
+	if cp.limiter == nil {
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok && timeoutMills > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMills)*time.Millisecond)
+		defer cancel()
+	}
+	return cp.limiter.Acquire(ctx, rpcClient.Name, request.GetRequestType(), rateLimitKeyFrom(ctx))
+}
+
 func (cp *RpcClientManager) injectCommHeader(param map[string]string) {
 	now := strconv.FormatInt(util.CurrentMillis(), 10)
 	param[constant.CLIENT_APPNAME_HEADER] = cp.clientConfig.AppName
@@ -77,7 +124,12 @@ func (cp *RpcClientManager) injectCommHeader(param map[string]string) {
 	param[constant.CHARSET_KEY] = "utf-8"
 }
 
+// CreateRpcClient create the client of the tenant ClientConfig.NamespaceId.
 func (cp *RpcClientManager) CreateRpcClient(taskId string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient {
+	return cp.CreateRpcClientForTenant(cp.clientConfig.NamespaceId, taskId, labels, serverRequestHandlers)
+}
+
+func (cp *RpcClientManager) CreateRpcClientForTenant(tenant string, taskId string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient {
 	targetLabels := map[string]string{
 		constant.LABEL_SOURCE: constant.LABEL_SOURCE_SDK,
 		constant.LABEL_MODULE: constant.LABEL_MODULE_CONFIG,
@@ -88,7 +140,7 @@ func (cp *RpcClientManager) CreateRpcClient(taskId string, labels map[string]str
 		targetLabels[k] = v
 	}
 
-	iRpcClient, _ := rpc.CreateClient(cp.uid+"-"+taskId, rpc.GRPC, targetLabels, cp.xgrpcServer)
+	iRpcClient, _ := rpc.CreateClient(cp.clientName(tenant, taskId), rpc.GRPC, targetLabels, cp.xgrpcServer)
 	rpcClient := iRpcClient.GetRpcClient()
 	if !rpcClient.IsInitialized() {
 		// 如果不是等待初始化状态，则直接返回已有Client复用
@@ -100,7 +152,8 @@ func (cp *RpcClientManager) CreateRpcClient(taskId string, labels map[string]str
 		rpcClient.RegisterServerRequestHandler(v, k)
 	}
 
-	rpcClient.Tenant = cp.clientConfig.NamespaceId
+	rpcClient.Tenant = tenant
+	rpcClient.BiStreamRequest = cp.clientConfig.BiStreamRequest
 	rpcClient.Start()
 
 	return rpcClient
@@ -110,6 +163,24 @@ func (cp *RpcClientManager) GetRpcClient(labels map[string]string, serverRequest
 	return cp.CreateRpcClient("0", labels, serverRequestHandlers)
 }
 
+func (cp *RpcClientManager) GetRpcClientForTenant(tenant string, labels map[string]string, serverRequestHandlers map[rpc.IServerRequestHandler]func() rpc_request.IRequest) *rpc.RpcClient {
+	return cp.CreateRpcClientForTenant(tenant, "0", labels, serverRequestHandlers)
+}
+
+// clientName is the key of the cached clients, the name without tenant is kept for the empty tenant.
+func (cp *RpcClientManager) clientName(tenant string, taskId string) string {
+	if tenant == "" {
+		return cp.uid + "-" + taskId
+	}
+	return cp.uid + "-" + taskId + constant.SERVICE_INFO_SPLITER + tenant
+}
+
 func (cp *RpcClientManager) Close(rpcClient *rpc.RpcClient) {
 	rpcClient.Shutdown()
 }
+
+func (cp *RpcClientManager) Shutdown() {
+	if cp.limiter != nil {
+		cp.limiter.Close()
+	}
+}
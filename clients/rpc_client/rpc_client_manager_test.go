@@ -0,0 +1,82 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc_client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+)
+
+func TestClientNameOfTenant(t *testing.T) {
+	cp := &RpcClientManager{uid: "uid"}
+	assert.Equal(t, "uid-0", cp.clientName("", "0"))
+	assert.Equal(t, "uid-0@@tenant", cp.clientName("tenant", "0"))
+	assert.NotEqual(t, cp.clientName("tenant-a", "0"), cp.clientName("tenant-b", "0"))
+}
+
+func TestTenantRequestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	manager, err := NewRpcClientManager([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{MetricsRegisterer: registry}, &http_agent.HttpAgent{})
+	assert.Nil(t, err)
+	defer manager.Shutdown()
+
+	// the client isn't started, so the request fails without a connection
+	rpcClient := rpc.NewGrpcClient("test-tenant", nil).RpcClient
+	rpcClient.Tenant = "tenant-a"
+	_, err = manager.RequestWithContext(context.Background(), rpcClient, rpc_request.NewHealthCheckRequest(), 1)
+	assert.NotNil(t, err)
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	var found bool
+	for _, family := range families {
+		if family.GetName() != "xgrpc_client_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "tenant" && label.GetValue() == "tenant-a" {
+					found = true
+				}
+			}
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestNewRpcClientManagerWithUnknownAuthType(t *testing.T) {
+	manager, err := NewRpcClientManager([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "bogus"}}, &http_agent.HttpAgent{})
+	assert.NotNil(t, err)
+	assert.Nil(t, manager)
+}
+
+func TestNewRpcClientManagerWithUnresolvedSecret(t *testing.T) {
+	manager, err := NewRpcClientManager([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{SecretKey: "${env:XGRPC_TEST_SECRET_KEY_NOT_EXIST}"}, &http_agent.HttpAgent{})
+	assert.NotNil(t, err)
+	assert.Nil(t, manager)
+}
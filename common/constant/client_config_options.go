@@ -20,6 +20,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/allenliu88/xgrpc-client-go/common/file"
 )
 
@@ -34,6 +36,7 @@ func NewClientConfig(opts ...ClientOption) *ClientConfig {
 		UpdateCacheWhenEmpty: false,
 		LogDir:               file.GetCurrentPath() + string(os.PathSeparator) + "log",
 		LogLevel:             "info",
+		SignatureMethod:      SIGN_METHOD_HMAC_SHA256,
 	}
 
 	for _, opt := range opts {
@@ -184,3 +187,59 @@ func WithTLS(tlsCfg TLSConfig) ClientOption {
 		config.TLSCfg = tlsCfg
 	}
 }
+
+// WithHttpTransportConfig ...
+func WithHttpTransportConfig(httpTransportCfg HttpTransportConfig) ClientOption {
+	return func(config *ClientConfig) {
+		config.HttpTransportCfg = httpTransportCfg
+	}
+}
+
+// WithBiStreamRequest ...
+func WithBiStreamRequest(biStreamRequest bool) ClientOption {
+	return func(config *ClientConfig) {
+		config.BiStreamRequest = biStreamRequest
+	}
+}
+
+// WithMetricsRegisterer ...
+func WithMetricsRegisterer(registerer prometheus.Registerer) ClientOption {
+	return func(config *ClientConfig) {
+		config.MetricsRegisterer = registerer
+	}
+}
+
+// WithSignatureMethod ...
+func WithSignatureMethod(signatureMethod string) ClientOption {
+	return func(config *ClientConfig) {
+		config.SignatureMethod = signatureMethod
+	}
+}
+
+// WithAuthConfig ...
+func WithAuthConfig(authCfg AuthConfig) ClientOption {
+	return func(config *ClientConfig) {
+		config.AuthCfg = authCfg
+	}
+}
+
+// WithDiskCacheConfig ...
+func WithDiskCacheConfig(diskCacheCfg DiskCacheConfig) ClientOption {
+	return func(config *ClientConfig) {
+		config.DiskCacheCfg = diskCacheCfg
+	}
+}
+
+// WithRateLimitConfig ...
+func WithRateLimitConfig(rateLimitCfg RateLimitConfig) ClientOption {
+	return func(config *ClientConfig) {
+		config.RateLimitCfg = rateLimitCfg
+	}
+}
+
+// WithCircuitBreakerConfig ...
+func WithCircuitBreakerConfig(circuitBreakerCfg CircuitBreakerConfig) ClientOption {
+	return func(config *ClientConfig) {
+		config.CircuitBreakerCfg = circuitBreakerCfg
+	}
+}
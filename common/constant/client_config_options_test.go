@@ -22,6 +22,7 @@ import (
 
 	"github.com/allenliu88/xgrpc-client-go/common/file"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,9 +49,13 @@ func TestNewClientConfig(t *testing.T) {
 	assert.Equal(t, config.RegionId, "")
 	assert.Equal(t, config.AccessKey, "")
 	assert.Equal(t, config.SecretKey, "")
+	assert.Equal(t, config.BiStreamRequest, false)
+	assert.Nil(t, config.MetricsRegisterer)
+	assert.Equal(t, config.SignatureMethod, SIGN_METHOD_HMAC_SHA256)
 }
 
 func TestNewClientConfigWithOptions(t *testing.T) {
+	registerer := prometheus.NewRegistry()
 	config := NewClientConfig(
 		WithTimeoutMs(uint64(20000)),
 		WithEndpoint("http://console.xgrpc.io:80"),
@@ -71,6 +76,12 @@ func TestNewClientConfigWithOptions(t *testing.T) {
 		WithNamespaceId("namespace_1"),
 		WithAccessKey("accessKey_1"),
 		WithSecretKey("secretKey_1"),
+		WithBiStreamRequest(true),
+		WithMetricsRegisterer(registerer),
+		WithSignatureMethod(SIGN_METHOD_HMAC_SHA1),
+		WithDiskCacheConfig(DiskCacheConfig{EncryptKey: "secret", MaxSize: 1024}),
+		WithRateLimitConfig(RateLimitConfig{Mode: RATE_LIMIT_MODE_BLOCK, Global: RateLimit{Rate: 100}}),
+		WithCircuitBreakerConfig(CircuitBreakerConfig{Enable: true, ErrorRateThreshold: 30}),
 	)
 
 	assert.Equal(t, config.TimeoutMs, uint64(20000))
@@ -90,6 +101,12 @@ func TestNewClientConfigWithOptions(t *testing.T) {
 	assert.Equal(t, config.OpenKMS, true)
 	assert.Equal(t, config.RegionId, "shanghai")
 	assert.Equal(t, config.NamespaceId, "namespace_1")
+	assert.Equal(t, config.DiskCacheCfg, DiskCacheConfig{EncryptKey: "secret", MaxSize: 1024})
+	assert.Equal(t, config.RateLimitCfg, RateLimitConfig{Mode: RATE_LIMIT_MODE_BLOCK, Global: RateLimit{Rate: 100}})
+	assert.Equal(t, config.CircuitBreakerCfg, CircuitBreakerConfig{Enable: true, ErrorRateThreshold: 30})
 	assert.Equal(t, config.AccessKey, "accessKey_1")
 	assert.Equal(t, config.SecretKey, "secretKey_1")
+	assert.Equal(t, config.BiStreamRequest, true)
+	assert.Equal(t, config.MetricsRegisterer, registerer)
+	assert.Equal(t, config.SignatureMethod, SIGN_METHOD_HMAC_SHA1)
 }
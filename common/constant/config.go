@@ -16,7 +16,11 @@
 
 package constant
 
-import "time"
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type ServerConfig struct {
 	Scheme      string // the xgrpc server scheme,default=http,this is not required in 2.0
@@ -51,6 +55,14 @@ type ClientConfig struct {
 	LogSampling          *ClientLogSamplingConfig // the sampling config of log
 	LogRollingConfig     *ClientLogRollingConfig  // log rolling config
 	TLSCfg               TLSConfig                // tls Config
+	HttpTransportCfg     HttpTransportConfig      // the config of the shared http transport
+	BiStreamRequest      bool                     // send client requests over the bi-directional stream instead of unary calls, default is false
+	MetricsRegisterer    prometheus.Registerer    // the registerer of the client metrics, default is prometheus.DefaultRegisterer
+	AuthCfg              AuthConfig               // the config of the authentication provider
+	SignatureMethod      string                   // the signature method of grpc requests, HmacSHA256 or HmacSHA1, default is HmacSHA256
+	DiskCacheCfg         DiskCacheConfig          // the config of the service and config cache files in CacheDir
+	RateLimitCfg         RateLimitConfig          // the config of the client-side rate limits of the requests
+	CircuitBreakerCfg    CircuitBreakerConfig     // the config of the circuit breakers of the servers and the request types
 }
 
 type ClientLogSamplingConfig struct {
@@ -93,3 +105,59 @@ type TLSConfig struct {
 	KeyFile            string // server use when verifying client certificates
 	ServerNameOverride string // serverNameOverride is for testing only
 }
+
+type HttpTransportConfig struct {
+	MaxIdleConns          int           // max idle connections of all hosts, default is 100
+	MaxIdleConnsPerHost   int           // max idle connections of each host, default is 10
+	MaxConnsPerHost       int           // max connections of each host, default is no limit
+	IdleConnTimeout       time.Duration // the idle connection is closed after the timeout, default is 90s
+	DialTimeout           time.Duration // timeout of establishing a connection, default is 5s
+	KeepAlive             time.Duration // interval of tcp keep-alive probes, default is 30s
+	TLSHandshakeTimeout   time.Duration // timeout of the tls handshake, default is 10s
+	ResponseHeaderTimeout time.Duration // timeout of waiting for the response headers, default is no limit
+	ProxyUrl              string        // the proxy of all requests, the proxy envs are used if it's empty
+	DisableHTTP2          bool          // disable HTTP/2, default is false
+}
+
+type DiskCacheConfig struct {
+	EncryptKey string // the passphrase of encrypting the cache files by AES-256-GCM, they're not encrypted if empty
+	MaxSize    int64  // the max total bytes of the cache files of a directory, the least recently written ones are evicted, default is no limit
+}
+
+type RateLimitConfig struct {
+	Mode         string               // failFast rejects the limited requests, block waits for the permits within the request timeout, default is failFast
+	Global       RateLimit            // the limit of all requests, default is no limit
+	RequestTypes map[string]RateLimit // the limits of the request types, e.g. ConfigQueryRequest, default is no limit
+	PerKey       RateLimit            // the limit of each key, e.g. the config of GetConfig, default is 5 per second with the burst 5, no limit if the rate is negative
+}
+
+type RateLimit struct {
+	Rate  float64 // the permits per second, no limit if not positive
+	Burst int     // the max permits at once, default is the rate rounded up
+}
+
+type CircuitBreakerConfig struct {
+	Enable                bool    // whether to break the requests to the failing servers, default is false
+	WindowSize            int     // the number of the last calls the rates are computed from, default is 100
+	MinimumCalls          int     // the min number of the calls in the window before the circuit opens, default is 20
+	ErrorRateThreshold    float64 // the percentage of the failed calls at which the circuit opens, default is 50
+	SlowCallThresholdMs   uint64  // the duration in milliseconds above which a call is slow, default is 3000ms
+	SlowCallRateThreshold float64 // the percentage of the slow calls at which the circuit opens, default is 100
+	OpenDurationMs        uint64  // the duration in milliseconds the circuit stays open before half-open, default is 30000ms
+	HalfOpenCalls         int     // the number of the trial calls permitted when half-open, default is 5
+}
+
+type AuthConfig struct {
+	Type              string       // the registered type of AuthProvider, it's inferred from the other fields if empty
+	AccessToken       string       // the static bearer token
+	TokenFile         string       // the file which contains the token, it's re-read when changed
+	TokenFileInterval uint64       // the interval in milliseconds to check the token file, default is 1000ms
+	OAuth2            OAuth2Config // the config of OAuth2 client credentials grant
+}
+
+type OAuth2Config struct {
+	TokenUrl     string   // the token endpoint of the authorization server
+	ClientId     string   // the client id
+	ClientSecret string   // the client secret
+	Scopes       []string // the scopes to request
+}
@@ -43,6 +43,15 @@ const (
 	KEY_TOKEN_TTL               = "tokenTtl"
 	KEY_GLOBAL_ADMIN            = "globalAdmin"
 	KEY_TOKEN_REFRESH_WINDOW    = "tokenRefreshWindow"
+	AUTH_TYPE_LOGIN             = "login"
+	AUTH_TYPE_STATIC            = "static"
+	AUTH_TYPE_FILE              = "file"
+	AUTH_TYPE_OAUTH2            = "oauth2"
+	SIGN_METHOD_HEADER          = "Spas-SignatureMethod"
+	SIGN_METHOD_HMAC_SHA1       = "HmacSHA1"
+	SIGN_METHOD_HMAC_SHA256     = "HmacSHA256"
+	RATE_LIMIT_MODE_FAIL_FAST   = "failFast"
+	RATE_LIMIT_MODE_BLOCK       = "block"
 	WEB_CONTEXT                 = "/xgrpc"
 	CONFIG_BASE_PATH            = "/v1/cs"
 	CONFIG_PATH                 = CONFIG_BASE_PATH + "/configs"
@@ -52,7 +61,14 @@ const (
 	SERVICE_PATH                = SERVICE_BASE_PATH + "/instance"
 	SERVICE_INFO_PATH           = SERVICE_BASE_PATH + "/service"
 	SERVICE_SUBSCRIBE_PATH      = SERVICE_PATH + "/list"
+	SERVICE_BEAT_PATH           = SERVICE_PATH + "/beat"
 	NAMESPACE_PATH              = "/v1/console/namespaces"
+	CORE_BASE_PATH              = "/v1/core"
+	CONNECTION_LIST_PATH        = CORE_BASE_PATH + "/loader/current"
+	CONNECTION_RESET_PATH       = CORE_BASE_PATH + "/loader/reloadClient"
+	CLUSTER_NODES_PATH          = CORE_BASE_PATH + "/cluster/nodes"
+	HEALTH_READINESS_PATH       = "/v1/console/health/readiness"
+	HEALTH_LIVENESS_PATH        = "/v1/console/health/liveness"
 	SPLIT_CONFIG                = string(rune(1))
 	SPLIT_CONFIG_INNER          = string(rune(2))
 	KEY_LISTEN_CONFIGS          = "Listening-Configs"
@@ -84,7 +100,10 @@ const (
 	LABEL_MODULE_CONFIG         = "config"
 	LABEL_MODULE_NAMING         = "naming"
 	RESPONSE_CODE_SUCCESS       = 200
+	RESPONSE_CODE_UNAUTHORIZED  = 401
+	RESPONSE_CODE_FORBIDDEN     = 403
 	UN_REGISTER                 = 301
+	RESOURCE_NOT_FOUND          = 20404
 	KEEP_ALIVE_TIME             = 5
 	DEFAULT_TIMEOUT_MILLS       = 3000
 	ALL_SYNC_INTERNAL           = 5 * time.Minute
@@ -97,4 +116,10 @@ const (
 	HTTPS_SERVER_PORT           = 443
 	GRPC                        = "grpc"
 	FAILOVER_FILE_SUFFIX        = "_failover"
+	CHUNK_ID_HEADER             = "xgrpc.chunk.id"
+	CHUNK_SEQ_HEADER            = "xgrpc.chunk.seq"
+	CHUNK_TOTAL_HEADER          = "xgrpc.chunk.total"
+	CHUNK_SIZE_HEADER           = "xgrpc.chunk.size"
+	CHUNK_CHECKSUM_HEADER       = "xgrpc.chunk.checksum"
+	CHUNK_ACK_HEADER            = "xgrpc.chunk.ack"
 )
@@ -19,7 +19,6 @@ package http_agent
 import (
 	"net/http"
 	"strings"
-	"time"
 )
 
 func get(client *http.Client, path string, header http.Header, timeoutMs uint64, params map[string]string) (response *http.Response, err error) {
@@ -33,15 +32,13 @@ func get(client *http.Client, path string, header http.Header, timeoutMs uint64,
 	if strings.HasSuffix(path, "&") {
 		path = path[:len(path)-1]
 	}
-
-	client.Timeout = time.Millisecond * time.Duration(timeoutMs)
 	request, errNew := http.NewRequest(http.MethodGet, path, nil)
 	if errNew != nil {
 		err = errNew
 		return
 	}
 	request.Header = header
-	resp, errDo := client.Do(request)
+	resp, errDo := do(client, request, timeoutMs)
 
 	if errDo != nil {
 		err = errDo
@@ -17,8 +17,15 @@
 package http_agent
 
 import (
+	"context"
+	gotls "crypto/tls"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/tls"
@@ -28,13 +35,28 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 5 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// HttpAgent sends the requests by a http client shared by all requests, so the connections are reused.
+// It's safe for concurrent use, and the config must not be changed after the first request.
 type HttpAgent struct {
-	TlsConfig constant.TLSConfig
+	TlsConfig       constant.TLSConfig
+	TransportConfig constant.HttpTransportConfig
+	clientOnce      sync.Once
+	client          *http.Client
+	clientErr       error
 }
 
 func (agent *HttpAgent) Get(path string, header http.Header, timeoutMs uint64,
 	params map[string]string) (response *http.Response, err error) {
-	client, err := agent.createClient()
+	client, err := agent.getClient()
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +122,7 @@ func (agent *HttpAgent) Request(method string, path string, header http.Header,
 }
 func (agent *HttpAgent) Post(path string, header http.Header, timeoutMs uint64,
 	params map[string]string) (response *http.Response, err error) {
-	client, err := agent.createClient()
+	client, err := agent.getClient()
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +130,7 @@ func (agent *HttpAgent) Post(path string, header http.Header, timeoutMs uint64,
 }
 func (agent *HttpAgent) Delete(path string, header http.Header, timeoutMs uint64,
 	params map[string]string) (response *http.Response, err error) {
-	client, err := agent.createClient()
+	client, err := agent.getClient()
 	if err != nil {
 		return nil, err
 	}
@@ -116,21 +138,100 @@ func (agent *HttpAgent) Delete(path string, header http.Header, timeoutMs uint64
 }
 func (agent *HttpAgent) Put(path string, header http.Header, timeoutMs uint64,
 	params map[string]string) (response *http.Response, err error) {
-	client, err := agent.createClient()
+	client, err := agent.getClient()
 	if err != nil {
 		return nil, err
 	}
 	return put(client, path, header, timeoutMs, params)
 }
 
-func (agent *HttpAgent) createClient() (*http.Client, error) {
-	if !agent.TlsConfig.Enable {
-		return &http.Client{}, nil
+// getClient returns the shared client, the tls files are read only once.
+func (agent *HttpAgent) getClient() (*http.Client, error) {
+	agent.clientOnce.Do(func() {
+		transport, err := newTransport(agent.TlsConfig, agent.TransportConfig)
+		if err != nil {
+			agent.clientErr = err
+			return
+		}
+		agent.client = &http.Client{Transport: transport}
+	})
+	return agent.client, agent.clientErr
+}
+
+func newTransport(tlsCfg constant.TLSConfig, cfg constant.HttpTransportConfig) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(cfg.ProxyUrl)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse proxy url %s failed", cfg.ProxyUrl)
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(cfg.DialTimeout, defaultDialTimeout),
+		KeepAlive: durationOrDefault(cfg.KeepAlive, defaultKeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          intOrDefault(cfg.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOrDefault(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       durationOrDefault(cfg.IdleConnTimeout, defaultIdleConnTimeout),
+		TLSHandshakeTimeout:   durationOrDefault(cfg.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+	}
+	if cfg.DisableHTTP2 {
+		// a non-nil empty map disables HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *gotls.Conn) http.RoundTripper{}
+	}
+	if tlsCfg.Enable {
+		tlsClientConfig, err := tls.NewTLS(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsClientConfig
+	}
+	return transport, nil
+}
+
+// do send the request with the deadline of timeoutMs, the deadline covers reading the response body,
+// and it's released when the body is closed. There's no deadline if timeoutMs is 0.
+func do(client *http.Client, request *http.Request, timeoutMs uint64) (*http.Response, error) {
+	if timeoutMs == 0 {
+		return client.Do(request)
 	}
-	cfg, err := tls.NewTLS(agent.TlsConfig)
+	ctx, cancel := context.WithTimeout(request.Context(), time.Millisecond*time.Duration(timeoutMs))
+	resp, err := client.Do(request.WithContext(ctx))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}, nil
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
 
+func intOrDefault(value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
 }
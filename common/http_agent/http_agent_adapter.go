@@ -0,0 +1,169 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http_agent
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// NewHttpAgentAdapter adapts IHttpAgentV2 to IHttpAgent, the response with a status code other than 2xx
+// is returned as the response instead of the error, just like HttpAgent.
+func NewHttpAgentAdapter(agent IHttpAgentV2) IHttpAgent {
+	return &httpAgentAdapter{agent: agent}
+}
+
+// AsHttpAgentV2 returns the agent if it implements IHttpAgentV2, otherwise it's adapted, and the adapted
+// agent only supports the requests without body or with the form body, which is sent as the params.
+func AsHttpAgentV2(agent IHttpAgent) IHttpAgentV2 {
+	if agentV2, ok := agent.(IHttpAgentV2); ok {
+		return agentV2
+	}
+	return &legacyHttpAgent{agent: agent}
+}
+
+type httpAgentAdapter struct {
+	agent IHttpAgentV2
+}
+
+func (a *httpAgentAdapter) Get(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodGet, path, header, timeoutMs, params)
+}
+
+func (a *httpAgentAdapter) Post(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodPost, path, header, timeoutMs, params)
+}
+
+func (a *httpAgentAdapter) Delete(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodDelete, path, header, timeoutMs, params)
+}
+
+func (a *httpAgentAdapter) Put(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodPut, path, header, timeoutMs, params)
+}
+
+func (a *httpAgentAdapter) Request(method string, path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	ctx := context.Background()
+	if timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	request := NewFormRequest(method, path, params)
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	resp, err := a.agent.Do(ctx, request)
+	if httpErr, ok := IsHttpError(err); ok {
+		return newResponse(httpErr.StatusCode, nil, httpErr.Body), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(resp.StatusCode, resp.Header, resp.Body), nil
+}
+
+func (a *httpAgentAdapter) RequestOnlyResult(method string, path string, header http.Header, timeoutMs uint64, params map[string]string) string {
+	resp, err := a.Request(method, path, header, timeoutMs, params)
+	if err != nil {
+		logger.Errorf("request method[%s],request path[%s],header:[%s],params:[%s],err:%+v", method, path, util.ToJsonString(header), util.ToJsonString(params), err)
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Errorf("request method[%s],request path[%s],header:[%s],params:[%s],status code error:%d", method, path, util.ToJsonString(header), util.ToJsonString(params), resp.StatusCode)
+		return ""
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+func newResponse(statusCode int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:     http.StatusText(statusCode),
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+type legacyHttpAgent struct {
+	agent IHttpAgent
+}
+
+func (a *legacyHttpAgent) Do(ctx context.Context, request *HttpRequest) (*HttpResponse, error) {
+	params, err := legacyParams(request)
+	if err != nil {
+		return nil, err
+	}
+	var timeoutMs uint64
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		timeoutMs = uint64(remaining / time.Millisecond)
+	}
+	resp, err := a.agent.Request(request.Method, request.Url, request.Header, timeoutMs, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readBody(resp.Body, request.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HttpError{Method: request.Method, Url: request.Url, StatusCode: resp.StatusCode, Body: body}
+	}
+	return &HttpResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// legacyParams merges the query and the form body into the params of IHttpAgent.
+func legacyParams(request *HttpRequest) (map[string]string, error) {
+	if len(request.Body) == 0 {
+		return request.Query, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return nil, errors.Errorf("the request body of %s is not supported by IHttpAgent", mediaType)
+	}
+	form, err := url.ParseQuery(string(request.Body))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse the form body")
+	}
+	params := make(map[string]string, len(request.Query)+len(form))
+	for k, v := range request.Query {
+		params[k] = v
+	}
+	for k := range form {
+		params[k] = form.Get(k)
+	}
+	return params, nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http_agent
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+func TestHttpAgentReuseConnections(t *testing.T) {
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	agent := &HttpAgent{}
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, "ok", agent.RequestOnlyResult(http.MethodGet, server.URL, nil, 3000, nil))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&newConns))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "ok", agent.RequestOnlyResult(http.MethodPost, server.URL, nil, 3000, map[string]string{"k": "v"}))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestHttpAgentTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	agent := &HttpAgent{}
+	_, err := agent.Get(server.URL, nil, 50, nil)
+	assert.NotNil(t, err)
+
+	// the deadline isn't exceeded before the body is read.
+	resp, err := agent.Get(server.URL, nil, 3000, nil)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Body.Close())
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestNewTransport(t *testing.T) {
+	transport, err := newTransport(constant.TLSConfig{}, constant.HttpTransportConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	transport, err = newTransport(constant.TLSConfig{}, constant.HttpTransportConfig{
+		MaxIdleConnsPerHost: 32,
+		ProxyUrl:            "http://127.0.0.1:3128",
+		DisableHTTP2:        true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 32, transport.MaxIdleConnsPerHost)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://xgrpc.io", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:3128", proxy.Host)
+
+	_, err = newTransport(constant.TLSConfig{}, constant.HttpTransportConfig{ProxyUrl: "://bad"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http_agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxResponseSize is the max size of the response body read by IHttpAgentV2 if it's not set in the request.
+const DefaultMaxResponseSize = 16 * 1024 * 1024
+
+// ErrResponseTooLarge is returned when the response body exceeds the max response size.
+var ErrResponseTooLarge = errors.New("http response body too large")
+
+// IHttpAgentV2 sends the request with the deadline and the cancellation of ctx,
+// the response body is read and the response with a status code other than 2xx is returned as *HttpError.
+type IHttpAgentV2 interface {
+	Do(ctx context.Context, request *HttpRequest) (*HttpResponse, error)
+}
+
+type HttpRequest struct {
+	Method          string
+	Url             string
+	Header          http.Header
+	Query           map[string]string // encoded into the query string of the url
+	Body            []byte
+	MaxResponseSize int64 // DefaultMaxResponseSize is used if it's 0
+}
+
+type HttpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// HttpError is the response with a status code other than 2xx.
+type HttpError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("request %s %s failed, status code:%d, body:%s", e.Method, e.Url, e.StatusCode, string(e.Body))
+}
+
+// NewJsonRequest create a request with the json body.
+func NewJsonRequest(method string, url string, body interface{}) (*HttpRequest, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &HttpRequest{
+		Method: method,
+		Url:    url,
+		Header: http.Header{"Content-Type": []string{"application/json;charset=utf-8"}},
+		Body:   data,
+	}, nil
+}
+
+// NewFormRequest create a request with the params, which are encoded into the body of POST and PUT,
+// and the query string of the others.
+func NewFormRequest(method string, url string, params map[string]string) *HttpRequest {
+	request := &HttpRequest{Method: method, Url: url, Header: http.Header{}}
+	if method == http.MethodPost || method == http.MethodPut {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+		request.Body = []byte(encodeParams(params))
+	} else {
+		request.Query = params
+	}
+	return request
+}
+
+// DecodeJson unmarshal the json body of the response.
+func (r *HttpResponse) DecodeJson(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// IsHttpError returns the HttpError in the chain of err.
+func IsHttpError(err error) (*HttpError, bool) {
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	return nil, false
+}
+
+func (agent *HttpAgent) Do(ctx context.Context, request *HttpRequest) (*HttpResponse, error) {
+	client, err := agent.getClient()
+	if err != nil {
+		return nil, err
+	}
+	requestUrl, err := buildUrl(request.Url, request.Query)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, requestUrl, bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, err
+	}
+	if request.Header != nil {
+		httpRequest.Header = request.Header.Clone()
+	}
+	resp, err := client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readBody(resp.Body, request.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HttpError{Method: request.Method, Url: request.Url, StatusCode: resp.StatusCode, Body: body}
+	}
+	return &HttpResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+func readBody(body io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+func buildUrl(rawUrl string, query map[string]string) (string, error) {
+	if len(query) == 0 {
+		return rawUrl, nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	values := u.Query()
+	for k, v := range query {
+		values.Set(k, v)
+	}
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+func encodeParams(params map[string]string) string {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http_agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpAgentDoJson(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "application/json"))
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	request, err := NewJsonRequest(http.MethodPost, server.URL, map[string]string{"name": "xgrpc"})
+	assert.Nil(t, err)
+	resp, err := (&HttpAgent{}).Do(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var result map[string]string
+	assert.Nil(t, resp.DecodeJson(&result))
+	assert.Equal(t, "xgrpc", result["name"])
+}
+
+func TestHttpAgentDoQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Query().Get("k")))
+	}))
+	defer server.Close()
+
+	resp, err := (&HttpAgent{}).Do(context.Background(), NewFormRequest(http.MethodGet, server.URL, map[string]string{"k": "v"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "v", string(resp.Body))
+}
+
+func TestHttpAgentDoHttpError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("unavailable"))
+	}))
+	defer server.Close()
+
+	_, err := (&HttpAgent{}).Do(context.Background(), NewFormRequest(http.MethodGet, server.URL, nil))
+	httpErr, ok := IsHttpError(errors.Wrap(err, "wrapped"))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+	assert.Equal(t, "unavailable", string(httpErr.Body))
+}
+
+func TestHttpAgentDoResponseTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 11)))
+	}))
+	defer server.Close()
+
+	request := NewFormRequest(http.MethodGet, server.URL, nil)
+	request.MaxResponseSize = 10
+	_, err := (&HttpAgent{}).Do(context.Background(), request)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+
+	request.MaxResponseSize = 11
+	resp, err := (&HttpAgent{}).Do(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, 11, len(resp.Body))
+}
+
+func TestHttpAgentDoContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := (&HttpAgent{}).Do(ctx, NewFormRequest(http.MethodGet, server.URL, nil))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestHttpAgentAdapter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(r.Header.Get("k")))
+	}))
+	defer server.Close()
+
+	agent := NewHttpAgentAdapter(&HttpAgent{})
+	header := http.Header{}
+	header.Set("k", "v")
+	assert.Equal(t, "v", agent.RequestOnlyResult(http.MethodGet, server.URL, header, 3000, nil))
+
+	resp, err := agent.Get(server.URL, header, 3000, map[string]string{"fail": "true"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "", agent.RequestOnlyResult(http.MethodGet, server.URL, header, 3000, map[string]string{"fail": "true"}))
+
+	// the legacy agent reports the status code as the typed error
+	legacy := AsHttpAgentV2(agent)
+	request := NewFormRequest(http.MethodGet, server.URL, map[string]string{"fail": "true"})
+	request.Header.Set("k", "v")
+	_, err = legacy.Do(context.Background(), request)
+	httpErr, ok := IsHttpError(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusInternalServerError, httpErr.StatusCode)
+	assert.Equal(t, "v", string(httpErr.Body))
+}
+
+func TestLegacyHttpAgentFormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + ":" + r.PostFormValue("ip")))
+	}))
+	defer server.Close()
+
+	legacy := AsHttpAgentV2(NewHttpAgentAdapter(&HttpAgent{}))
+	resp, err := legacy.Do(context.Background(), NewFormRequest(http.MethodPut, server.URL, map[string]string{"ip": "127.0.0.1"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "PUT:127.0.0.1", string(resp.Body))
+
+	request := &HttpRequest{Method: http.MethodPost, Url: server.URL, Header: http.Header{}, Body: []byte("{}")}
+	request.Header.Set("Content-Type", "application/json")
+	_, err = legacy.Do(context.Background(), request)
+	assert.NotNil(t, err)
+}
@@ -18,13 +18,11 @@ package http_agent
 import (
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/allenliu88/xgrpc-client-go/util"
 )
 
 func post(client *http.Client, path string, header http.Header, timeoutMs uint64, params map[string]string) (response *http.Response, err error) {
-	client.Timeout = time.Millisecond * time.Duration(timeoutMs)
 
 	body := util.GetUrlFormedMap(params)
 	request, errNew := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
@@ -33,7 +31,7 @@ func post(client *http.Client, path string, header http.Header, timeoutMs uint64
 		return
 	}
 	request.Header = header
-	resp, errDo := client.Do(request)
+	resp, errDo := do(client, request, timeoutMs)
 	if errDo != nil {
 		err = errDo
 	} else {
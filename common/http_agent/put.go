@@ -19,11 +19,9 @@ package http_agent
 import (
 	"net/http"
 	"strings"
-	"time"
 )
 
 func put(client *http.Client, path string, header http.Header, timeoutMs uint64, params map[string]string) (response *http.Response, err error) {
-	client.Timeout = time.Millisecond * time.Duration(timeoutMs)
 	var body string
 	for key, value := range params {
 		if len(value) > 0 {
@@ -39,7 +37,7 @@ func put(client *http.Client, path string, header http.Header, timeoutMs uint64,
 		return
 	}
 	request.Header = header
-	resp, errDo := client.Do(request)
+	resp, errDo := do(client, request, timeoutMs)
 	if errDo != nil {
 		err = errDo
 	} else {
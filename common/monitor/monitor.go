@@ -27,11 +27,97 @@ var (
 		Name: "xgrpc_client_request",
 		Help: "xgrpc_client_request",
 	}, []string{"module", "method", "url", "code"})
+	requestDurationVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "xgrpc_client_request_duration_seconds",
+		Help: "Latency of the requests sent by the client, including retries.",
+	}, []string{"client", "tenant", "request_type", "code"})
+	requestInFlightVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xgrpc_client_requests_in_flight",
+		Help: "Number of the requests being sent by the client.",
+	}, []string{"client", "tenant", "request_type"})
+	requestErrorsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_request_errors_total",
+		Help: "Number of the requests failed or answered with an error code.",
+	}, []string{"client", "tenant", "request_type", "code"})
+	requestRetriesVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_request_retries_total",
+		Help: "Number of the retried attempts of the requests.",
+	}, []string{"client", "tenant", "request_type"})
+	pushDurationVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "xgrpc_client_push_handle_duration_seconds",
+		Help: "Latency of handling the requests pushed by the server.",
+	}, []string{"client", "request_type", "code"})
+	pushInFlightVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xgrpc_client_pushes_in_flight",
+		Help: "Number of the requests pushed by the server being handled.",
+	}, []string{"client", "request_type"})
+	connectionStateVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xgrpc_client_connection_state",
+		Help: "State of the client, 1 for the current state and 0 for the others.",
+	}, []string{"client", "state"})
+	reconnectsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_reconnects_total",
+		Help: "Number of the reconnections of the client.",
+	}, []string{"client", "result"})
+	healthChecksVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_health_checks_total",
+		Help: "Number of the health checks sent by the client.",
+	}, []string{"client", "result"})
+	redosVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_redos_total",
+		Help: "Number of the intents replayed by the client after reconnecting.",
+	}, []string{"client", "result"})
+	cacheRequestsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_cache_requests_total",
+		Help: "Number of the lookups of the named caches by result, hit or miss.",
+	}, []string{"cache", "result"})
+	cacheEvictionsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_cache_evictions_total",
+		Help: "Number of the entries removed from the named caches by reason.",
+	}, []string{"cache", "reason"})
+	throttledVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_requests_throttled_total",
+		Help: "Number of the requests rejected or delayed by the client-side rate limits.",
+	}, []string{"client", "request_type", "scope", "result"})
+	circuitTransitionsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_circuit_transitions_total",
+		Help: "Number of the state changes of the circuit breakers.",
+	}, []string{"client", "server", "request_type", "from", "to"})
+	circuitRejectedVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xgrpc_client_circuit_rejected_total",
+		Help: "Number of the requests rejected by the open circuit breakers.",
+	}, []string{"client", "server", "request_type"})
 )
 
-// register collectors vec
-func init() {
-	prometheus.MustRegister(gaugeMonitorVec, histogramMonitorVec)
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	ThrottleRejected = "rejected"
+	ThrottleDelayed  = "delayed"
+)
+
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{gaugeMonitorVec, histogramMonitorVec, requestDurationVec, requestInFlightVec,
+		requestErrorsVec, requestRetriesVec, pushDurationVec, pushInFlightVec, connectionStateVec, reconnectsVec,
+		healthChecksVec, redosVec, cacheRequestsVec, cacheEvictionsVec,
+		throttledVec, circuitTransitionsVec, circuitRejectedVec}
+}
+
+// Register register the collectors of xgrpc client to the registerer, prometheus.DefaultRegisterer is used if
+// registerer is nil. Registering to the same registerer more than once is allowed.
+func Register(registerer prometheus.Registerer) error {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	for _, collector := range collectors() {
+		if err := registerer.Register(collector); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // get gauge with labels and use gaugeMonitorVec
@@ -56,6 +142,16 @@ func GetHistogramWithLabels(labels ...string) prometheus.Observer {
 	return histogramMonitorVec.WithLabelValues(labels...)
 }
 
+// ObserveWithRequestId observe the value and attach the request id as exemplar if the observer supports exemplars.
+func ObserveWithRequestId(observer prometheus.Observer, value float64, requestId string) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && requestId != "" {
+		exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"requestId": requestId})
+		return
+	}
+	observer.Observe(value)
+}
+
+// Deprecated: use GetRequestDurationMonitor, it's kept for the http requests.
 func GetConfigRequestMonitor(method, url, code string) prometheus.Observer {
 	return GetHistogramWithLabels("config", method, url, code)
 }
@@ -63,3 +159,63 @@ func GetConfigRequestMonitor(method, url, code string) prometheus.Observer {
 func GetNamingRequestMonitor(method, url, code string) prometheus.Observer {
 	return GetHistogramWithLabels("naming", method, url, code)
 }
+
+func GetRequestDurationMonitor(client, tenant, requestType, code string) prometheus.Observer {
+	return requestDurationVec.WithLabelValues(client, tenant, requestType, code)
+}
+
+func GetRequestInFlightMonitor(client, tenant, requestType string) prometheus.Gauge {
+	return requestInFlightVec.WithLabelValues(client, tenant, requestType)
+}
+
+func GetRequestErrorMonitor(client, tenant, requestType, code string) prometheus.Counter {
+	return requestErrorsVec.WithLabelValues(client, tenant, requestType, code)
+}
+
+func GetRequestRetryMonitor(client, tenant, requestType string) prometheus.Counter {
+	return requestRetriesVec.WithLabelValues(client, tenant, requestType)
+}
+
+func GetPushDurationMonitor(client, requestType, code string) prometheus.Observer {
+	return pushDurationVec.WithLabelValues(client, requestType, code)
+}
+
+func GetPushInFlightMonitor(client, requestType string) prometheus.Gauge {
+	return pushInFlightVec.WithLabelValues(client, requestType)
+}
+
+func GetConnectionStateMonitor(client, state string) prometheus.Gauge {
+	return connectionStateVec.WithLabelValues(client, state)
+}
+
+func GetReconnectMonitor(client, result string) prometheus.Counter {
+	return reconnectsVec.WithLabelValues(client, result)
+}
+
+func GetHealthCheckMonitor(client, result string) prometheus.Counter {
+	return healthChecksVec.WithLabelValues(client, result)
+}
+
+func GetRedoMonitor(client, result string) prometheus.Counter {
+	return redosVec.WithLabelValues(client, result)
+}
+
+func GetCacheRequestMonitor(cache, result string) prometheus.Counter {
+	return cacheRequestsVec.WithLabelValues(cache, result)
+}
+
+func GetCacheEvictionMonitor(cache, reason string) prometheus.Counter {
+	return cacheEvictionsVec.WithLabelValues(cache, reason)
+}
+
+func GetThrottledMonitor(client, requestType, scope, result string) prometheus.Counter {
+	return throttledVec.WithLabelValues(client, requestType, scope, result)
+}
+
+func GetCircuitTransitionMonitor(client, server, requestType, from, to string) prometheus.Counter {
+	return circuitTransitionsVec.WithLabelValues(client, server, requestType, from, to)
+}
+
+func GetCircuitRejectedMonitor(client, server, requestType string) prometheus.Counter {
+	return circuitRejectedVec.WithLabelValues(client, server, requestType)
+}
@@ -3,6 +3,8 @@ package monitor
 import (
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +39,29 @@ func TestHistorgam(t *testing.T) {
 		assert.NotNil(t, monitor)
 	})
 }
+
+func TestObserveWithRequestId(t *testing.T) {
+	monitor := GetConfigRequestMonitor("grpc", "HealthCheckRequest", "200")
+	assert.NotPanics(t, func() {
+		ObserveWithRequestId(monitor, 1, "request-id")
+		ObserveWithRequestId(monitor, 1, "")
+	})
+}
+
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, Register(registry))
+	// registering twice is allowed.
+	assert.Nil(t, Register(registry))
+
+	failures := testutil.ToFloat64(GetRequestErrorMonitor("client", "", "HealthCheckRequest", "500"))
+	GetRequestErrorMonitor("client", "", "HealthCheckRequest", "500").Inc()
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "xgrpc_client_request_errors_total")
+	assert.Equal(t, failures+1, testutil.ToFloat64(GetRequestErrorMonitor("client", "", "HealthCheckRequest", "500")))
+}
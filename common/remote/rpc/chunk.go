@@ -0,0 +1,225 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"hash/crc32"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+
+	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+const (
+	// chunkExpireTime is how long an incomplete chunk sequence is kept before it is dropped.
+	chunkExpireTime = 30 * time.Second
+	// maxChunkTotal is the max chunk count of a sequence, the count is bounded by the declared size as well
+	// since every chunk carries one byte at least.
+	maxChunkTotal = 1 << 16
+)
+
+// isChunk check whether the payload is one piece of a chunked payload.
+func isChunk(p *xgrpc_grpc_service.Payload) bool {
+	_, ok := p.GetMetadata().GetHeaders()[constant.CHUNK_ID_HEADER]
+	return ok
+}
+
+// splitPayload splits the payload body into chunks of at most chunkSize bytes.
+// Every chunk carries a copy of the original metadata plus the chunk id, sequence number,
+// total chunk count, total body size and the crc32 checksum of the chunk body.
+// The payload is returned as is when its body fits into a single chunk.
+func splitPayload(p *xgrpc_grpc_service.Payload, chunkId string, chunkSize int) []*xgrpc_grpc_service.Payload {
+	body := p.GetBody().GetValue()
+	if chunkSize <= 0 || len(body) <= chunkSize {
+		return []*xgrpc_grpc_service.Payload{p}
+	}
+	total := (len(body) + chunkSize - 1) / chunkSize
+	chunks := make([]*xgrpc_grpc_service.Payload, 0, total)
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+		value := body[seq*chunkSize : end]
+		headers := make(map[string]string, len(p.GetMetadata().GetHeaders())+5)
+		for k, v := range p.GetMetadata().GetHeaders() {
+			headers[k] = v
+		}
+		headers[constant.CHUNK_ID_HEADER] = chunkId
+		headers[constant.CHUNK_SEQ_HEADER] = strconv.Itoa(seq)
+		headers[constant.CHUNK_TOTAL_HEADER] = strconv.Itoa(total)
+		headers[constant.CHUNK_SIZE_HEADER] = strconv.Itoa(len(body))
+		headers[constant.CHUNK_CHECKSUM_HEADER] = strconv.FormatUint(uint64(crc32.ChecksumIEEE(value)), 16)
+		chunks = append(chunks, &xgrpc_grpc_service.Payload{
+			Metadata: &xgrpc_grpc_service.Metadata{
+				Type:     p.GetMetadata().GetType(),
+				ClientIp: p.GetMetadata().GetClientIp(),
+				Headers:  headers,
+			},
+			Body: &any.Any{TypeUrl: p.GetBody().GetTypeUrl(), Value: value},
+		})
+	}
+	return chunks
+}
+
+type chunkBuffer struct {
+	total         int
+	size          int
+	received      int
+	receivedBytes int
+	chunks        [][]byte
+	metadata      *xgrpc_grpc_service.Metadata
+	typeUrl       string
+	createTime    time.Time
+}
+
+// chunkAssembler reassembles chunked payloads, the bytes held by incomplete sequences
+// are limited by maxBufferedBytes. The declared size of a sequence is reserved when its first chunk
+// arrives, and the sequence is dropped once the received bytes exceed the declared size.
+type chunkAssembler struct {
+	mux              sync.Mutex
+	buffers          map[string]*chunkBuffer
+	bufferedBytes    int
+	maxBufferedBytes int
+}
+
+func newChunkAssembler(maxBufferedBytes int) *chunkAssembler {
+	return &chunkAssembler{
+		buffers:          make(map[string]*chunkBuffer, 8),
+		maxBufferedBytes: maxBufferedBytes,
+	}
+}
+
+// accept adds the payload to the reassembly buffer. It returns the reassembled payload once the
+// last chunk arrives, nil while the sequence is incomplete, and the payload itself when it is not a chunk.
+func (a *chunkAssembler) accept(p *xgrpc_grpc_service.Payload) (*xgrpc_grpc_service.Payload, error) {
+	if !isChunk(p) {
+		return p, nil
+	}
+	headers := p.GetMetadata().GetHeaders()
+	chunkId := headers[constant.CHUNK_ID_HEADER]
+	seq, err := strconv.Atoi(headers[constant.CHUNK_SEQ_HEADER])
+	if err != nil {
+		return nil, errors.Wrapf(err, "chunk %s has invalid sequence", chunkId)
+	}
+	size, err := strconv.Atoi(headers[constant.CHUNK_SIZE_HEADER])
+	if err != nil || size < 0 {
+		return nil, errors.Errorf("chunk %s has invalid size:%s", chunkId, headers[constant.CHUNK_SIZE_HEADER])
+	}
+	total, err := strconv.Atoi(headers[constant.CHUNK_TOTAL_HEADER])
+	if err != nil || total <= 0 || total > maxChunkTotal || (total > size && total > 1) {
+		return nil, errors.Errorf("chunk %s has invalid total count:%s, size:%d", chunkId,
+			headers[constant.CHUNK_TOTAL_HEADER], size)
+	}
+	value := p.GetBody().GetValue()
+	checksum := strconv.FormatUint(uint64(crc32.ChecksumIEEE(value)), 16)
+	if checksum != headers[constant.CHUNK_CHECKSUM_HEADER] {
+		a.discard(chunkId)
+		return nil, errors.Errorf("chunk %s seq %d checksum mismatch, expect:%s, actual:%s", chunkId, seq,
+			headers[constant.CHUNK_CHECKSUM_HEADER], checksum)
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.removeExpired()
+	buffer, ok := a.buffers[chunkId]
+	if !ok {
+		if a.bufferedBytes+size > a.maxBufferedBytes {
+			return nil, errors.Errorf("chunk %s of %d bytes exceeds reassembly buffer limit, buffered:%d, limit:%d",
+				chunkId, size, a.bufferedBytes, a.maxBufferedBytes)
+		}
+		buffer = &chunkBuffer{
+			total:      total,
+			size:       size,
+			chunks:     make([][]byte, total),
+			metadata:   p.GetMetadata(),
+			typeUrl:    p.GetBody().GetTypeUrl(),
+			createTime: time.Now(),
+		}
+		a.buffers[chunkId] = buffer
+		a.bufferedBytes += size
+	}
+	if seq < 0 || seq >= buffer.total || total != buffer.total {
+		a.removeBuffer(chunkId)
+		return nil, errors.Errorf("chunk %s seq %d out of range, total:%d", chunkId, seq, buffer.total)
+	}
+	if buffer.chunks[seq] == nil {
+		if buffer.receivedBytes+len(value) > buffer.size {
+			a.removeBuffer(chunkId)
+			return nil, errors.Errorf("chunk %s received %d bytes more than declared size %d", chunkId,
+				buffer.receivedBytes+len(value), buffer.size)
+		}
+		buffer.chunks[seq] = value
+		buffer.received++
+		buffer.receivedBytes += len(value)
+	}
+	if buffer.received < buffer.total {
+		return nil, nil
+	}
+
+	a.removeBuffer(chunkId)
+	body := make([]byte, 0, buffer.size)
+	for _, chunk := range buffer.chunks {
+		body = append(body, chunk...)
+	}
+	if len(body) != buffer.size {
+		return nil, errors.Errorf("chunk %s reassembled size %d not equal to declared size %d", chunkId, len(body), buffer.size)
+	}
+	metadataHeaders := make(map[string]string, len(buffer.metadata.GetHeaders()))
+	for k, v := range buffer.metadata.GetHeaders() {
+		switch k {
+		case constant.CHUNK_ID_HEADER, constant.CHUNK_SEQ_HEADER, constant.CHUNK_TOTAL_HEADER,
+			constant.CHUNK_SIZE_HEADER, constant.CHUNK_CHECKSUM_HEADER:
+			continue
+		}
+		metadataHeaders[k] = v
+	}
+	return &xgrpc_grpc_service.Payload{
+		Metadata: &xgrpc_grpc_service.Metadata{
+			Type:     buffer.metadata.GetType(),
+			ClientIp: buffer.metadata.GetClientIp(),
+			Headers:  metadataHeaders,
+		},
+		Body: &any.Any{TypeUrl: buffer.typeUrl, Value: body},
+	}, nil
+}
+
+func (a *chunkAssembler) discard(chunkId string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.removeBuffer(chunkId)
+}
+
+func (a *chunkAssembler) removeBuffer(chunkId string) {
+	if buffer, ok := a.buffers[chunkId]; ok {
+		a.bufferedBytes -= buffer.size
+		delete(a.buffers, chunkId)
+	}
+}
+
+func (a *chunkAssembler) removeExpired() {
+	for chunkId, buffer := range a.buffers {
+		if time.Since(buffer.createTime) > chunkExpireTime {
+			a.removeBuffer(chunkId)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+
+	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+func newTestPayload(body string) *xgrpc_grpc_service.Payload {
+	return &xgrpc_grpc_service.Payload{
+		Metadata: &xgrpc_grpc_service.Metadata{
+			Type:    "DemoRequest",
+			Headers: map[string]string{"module": "demo"},
+		},
+		Body: &any.Any{Value: []byte(body)},
+	}
+}
+
+func TestSplitPayload(t *testing.T) {
+	p := newTestPayload("hello")
+	chunks := splitPayload(p, "1", 10)
+	assert.Equal(t, 1, len(chunks))
+	assert.False(t, isChunk(chunks[0]))
+
+	chunks = splitPayload(newTestPayload(strings.Repeat("a", 25)), "1", 10)
+	assert.Equal(t, 3, len(chunks))
+	for i, chunk := range chunks {
+		assert.True(t, isChunk(chunk))
+		assert.Equal(t, "DemoRequest", chunk.GetMetadata().GetType())
+		assert.Equal(t, "demo", chunk.GetMetadata().GetHeaders()["module"])
+		assert.Equal(t, "3", chunk.GetMetadata().GetHeaders()[constant.CHUNK_TOTAL_HEADER])
+		assert.Equal(t, "25", chunk.GetMetadata().GetHeaders()[constant.CHUNK_SIZE_HEADER])
+		if i < 2 {
+			assert.Equal(t, 10, len(chunk.GetBody().GetValue()))
+		}
+	}
+	assert.Equal(t, 5, len(chunks[2].GetBody().GetValue()))
+}
+
+func TestChunkAssembler(t *testing.T) {
+	body := strings.Repeat("abcdefg", 10)
+	chunks := splitPayload(newTestPayload(body), "1", 8)
+
+	t.Run("outOfOrder", func(t *testing.T) {
+		assembler := newChunkAssembler(1024)
+		var result *xgrpc_grpc_service.Payload
+		for i := len(chunks) - 1; i >= 0; i-- {
+			p, err := assembler.accept(chunks[i])
+			assert.Nil(t, err)
+			if i > 0 {
+				assert.Nil(t, p)
+			}
+			result = p
+		}
+		assert.Equal(t, body, string(result.GetBody().GetValue()))
+		assert.Equal(t, "DemoRequest", result.GetMetadata().GetType())
+		assert.Equal(t, map[string]string{"module": "demo"}, result.GetMetadata().GetHeaders())
+		assert.Equal(t, 0, assembler.bufferedBytes)
+	})
+
+	t.Run("notChunk", func(t *testing.T) {
+		assembler := newChunkAssembler(1024)
+		p := newTestPayload("hello")
+		result, err := assembler.accept(p)
+		assert.Nil(t, err)
+		assert.Equal(t, p, result)
+	})
+
+	t.Run("checksumMismatch", func(t *testing.T) {
+		assembler := newChunkAssembler(1024)
+		corrupted := splitPayload(newTestPayload(body), "2", 8)
+		corrupted[1].Body.Value = []byte("corrupt!")
+		_, err := assembler.accept(corrupted[0])
+		assert.Nil(t, err)
+		_, err = assembler.accept(corrupted[1])
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(assembler.buffers))
+		assert.Equal(t, 0, assembler.bufferedBytes)
+	})
+
+	t.Run("exceedBufferLimit", func(t *testing.T) {
+		assembler := newChunkAssembler(len(body) - 1)
+		p, err := assembler.accept(chunks[0])
+		assert.Nil(t, p)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(assembler.buffers))
+	})
+
+	t.Run("invalidTotal", func(t *testing.T) {
+		assembler := newChunkAssembler(1 << 30)
+		for _, total := range []string{"0", "71", "100000000"} {
+			forged := splitPayload(newTestPayload(body), "3", 8)[0]
+			forged.Metadata.Headers[constant.CHUNK_TOTAL_HEADER] = total
+			p, err := assembler.accept(forged)
+			assert.Nil(t, p)
+			assert.NotNil(t, err)
+		}
+		assert.Equal(t, 0, len(assembler.buffers))
+	})
+
+	t.Run("exceedDeclaredSize", func(t *testing.T) {
+		assembler := newChunkAssembler(1024)
+		forged := splitPayload(newTestPayload(body), "4", 8)
+		for _, chunk := range forged {
+			chunk.Metadata.Headers[constant.CHUNK_SIZE_HEADER] = "20"
+		}
+		_, err := assembler.accept(forged[0])
+		assert.Nil(t, err)
+		_, err = assembler.accept(forged[1])
+		assert.Nil(t, err)
+		_, err = assembler.accept(forged[2])
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(assembler.buffers))
+		assert.Equal(t, 0, assembler.bufferedBytes)
+	})
+}
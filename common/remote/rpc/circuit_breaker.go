@@ -0,0 +1,347 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+const (
+	defaultCircuitWindowSize            = 100
+	defaultCircuitMinimumCalls          = 20
+	defaultCircuitErrorRateThreshold    = 50
+	defaultCircuitSlowCallThresholdMs   = 3000
+	defaultCircuitSlowCallRateThreshold = 100
+	defaultCircuitOpenDurationMs        = 30000
+	defaultCircuitHalfOpenCalls         = 5
+)
+
+type CircuitState int32
+
+const (
+	CIRCUIT_CLOSED CircuitState = iota
+	CIRCUIT_OPEN
+	CIRCUIT_HALF_OPEN
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CIRCUIT_CLOSED:
+		return "closed"
+	case CIRCUIT_OPEN:
+		return "open"
+	case CIRCUIT_HALF_OPEN:
+		return "half_open"
+	}
+	return "unknown"
+}
+
+// ErrCircuitOpen is matched by errors.Is with the errors of the requests rejected by the open circuits.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is the error of the request rejected by the circuit of the server and the request type.
+type CircuitOpenError struct {
+	Server      string
+	RequestType string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "circuit breaker of " + e.RequestType + " to " + e.Server + " is open"
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitStateChange is reported when the circuit of the server and the request type changes its state.
+type CircuitStateChange struct {
+	ClientName  string
+	Server      string
+	RequestType string
+	From        CircuitState
+	To          CircuitState
+}
+
+// CircuitFallback returns the result of the request rejected by the open circuit, err is *CircuitOpenError.
+type CircuitFallback func(request rpc_request.IRequest, err error) (rpc_response.IResponse, error)
+
+const (
+	outcomeFailed uint8 = 1 << iota
+	outcomeSlow
+)
+
+// circuitPermit is taken before a call and recorded with the outcome of the call, the outcomes of the calls
+// permitted before the last state change are ignored.
+type circuitPermit struct {
+	generation uint64
+}
+
+// CircuitBreaker is the circuit of a server and a request type. It opens when the rate of the failed calls or the
+// slow calls of the last calls reaches the thresholds, rejects all calls while it's open, and permits a few trial
+// calls once it's been open for a while, which close it again unless they reach the thresholds too.
+type CircuitBreaker struct {
+	breakers          *CircuitBreakers
+	server            string
+	requestType       string
+	mux               sync.Mutex
+	state             CircuitState
+	generation        uint64
+	outcomes          []uint8
+	next              int
+	calls             int
+	failures          int
+	slowCalls         int
+	openedAt          time.Time
+	halfOpenPermitted int
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.state
+}
+
+func (b *CircuitBreaker) allow() (circuitPermit, error) {
+	b.mux.Lock()
+	var change *CircuitStateChange
+	defer func() {
+		b.mux.Unlock()
+		b.breakers.notify(change)
+	}()
+	config := b.breakers.config
+	if b.state == CIRCUIT_OPEN {
+		if time.Since(b.openedAt) < time.Duration(config.OpenDurationMs)*time.Millisecond {
+			monitor.GetCircuitRejectedMonitor(b.breakers.clientName, b.server, b.requestType).Inc()
+			return circuitPermit{}, &CircuitOpenError{Server: b.server, RequestType: b.requestType}
+		}
+		change = b.transition(CIRCUIT_HALF_OPEN)
+	}
+	if b.state == CIRCUIT_HALF_OPEN {
+		if b.halfOpenPermitted >= config.HalfOpenCalls {
+			monitor.GetCircuitRejectedMonitor(b.breakers.clientName, b.server, b.requestType).Inc()
+			return circuitPermit{}, &CircuitOpenError{Server: b.server, RequestType: b.requestType}
+		}
+		b.halfOpenPermitted++
+	}
+	return circuitPermit{generation: b.generation}, nil
+}
+
+func (b *CircuitBreaker) record(permit circuitPermit, failed bool, duration time.Duration) {
+	b.mux.Lock()
+	var change *CircuitStateChange
+	defer func() {
+		b.mux.Unlock()
+		b.breakers.notify(change)
+	}()
+	if permit.generation != b.generation {
+		return
+	}
+	config := b.breakers.config
+	var outcome uint8
+	if failed {
+		outcome |= outcomeFailed
+	}
+	if duration > time.Duration(config.SlowCallThresholdMs)*time.Millisecond {
+		outcome |= outcomeSlow
+	}
+	b.add(outcome)
+	switch b.state {
+	case CIRCUIT_CLOSED:
+		if b.calls >= config.MinimumCalls && b.exceeded() {
+			change = b.transition(CIRCUIT_OPEN)
+		}
+	case CIRCUIT_HALF_OPEN:
+		if b.exceeded() {
+			change = b.transition(CIRCUIT_OPEN)
+		} else if b.calls >= config.HalfOpenCalls {
+			change = b.transition(CIRCUIT_CLOSED)
+		}
+	}
+}
+
+// add puts the outcome into the window in place of the oldest one if the window is full.
+func (b *CircuitBreaker) add(outcome uint8) {
+	if b.calls == len(b.outcomes) {
+		b.count(b.outcomes[b.next], -1)
+	} else {
+		b.calls++
+	}
+	b.outcomes[b.next] = outcome
+	b.count(outcome, 1)
+	b.next = (b.next + 1) % len(b.outcomes)
+}
+
+func (b *CircuitBreaker) count(outcome uint8, delta int) {
+	if outcome&outcomeFailed != 0 {
+		b.failures += delta
+	}
+	if outcome&outcomeSlow != 0 {
+		b.slowCalls += delta
+	}
+}
+
+// exceeded returns whether the rate of the failed or the slow calls in the window reaches the threshold. When
+// half-open, it's computed as if all the trial calls not finished yet succeed, so the circuit opens again as soon
+// as the result is certain.
+func (b *CircuitBreaker) exceeded() bool {
+	config := b.breakers.config
+	calls := b.calls
+	if b.state == CIRCUIT_HALF_OPEN {
+		calls = config.HalfOpenCalls
+	}
+	return float64(b.failures*100) >= config.ErrorRateThreshold*float64(calls) ||
+		float64(b.slowCalls*100) >= config.SlowCallRateThreshold*float64(calls)
+}
+
+func (b *CircuitBreaker) transition(to CircuitState) *CircuitStateChange {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.next, b.calls, b.failures, b.slowCalls, b.halfOpenPermitted = 0, 0, 0, 0, 0
+	if to == CIRCUIT_OPEN {
+		b.openedAt = time.Now()
+	}
+	monitor.GetCircuitTransitionMonitor(b.breakers.clientName, b.server, b.requestType, from.String(), to.String()).Inc()
+	logger.Infof("%s circuit breaker of %s to %s changes from %s to %s", b.breakers.clientName, b.requestType,
+		b.server, from, to)
+	return &CircuitStateChange{ClientName: b.breakers.clientName, Server: b.server, RequestType: b.requestType,
+		From: from, To: to}
+}
+
+// CircuitBreakers keeps the circuits of the servers and the request types of the rpc client. Every RpcClient has
+// one, which is got by GetCircuitBreakers, and it's enabled by ClientConfig.CircuitBreakerCfg.
+type CircuitBreakers struct {
+	clientName      string
+	config          constant.CircuitBreakerConfig
+	mux             sync.Mutex
+	breakers        map[string]*CircuitBreaker
+	fallbacks       map[string]CircuitFallback
+	changeListeners []func(change CircuitStateChange)
+}
+
+// NewCircuitBreakers create the circuit breakers of the client, the defaults of CircuitBreakerConfig are used for
+// the fields not positive.
+func NewCircuitBreakers(clientName string, config constant.CircuitBreakerConfig) *CircuitBreakers {
+	if config.WindowSize <= 0 {
+		config.WindowSize = defaultCircuitWindowSize
+	}
+	if config.MinimumCalls <= 0 {
+		config.MinimumCalls = defaultCircuitMinimumCalls
+	}
+	if config.ErrorRateThreshold <= 0 {
+		config.ErrorRateThreshold = defaultCircuitErrorRateThreshold
+	}
+	if config.SlowCallThresholdMs == 0 {
+		config.SlowCallThresholdMs = defaultCircuitSlowCallThresholdMs
+	}
+	if config.SlowCallRateThreshold <= 0 {
+		config.SlowCallRateThreshold = defaultCircuitSlowCallRateThreshold
+	}
+	if config.OpenDurationMs == 0 {
+		config.OpenDurationMs = defaultCircuitOpenDurationMs
+	}
+	if config.HalfOpenCalls <= 0 {
+		config.HalfOpenCalls = defaultCircuitHalfOpenCalls
+	}
+	if config.WindowSize < config.HalfOpenCalls {
+		config.WindowSize = config.HalfOpenCalls
+	}
+	if config.MinimumCalls > config.WindowSize {
+		config.MinimumCalls = config.WindowSize
+	}
+	return &CircuitBreakers{
+		clientName: clientName,
+		config:     config,
+		breakers:   map[string]*CircuitBreaker{},
+		fallbacks:  map[string]CircuitFallback{},
+	}
+}
+
+// Enabled returns whether the requests go through the circuits.
+func (b *CircuitBreakers) Enabled() bool {
+	return b != nil && b.config.Enable
+}
+
+// Get returns the circuit of the server and the request type, it's created closed if absent.
+func (b *CircuitBreakers) Get(server string, requestType string) *CircuitBreaker {
+	key := server + "#" + requestType
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	breaker, ok := b.breakers[key]
+	if !ok {
+		breaker = &CircuitBreaker{
+			breakers:    b,
+			server:      server,
+			requestType: requestType,
+			outcomes:    make([]uint8, b.config.WindowSize),
+		}
+		b.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// RegisterFallback registers the fallback of the requests of the request type rejected by the open circuits, the
+// one of the empty request type applies to the request types without their own. The requests fail with
+// *CircuitOpenError if there's no fallback.
+func (b *CircuitBreakers) RegisterFallback(requestType string, fallback CircuitFallback) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.fallbacks[requestType] = fallback
+}
+
+// OnStateChange registers the listener of the state changes of the circuits, it's called synchronously so it
+// mustn't block.
+func (b *CircuitBreakers) OnStateChange(listener func(change CircuitStateChange)) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.changeListeners = append(b.changeListeners, listener)
+}
+
+func (b *CircuitBreakers) notify(change *CircuitStateChange) {
+	if change == nil {
+		return
+	}
+	b.mux.Lock()
+	listeners := append([]func(change CircuitStateChange){}, b.changeListeners...)
+	b.mux.Unlock()
+	for _, listener := range listeners {
+		listener(*change)
+	}
+}
+
+func (b *CircuitBreakers) fallback(request rpc_request.IRequest, err error) (rpc_response.IResponse, error) {
+	b.mux.Lock()
+	fallback, ok := b.fallbacks[request.GetRequestType()]
+	if !ok {
+		fallback, ok = b.fallbacks[""]
+	}
+	b.mux.Unlock()
+	if !ok {
+		return nil, err
+	}
+	return fallback(request, err)
+}
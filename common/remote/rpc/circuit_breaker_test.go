@@ -0,0 +1,164 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+func call(breaker *CircuitBreaker, failed bool, duration time.Duration) error {
+	permit, err := breaker.allow()
+	if err != nil {
+		return err
+	}
+	breaker.record(permit, failed, duration)
+	return nil
+}
+
+func TestCircuitBreakerOpensOnErrorRate(t *testing.T) {
+	breakers := NewCircuitBreakers("test-circuit", constant.CircuitBreakerConfig{Enable: true, WindowSize: 10,
+		MinimumCalls: 4, ErrorRateThreshold: 50, OpenDurationMs: 50, HalfOpenCalls: 2})
+	var changes []CircuitStateChange
+	breakers.OnStateChange(func(change CircuitStateChange) {
+		changes = append(changes, change)
+	})
+	// the counters are shared by the breakers of the same client name, e.g. when running the test repeatedly.
+	opened := testutil.ToFloat64(monitor.GetCircuitTransitionMonitor("test-circuit", "127.0.0.1:8848",
+		"ConfigQueryRequest", "closed", "open"))
+	rejected := testutil.ToFloat64(monitor.GetCircuitRejectedMonitor("test-circuit", "127.0.0.1:8848",
+		"ConfigQueryRequest"))
+	breaker := breakers.Get("127.0.0.1:8848", "ConfigQueryRequest")
+	assert.Same(t, breaker, breakers.Get("127.0.0.1:8848", "ConfigQueryRequest"))
+
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Nil(t, call(breaker, false, 0))
+	// the minimum calls are not reached yet.
+	assert.Equal(t, CIRCUIT_CLOSED, breaker.State())
+	assert.Nil(t, call(breaker, false, 0))
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+
+	err := call(breaker, false, 0)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "127.0.0.1:8848", openErr.Server)
+	assert.Equal(t, "ConfigQueryRequest", openErr.RequestType)
+	// the circuits of the other request types are not affected.
+	assert.Equal(t, CIRCUIT_CLOSED, breakers.Get("127.0.0.1:8848", "ConfigPublishRequest").State())
+
+	time.Sleep(60 * time.Millisecond)
+	permit1, err := breaker.allow()
+	assert.Nil(t, err)
+	assert.Equal(t, CIRCUIT_HALF_OPEN, breaker.State())
+	permit2, err := breaker.allow()
+	assert.Nil(t, err)
+	// only the trial calls are permitted when half-open.
+	assert.True(t, errors.Is(call(breaker, false, 0), ErrCircuitOpen))
+	breaker.record(permit1, false, 0)
+	breaker.record(permit2, false, 0)
+	assert.Equal(t, CIRCUIT_CLOSED, breaker.State())
+
+	assert.Equal(t, []CircuitStateChange{
+		{ClientName: "test-circuit", Server: "127.0.0.1:8848", RequestType: "ConfigQueryRequest", From: CIRCUIT_CLOSED, To: CIRCUIT_OPEN},
+		{ClientName: "test-circuit", Server: "127.0.0.1:8848", RequestType: "ConfigQueryRequest", From: CIRCUIT_OPEN, To: CIRCUIT_HALF_OPEN},
+		{ClientName: "test-circuit", Server: "127.0.0.1:8848", RequestType: "ConfigQueryRequest", From: CIRCUIT_HALF_OPEN, To: CIRCUIT_CLOSED},
+	}, changes)
+	assert.Equal(t, opened+1, testutil.ToFloat64(monitor.GetCircuitTransitionMonitor("test-circuit",
+		"127.0.0.1:8848", "ConfigQueryRequest", "closed", "open")))
+	assert.Equal(t, rejected+2, testutil.ToFloat64(monitor.GetCircuitRejectedMonitor("test-circuit",
+		"127.0.0.1:8848", "ConfigQueryRequest")))
+}
+
+func TestCircuitBreakerReopensOnFailedTrial(t *testing.T) {
+	breakers := NewCircuitBreakers("test-circuit-trial", constant.CircuitBreakerConfig{Enable: true, WindowSize: 4,
+		MinimumCalls: 2, OpenDurationMs: 20, HalfOpenCalls: 2})
+	breaker := breakers.Get("127.0.0.1:8848", "ConfigQueryRequest")
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+
+	time.Sleep(30 * time.Millisecond)
+	permit, err := breaker.allow()
+	assert.Nil(t, err)
+	// one failed trial call of two reaches the error rate 50% already.
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+	// the outcome of the call permitted before the state change is ignored.
+	breaker.record(permit, false, 0)
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+}
+
+func TestCircuitBreakerOpensOnSlowCallRate(t *testing.T) {
+	breakers := NewCircuitBreakers("test-circuit-slow", constant.CircuitBreakerConfig{Enable: true, WindowSize: 4,
+		MinimumCalls: 4, SlowCallThresholdMs: 10, SlowCallRateThreshold: 75, HalfOpenCalls: 2})
+	breaker := breakers.Get("127.0.0.1:8848", "ConfigQueryRequest")
+	assert.Nil(t, call(breaker, false, time.Millisecond))
+	assert.Nil(t, call(breaker, false, 20*time.Millisecond))
+	assert.Nil(t, call(breaker, false, 20*time.Millisecond))
+	assert.Nil(t, call(breaker, false, time.Millisecond))
+	assert.Equal(t, CIRCUIT_CLOSED, breaker.State())
+	// the oldest fast call leaves the window.
+	assert.Nil(t, call(breaker, false, 20*time.Millisecond))
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+}
+
+func TestRequestThroughOpenCircuit(t *testing.T) {
+	connection := &mockEchoConnection{}
+	rpcClient := newRunningRpcClient(connection)
+	rpcClient.circuitBreakers = NewCircuitBreakers("test", constant.CircuitBreakerConfig{Enable: true,
+		MinimumCalls: 1})
+	breaker := rpcClient.circuitBreakers.Get(":0", "HealthCheckRequest")
+	assert.Nil(t, call(breaker, true, 0))
+	assert.Equal(t, CIRCUIT_OPEN, breaker.State())
+
+	_, err := rpcClient.Request(rpc_request.NewHealthCheckRequest(), 1000)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Empty(t, connection.requestIds)
+	assert.True(t, rpcClient.IsRunning())
+
+	rpcClient.GetCircuitBreakers().RegisterFallback("", func(request rpc_request.IRequest, err error) (rpc_response.IResponse, error) {
+		return &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{Success: true}}, nil
+	})
+	response, err := rpcClient.Request(rpc_request.NewHealthCheckRequest(), 1000)
+	assert.Nil(t, err)
+	assert.True(t, response.IsSuccess())
+	assert.Empty(t, connection.requestIds)
+}
+
+func TestRequestThroughCircuitIgnoresAuthFailure(t *testing.T) {
+	connection := &mockAuthConnection{}
+	rpcClient := newRunningRpcClient(connection)
+	rpcClient.circuitBreakers = NewCircuitBreakers("test-circuit-auth", constant.CircuitBreakerConfig{Enable: true,
+		MinimumCalls: 1})
+	response, err := rpcClient.requestThroughCircuit(context.Background(), connection, rpc_request.NewHealthCheckRequest(), 1000, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, constant.RESPONSE_CODE_FORBIDDEN, response.GetErrorCode())
+	// the rejected credential is refreshed and retried, it's not a failure of the server.
+	assert.Equal(t, CIRCUIT_CLOSED, rpcClient.circuitBreakers.Get(":0", "HealthCheckRequest").State())
+}
@@ -23,3 +23,6 @@ func (m *MockConnection) getServerInfo() ServerInfo {
 func (m *MockConnection) setAbandon(flag bool) {
 
 }
+func (m *MockConnection) getAbandon() bool {
+	return false
+}
@@ -0,0 +1,370 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake_server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/inner/uuid"
+)
+
+// Request is a request received by the FakeServer.
+type Request struct {
+	Type         string
+	Headers      map[string]string
+	Body         []byte
+	ConnectionId string
+}
+
+// Decode unmarshal the json body into the request.
+func (r *Request) Decode(request interface{}) error {
+	return json.Unmarshal(r.Body, request)
+}
+
+// Handler answers the request, the ErrorResponse is answered if it returns nil.
+type Handler func(request *Request) rpc_response.IResponse
+
+// FakeServer is an in-memory xgrpc server on the loopback interface, it's used by the tests of the clients.
+// It answers ServerCheckRequest and HealthCheckRequest, and the other requests by the registered handlers.
+type FakeServer struct {
+	listener    net.Listener
+	grpcServer  *grpc.Server
+	mux         sync.Mutex
+	handlers    map[string]Handler
+	connections map[string]*connection
+	requests    []*Request
+	acks        map[string]rpc_response.IResponse
+	changed     *sync.Cond
+}
+
+type connection struct {
+	stream xgrpc_grpc_service.BiRequestStream_RequestBiStreamServer
+	mux    sync.Mutex
+	closed chan struct{}
+}
+
+type requestServer struct {
+	server *FakeServer
+}
+
+type biRequestStreamServer struct {
+	server *FakeServer
+}
+
+func NewFakeServer() (*FakeServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeServer{
+		listener:    listener,
+		grpcServer:  grpc.NewServer(),
+		handlers:    map[string]Handler{},
+		connections: map[string]*connection{},
+		acks:        map[string]rpc_response.IResponse{},
+	}
+	s.changed = sync.NewCond(&s.mux)
+	xgrpc_grpc_service.RegisterRequestServer(s.grpcServer, &requestServer{server: s})
+	xgrpc_grpc_service.RegisterBiRequestStreamServer(s.grpcServer, &biRequestStreamServer{server: s})
+	go func() {
+		_ = s.grpcServer.Serve(listener)
+	}()
+	return s, nil
+}
+
+// ServerConfig returns the config to connect the fake server.
+func (s *FakeServer) ServerConfig() constant.ServerConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return constant.ServerConfig{
+		Scheme:      constant.DEFAULT_SERVER_SCHEME,
+		ContextPath: constant.DEFAULT_CONTEXT_PATH,
+		IpAddr:      addr.IP.String(),
+		Port:        uint64(addr.Port),
+		GrpcPort:    uint64(addr.Port),
+	}
+}
+
+func (s *FakeServer) Address() string {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String() + ":" + strconv.Itoa(addr.Port)
+}
+
+// Handle registers the handler of the request type.
+func (s *FakeServer) Handle(requestType string, handler Handler) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.handlers[requestType] = handler
+}
+
+// GetRequests returns the received requests of the type, all requests are returned if the type is empty.
+func (s *FakeServer) GetRequests(requestType string) []*Request {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var result []*Request
+	for _, request := range s.requests {
+		if requestType == "" || request.Type == requestType {
+			result = append(result, request)
+		}
+	}
+	return result
+}
+
+// GetConnectionIds returns the connections which have sent ConnectionSetupRequest.
+func (s *FakeServer) GetConnectionIds() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.connectionIds()
+}
+
+func (s *FakeServer) connectionIds() []string {
+	ids := make([]string, 0, len(s.connections))
+	for id := range s.connections {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// WaitConnections waits until there are at least n connections.
+func (s *FakeServer) WaitConnections(n int, timeout time.Duration) bool {
+	return s.waitUntil(timeout, func() bool {
+		return len(s.connections) >= n
+	})
+}
+
+// Push sends the request to all the connections, and waits for their responses.
+func (s *FakeServer) Push(request rpc_request.IRequest, timeout time.Duration) ([]rpc_response.IResponse, error) {
+	s.mux.Lock()
+	ids := s.connectionIds()
+	s.mux.Unlock()
+	if len(ids) == 0 {
+		return nil, errors.New("no connection to push")
+	}
+
+	var requestIds []string
+	for _, id := range ids {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			return nil, err
+		}
+		request.SetRequestId(uid.String())
+		if err = s.send(id, request.GetRequestType(), request.GetHeaders(), []byte(request.GetBody(request))); err != nil {
+			return nil, err
+		}
+		requestIds = append(requestIds, uid.String())
+	}
+
+	var responses []rpc_response.IResponse
+	ok := s.waitUntil(timeout, func() bool {
+		responses = responses[:0]
+		for _, requestId := range requestIds {
+			if ack, ok := s.acks[requestId]; ok {
+				responses = append(responses, ack)
+			}
+		}
+		return len(responses) == len(requestIds)
+	})
+	if !ok {
+		return responses, errors.Errorf("push %s timeout, %d of %d acked", request.GetRequestType(), len(responses), len(requestIds))
+	}
+	return responses, nil
+}
+
+// ResetConnections closes the streams of all the connections, the clients reconnect to the server.
+func (s *FakeServer) ResetConnections() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for id, conn := range s.connections {
+		close(conn.closed)
+		delete(s.connections, id)
+	}
+	s.changed.Broadcast()
+}
+
+// Stop shuts down the server.
+func (s *FakeServer) Stop() {
+	s.grpcServer.Stop()
+}
+
+func (s *FakeServer) waitUntil(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		s.changed.Broadcast()
+	})
+	defer timer.Stop()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for !condition() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		s.changed.Wait()
+	}
+	return true
+}
+
+func (s *FakeServer) handle(request *Request) rpc_response.IResponse {
+	s.mux.Lock()
+	s.requests = append(s.requests, request)
+	handler := s.handlers[request.Type]
+	s.changed.Broadcast()
+	s.mux.Unlock()
+
+	var response rpc_response.IResponse
+	switch {
+	case handler != nil:
+		response = handler(request)
+	case request.Type == "ServerCheckRequest":
+		uid, _ := uuid.NewV4()
+		response = &rpc_response.ServerCheckResponse{Response: SuccessResponse(), ConnectionId: uid.String()}
+	case request.Type == "HealthCheckRequest":
+		response = &rpc_response.HealthCheckResponse{Response: SuccessResponse()}
+	}
+	if response == nil {
+		response = &rpc_response.ErrorResponse{Response: &rpc_response.Response{ResultCode: 500, ErrorCode: 501,
+			Message: "unsupported request type " + request.Type}}
+	}
+	var meta struct {
+		RequestId string `json:"requestId"`
+	}
+	_ = request.Decode(&meta)
+	response.SetRequestId(meta.RequestId)
+	return response
+}
+
+func (s *FakeServer) send(connectionId string, payloadType string, headers map[string]string, body []byte) error {
+	s.mux.Lock()
+	conn, ok := s.connections[connectionId]
+	s.mux.Unlock()
+	if !ok {
+		return errors.Errorf("connection %s not found", connectionId)
+	}
+	return conn.send(newPayload(payloadType, headers, body))
+}
+
+func (c *connection) send(payload *xgrpc_grpc_service.Payload) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.stream.Send(payload)
+}
+
+func (r *requestServer) Request(ctx context.Context, payload *xgrpc_grpc_service.Payload) (*xgrpc_grpc_service.Payload, error) {
+	response := r.server.handle(newRequest(payload, ""))
+	return responsePayload(response), nil
+}
+
+// RequestBiStream serves the stream until the client closes it or the connection is reset.
+func (b *biRequestStreamServer) RequestBiStream(stream xgrpc_grpc_service.BiRequestStream_RequestBiStreamServer) error {
+	s := b.server
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	conn := &connection{stream: stream, closed: make(chan struct{})}
+	connectionId := uid.String()
+	defer func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if s.connections[connectionId] == conn {
+			delete(s.connections, connectionId)
+		}
+		s.changed.Broadcast()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			payload, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			s.accept(conn, connectionId, payload)
+		}
+	}()
+	select {
+	case <-done:
+	case <-conn.closed:
+	}
+	return nil
+}
+
+func (s *FakeServer) accept(conn *connection, connectionId string, payload *xgrpc_grpc_service.Payload) {
+	payloadType := payload.GetMetadata().GetType()
+	if responseFunc, ok := rpc_response.ClientResponseMapping[payloadType]; ok {
+		ack := responseFunc()
+		if err := json.Unmarshal(payload.GetBody().GetValue(), ack); err == nil {
+			s.mux.Lock()
+			s.acks[ack.GetRequestId()] = ack
+			s.changed.Broadcast()
+			s.mux.Unlock()
+		}
+		return
+	}
+	request := newRequest(payload, connectionId)
+	if payloadType == "ConnectionSetupRequest" {
+		s.mux.Lock()
+		s.requests = append(s.requests, request)
+		s.connections[connectionId] = conn
+		s.changed.Broadcast()
+		s.mux.Unlock()
+		return
+	}
+	response := s.handle(request)
+	_ = conn.send(responsePayload(response))
+}
+
+func newRequest(payload *xgrpc_grpc_service.Payload, connectionId string) *Request {
+	return &Request{
+		Type:         payload.GetMetadata().GetType(),
+		Headers:      payload.GetMetadata().GetHeaders(),
+		Body:         payload.GetBody().GetValue(),
+		ConnectionId: connectionId,
+	}
+}
+
+// responsePayload marshals the whole typed response, the GetBody of the embedded Response only has the common fields.
+func responsePayload(response rpc_response.IResponse) *xgrpc_grpc_service.Payload {
+	body, _ := json.Marshal(response)
+	return newPayload(response.GetResponseType(), nil, body)
+}
+
+func newPayload(payloadType string, headers map[string]string, body []byte) *xgrpc_grpc_service.Payload {
+	return &xgrpc_grpc_service.Payload{
+		Metadata: &xgrpc_grpc_service.Metadata{Type: payloadType, Headers: headers},
+		Body:     &any.Any{Value: body},
+	}
+}
+
+// SuccessResponse returns the response of the success code, it is embedded into the typed responses of the handlers.
+func SuccessResponse() *rpc_response.Response {
+	return &rpc_response.Response{ResultCode: constant.RESPONSE_CODE_SUCCESS, Success: true}
+}
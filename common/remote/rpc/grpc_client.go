@@ -23,17 +23,19 @@ import (
 	"os"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/common/tracing"
 
 	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
 	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
@@ -51,14 +53,21 @@ func NewGrpcClient(clientName string, xgrpcServer *xgrpc_server.XgrpcServer) *Gr
 			rpcClientStatus:             INITIALIZED,
 			eventChan:                   make(chan ConnectionEvent),
 			reconnectionChan:            make(chan ReconnectContext, 1),
+			serverListChangeChan:        make(chan struct{}, 1),
 			xgrpcServer:                 xgrpcServer,
 			serverRequestHandlerMapping: make(map[string]ServerRequestHandlerMapping, 8),
 			mux:                         new(sync.Mutex),
+			requestIdGenerator:          &UuidRequestIdGenerator{},
+			redoService:                 NewRedoService(clientName, 0),
 		},
 	}
+	if xgrpcServer != nil {
+		rpcClient.circuitBreakers = NewCircuitBreakers(clientName, xgrpcServer.GetClientConfig().CircuitBreakerCfg)
+	}
 	rpcClient.RpcClient.lastActiveTimestamp.Store(time.Now())
 	rpcClient.executeClient = rpcClient
 	listeners := make([]IConnectionEventListener, 0, 8)
+	listeners = append(listeners, rpcClient.redoService)
 	rpcClient.connectionEventListeners.Store(listeners)
 	return rpcClient
 }
@@ -87,6 +96,22 @@ func getInitialConnWindowSize() int32 {
 	return int32(initialConnWindowSize)
 }
 
+func getChunkSize() int {
+	chunkSize, err := strconv.Atoi(os.Getenv("xgrpc.remote.client.grpc.chunk.size"))
+	if err != nil {
+		return 8 * 1024 * 1024
+	}
+	return chunkSize
+}
+
+func getMaxChunkBufferSize() int {
+	maxChunkBufferSize, err := strconv.Atoi(os.Getenv("xgrpc.remote.client.grpc.chunk.buffer.size"))
+	if err != nil {
+		return 64 * 1024 * 1024
+	}
+	return maxChunkBufferSize
+}
+
 func getKeepAliveTimeMillis() keepalive.ClientParameters {
 	keepAliveTimeMillisInt, err := strconv.Atoi(os.Getenv("xgrpc.remote.grpc.keep.alive.millis"))
 	var keepAliveTime time.Duration
@@ -109,6 +134,9 @@ func (c *GrpcClient) createNewConnection(serverInfo ServerInfo) (*grpc.ClientCon
 	opts = append(opts, grpc.WithInsecure())
 	opts = append(opts, grpc.WithInitialWindowSize(getInitialWindowSize()))
 	opts = append(opts, grpc.WithInitialConnWindowSize(getInitialConnWindowSize()))
+	if c.xgrpcServer != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(c.xgrpcServer.GetPerRPCCredentials()))
+	}
 	rpcPort := serverInfo.serverGrpcPort
 	if rpcPort == 0 {
 		rpcPort = serverInfo.serverPort + c.rpcPortOffset()
@@ -152,6 +180,9 @@ func (c *GrpcClient) sendConnectionSetupRequest(grpcConn *GrpcConnection) error
 	csr.Tenant = c.Tenant
 	csr.Labels = c.labels
 	csr.ClientAbilities = c.clientAbilities
+	if c.xgrpcServer != nil {
+		c.xgrpcServer.InjectSecurityInfo(csr.GetHeaders())
+	}
 	err := grpcConn.biStreamSend(convertRequest(csr))
 	if err != nil {
 		logger.Warnf("Send ConnectionSetupRequest error:%+v", err)
@@ -185,7 +216,7 @@ func (c *GrpcClient) bindBiRequestStream(streamClient xgrpc_grpc_service.BiReque
 						} else {
 							logger.Errorf("%s Request stream error, switch server, error=%+v", grpcConn.getConnectionId(), err)
 						}
-						if atomic.CompareAndSwapInt32((*int32)(&c.rpcClientStatus), int32(RUNNING), int32(UNHEALTHY)) {
+						if c.compareAndSetStatus(RUNNING, UNHEALTHY) {
 							c.switchServerAsync(ServerInfo{}, false)
 							return
 						}
@@ -194,6 +225,14 @@ func (c *GrpcClient) bindBiRequestStream(streamClient xgrpc_grpc_service.BiReque
 						return
 					}
 				} else {
+					payload, err = grpcConn.assembler.accept(payload)
+					if err != nil {
+						logger.Warnf("%s drop chunked payload, error=%+v", grpcConn.getConnectionId(), err)
+						continue
+					}
+					if payload == nil || grpcConn.notifyChunkedReply(payload) || grpcConn.notifyResponse(payload) {
+						continue
+					}
 					c.handleServerRequest(payload, grpcConn)
 				}
 
@@ -231,14 +270,32 @@ func (c *GrpcClient) handleServerRequest(p *xgrpc_grpc_service.Payload, grpcConn
 	client := c.GetRpcClient()
 	payLoadType := p.GetMetadata().GetType()
 
+	ctx := tracing.Extract(context.Background(), p.GetMetadata().GetHeaders())
+	ctx, span := tracing.StartSpan(ctx, "xgrpc.push "+payLoadType, trace.SpanKindServer,
+		tracing.ClientNameKey.String(client.Name), tracing.RequestTypeKey.String(payLoadType),
+		tracing.ConnectionIdKey.String(grpcConn.getConnectionId()))
+	start := time.Now()
+	monitor.GetPushInFlightMonitor(client.Name, payLoadType).Inc()
+	var (
+		response rpc_response.IResponse
+		err      error
+	)
+	defer func() {
+		tracing.EndSpan(span, err)
+		monitor.GetPushInFlightMonitor(client.Name, payLoadType).Dec()
+		monitor.GetPushDurationMonitor(client.Name, payLoadType, rpc_response.GetGrpcResponseStatusCode(response)).
+			Observe(time.Since(start).Seconds())
+	}()
+
 	mapping, ok := client.serverRequestHandlerMapping[payLoadType]
 	if !ok {
 		logger.Errorf("%s Unsupported payload type", grpcConn.getConnectionId())
+		err = errors.Errorf("unsupported payload type:%s", payLoadType)
 		return
 	}
 
 	serverRequest := mapping.serverRequest()
-	err := json.Unmarshal(p.GetBody().Value, serverRequest)
+	err = json.Unmarshal(p.GetBody().Value, serverRequest)
 	if err != nil {
 		logger.Errorf("%s Fail to json Unmarshal for request:%s, ackId->%s", grpcConn.getConnectionId(),
 			serverRequest.GetRequestType(), serverRequest.GetRequestId())
@@ -246,14 +303,26 @@ func (c *GrpcClient) handleServerRequest(p *xgrpc_grpc_service.Payload, grpcConn
 	}
 
 	serverRequest.PutAllHeaders(p.GetMetadata().Headers)
+	if serverRequest.GetRequestId() == "" {
+		client.fillRequestId(serverRequest)
+		logger.Warnf("%s server request:%s without requestId, generated ackId->%s", grpcConn.getConnectionId(),
+			serverRequest.GetRequestType(), serverRequest.GetRequestId())
+	}
+	logger.Debugf("%s receive server request:%s, ackId->%s", grpcConn.getConnectionId(), serverRequest.GetRequestType(),
+		serverRequest.GetRequestId())
+	span.SetAttributes(tracing.RequestIdKey.String(serverRequest.GetRequestId()))
+	// let the handler continue the trace from the push span with tracing.Extract(ctx, request.GetHeaders()).
+	tracing.Inject(ctx, serverRequest.GetHeaders())
 
-	response := mapping.handler.RequestReply(serverRequest, client)
+	response = mapping.handler.RequestReply(serverRequest, client)
 	if response == nil {
 		logger.Warnf("%s Fail to process server request, ackId->%s", grpcConn.getConnectionId(),
 			serverRequest.GetRequestId())
+		err = errors.Errorf("handler %s returns no response", mapping.handler.Name())
 		return
 	}
 	response.SetRequestId(serverRequest.GetRequestId())
+	span.SetAttributes(tracing.ResponseAttributes(response)...)
 	err = grpcConn.biStreamSend(convertResponse(response))
 	if err != nil && err != io.EOF {
 		logger.Warnf("%s Fail to send response:%s,ackId->%s", grpcConn.getConnectionId(),
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,6 +28,9 @@ import (
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
 
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/inner/uuid"
 	"github.com/allenliu88/xgrpc-client-go/util"
 
 	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
@@ -36,8 +40,13 @@ import (
 
 type GrpcConnection struct {
 	*Connection
-	client         xgrpc_grpc_service.RequestClient
-	biStreamClient xgrpc_grpc_service.BiRequestStream_RequestBiStreamClient
+	client                 xgrpc_grpc_service.RequestClient
+	biStreamClient         xgrpc_grpc_service.BiRequestStream_RequestBiStreamClient
+	biStreamMux            sync.Mutex
+	chunkSize              int
+	assembler              *chunkAssembler
+	pendingChunkedRequests sync.Map
+	pendingRequests        sync.Map
 }
 
 func NewGrpcConnection(serverInfo ServerInfo, connectionId string, conn *grpc.ClientConn,
@@ -51,13 +60,24 @@ func NewGrpcConnection(serverInfo ServerInfo, connectionId string, conn *grpc.Cl
 		},
 		client:         client,
 		biStreamClient: biStreamClient,
+		chunkSize:      getChunkSize(),
+		assembler:      newChunkAssembler(getMaxChunkBufferSize()),
 	}
 }
 func (g *GrpcConnection) request(request rpc_request.IRequest, timeoutMills int64, client *RpcClient) (rpc_response.IResponse, error) {
+	if client != nil && client.BiStreamRequest {
+		return g.biStreamRequest(request, timeoutMills)
+	}
 	p := convertRequest(request)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutMills)*time.Millisecond)
-	defer cancel()
-	responsePayload, err := g.client.Request(ctx, p)
+	var responsePayload *xgrpc_grpc_service.Payload
+	var err error
+	if g.chunkSize > 0 && len(p.GetBody().GetValue()) > g.chunkSize {
+		responsePayload, err = g.requestByChunks(p, timeoutMills)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutMills)*time.Millisecond)
+		defer cancel()
+		responsePayload, err = g.client.Request(ctx, p)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -73,12 +93,126 @@ func (g *GrpcConnection) request(request rpc_request.IRequest, timeoutMills int6
 	return response, err
 }
 
+// biStreamRequest sends the request over the bi-directional stream and waits for the response
+// carrying the same request id.
+func (g *GrpcConnection) biStreamRequest(request rpc_request.IRequest, timeoutMills int64) (rpc_response.IResponse, error) {
+	if request.GetRequestId() == "" {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			return nil, err
+		}
+		request.SetRequestId(uid.String())
+	}
+	requestId := request.GetRequestId()
+	responseChan := make(chan rpc_response.IResponse, 1)
+	if _, loaded := g.pendingRequests.LoadOrStore(requestId, responseChan); loaded {
+		return nil, errors.Errorf("request:%s, requestId:%s is already pending", request.GetRequestType(), requestId)
+	}
+	defer g.pendingRequests.Delete(requestId)
+
+	if err := g.biStreamSend(convertRequest(request)); err != nil {
+		return nil, err
+	}
+	select {
+	case response := <-responseChan:
+		return response, nil
+	case <-time.After(time.Duration(timeoutMills) * time.Millisecond):
+		return nil, errors.Errorf("request:%s, requestId:%s timeout after %d ms", request.GetRequestType(), requestId, timeoutMills)
+	}
+}
+
+// notifyResponse hands the response received from the bi-directional stream over to the pending request
+// with the same request id, returns false if the payload is not a response.
+func (g *GrpcConnection) notifyResponse(p *xgrpc_grpc_service.Payload) bool {
+	responseFunc, ok := rpc_response.ClientResponseMapping[p.GetMetadata().GetType()]
+	if !ok {
+		return false
+	}
+	response := responseFunc()
+	if err := json.Unmarshal(p.GetBody().GetValue(), response); err != nil {
+		logger.Errorf("%s Fail to json Unmarshal for response:%s, error=%+v", g.getConnectionId(), p.GetMetadata().GetType(), err)
+		return true
+	}
+	responseChan, ok := g.pendingRequests.Load(response.GetRequestId())
+	if !ok {
+		logger.Warnf("%s receive response:%s without pending request, requestId:%s", g.getConnectionId(),
+			response.GetResponseType(), response.GetRequestId())
+		return true
+	}
+	select {
+	case responseChan.(chan rpc_response.IResponse) <- response:
+	default:
+	}
+	return true
+}
+
 func (g *GrpcConnection) close() {
 	g.Connection.close()
 }
 
+// requestByChunks sends a payload larger than the chunk size over the bi-directional stream
+// and waits for the reply, the server acks the reply with the chunk id of the request.
+func (g *GrpcConnection) requestByChunks(p *xgrpc_grpc_service.Payload, timeoutMills int64) (*xgrpc_grpc_service.Payload, error) {
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	chunkId := uid.String()
+	replyChan := make(chan *xgrpc_grpc_service.Payload, 1)
+	g.pendingChunkedRequests.Store(chunkId, replyChan)
+	defer g.pendingChunkedRequests.Delete(chunkId)
+
+	if err = g.biStreamSendChunks(p, chunkId); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-replyChan:
+		return reply, nil
+	case <-time.After(time.Duration(timeoutMills) * time.Millisecond):
+		return nil, errors.Errorf("chunked request:%s, chunkId:%s timeout after %d ms", p.GetMetadata().GetType(), chunkId, timeoutMills)
+	}
+}
+
+// notifyChunkedReply hands the payload over to the pending chunked request it acks,
+// returns false if the payload is not a reply of a chunked request.
+func (g *GrpcConnection) notifyChunkedReply(p *xgrpc_grpc_service.Payload) bool {
+	chunkId, ok := p.GetMetadata().GetHeaders()[constant.CHUNK_ACK_HEADER]
+	if !ok {
+		return false
+	}
+	replyChan, ok := g.pendingChunkedRequests.Load(chunkId)
+	if !ok {
+		return false
+	}
+	select {
+	case replyChan.(chan *xgrpc_grpc_service.Payload) <- p:
+	default:
+	}
+	return true
+}
+
 func (g *GrpcConnection) biStreamSend(payload *xgrpc_grpc_service.Payload) error {
-	return g.biStreamClient.Send(payload)
+	if g.chunkSize <= 0 || len(payload.GetBody().GetValue()) <= g.chunkSize {
+		g.biStreamMux.Lock()
+		defer g.biStreamMux.Unlock()
+		return g.biStreamClient.Send(payload)
+	}
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	return g.biStreamSendChunks(payload, uid.String())
+}
+
+func (g *GrpcConnection) biStreamSendChunks(payload *xgrpc_grpc_service.Payload, chunkId string) error {
+	g.biStreamMux.Lock()
+	defer g.biStreamMux.Unlock()
+	for _, chunk := range splitPayload(payload, chunkId, g.chunkSize) {
+		if err := g.biStreamClient.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func convertRequest(r rpc_request.IRequest) *xgrpc_grpc_service.Payload {
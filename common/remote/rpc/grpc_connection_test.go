@@ -0,0 +1,100 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	xgrpc_grpc_service "github.com/allenliu88/xgrpc-client-go/api/grpc"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+type mockBiStreamClient struct {
+	grpc.ClientStream
+	sent chan *xgrpc_grpc_service.Payload
+}
+
+func (m *mockBiStreamClient) Send(p *xgrpc_grpc_service.Payload) error {
+	m.sent <- p
+	return nil
+}
+
+func (m *mockBiStreamClient) Recv() (*xgrpc_grpc_service.Payload, error) {
+	select {}
+}
+
+func newMockGrpcConnection() (*GrpcConnection, *mockBiStreamClient) {
+	biStreamClient := &mockBiStreamClient{sent: make(chan *xgrpc_grpc_service.Payload, 16)}
+	return NewGrpcConnection(ServerInfo{}, "mock", nil, nil, biStreamClient), biStreamClient
+}
+
+func TestBiStreamRequest(t *testing.T) {
+	grpcConn, biStreamClient := newMockGrpcConnection()
+	go func() {
+		p := <-biStreamClient.sent
+		var request map[string]interface{}
+		_ = json.Unmarshal(p.GetBody().GetValue(), &request)
+		response := &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{ResultCode: 200, Success: true}}
+		response.SetRequestId(request["requestId"].(string))
+
+		// a late response of another request is ignored.
+		other := &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{RequestId: "other"}}
+		assert.True(t, grpcConn.notifyResponse(convertResponse(other)))
+		assert.True(t, grpcConn.notifyResponse(convertResponse(response)))
+	}()
+
+	request := rpc_request.NewHealthCheckRequest()
+	response, err := grpcConn.request(request, 3000, &RpcClient{BiStreamRequest: true})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, request.GetRequestId())
+	assert.Equal(t, request.GetRequestId(), response.GetRequestId())
+	assert.True(t, response.IsSuccess())
+}
+
+func TestBiStreamRequestTimeout(t *testing.T) {
+	grpcConn, _ := newMockGrpcConnection()
+	_, err := grpcConn.request(rpc_request.NewHealthCheckRequest(), 10, &RpcClient{BiStreamRequest: true})
+	assert.NotNil(t, err)
+}
+
+func TestNotifyResponseIgnoresServerRequest(t *testing.T) {
+	grpcConn, _ := newMockGrpcConnection()
+	p := &xgrpc_grpc_service.Payload{
+		Metadata: &xgrpc_grpc_service.Metadata{Type: "ConnectResetRequest"},
+		Body:     &any.Any{Value: []byte("{}")},
+	}
+	assert.False(t, grpcConn.notifyResponse(p))
+}
+
+func TestConnectionSetupRequestCarriesTenant(t *testing.T) {
+	grpcConn, biStreamClient := newMockGrpcConnection()
+	client := NewGrpcClient("test-setup", nil)
+	client.Tenant = "tenant-a"
+	assert.Nil(t, client.sendConnectionSetupRequest(grpcConn))
+
+	p := <-biStreamClient.sent
+	var request rpc_request.ConnectionSetupRequest
+	assert.Nil(t, json.Unmarshal(p.GetBody().GetValue(), &request))
+	assert.Equal(t, "ConnectionSetupRequest", p.GetMetadata().GetType())
+	assert.Equal(t, "tenant-a", request.Tenant)
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+)
+
+const defaultRedoRetryInterval = 3 * time.Second
+
+// RedoTask replays an intent of the client on the server, e.g. a registration, a subscription or a listener.
+// It may be replayed more than once, so it must be idempotent.
+type RedoTask func() error
+
+// RedoFailure is reported when replaying the task of the key fails, the task is retried until it succeeds,
+// it's removed or the client disconnects.
+type RedoFailure struct {
+	ClientName string
+	Key        string
+	Attempts   int
+	Err        error
+}
+
+type redoEntry struct {
+	task     RedoTask
+	pending  bool
+	attempts int
+}
+
+// RedoService records the intents of the rpc client and replays them after the client connects to a server,
+// since the server the client lands on knows nothing about the state of the client on the server before.
+// Every RpcClient has one, which is got by GetRedoService.
+type RedoService struct {
+	clientName       string
+	retryInterval    time.Duration
+	mux              sync.Mutex
+	keys             []string
+	entries          map[string]*redoEntry
+	connected        bool
+	generation       uint64
+	failureListeners []func(failure RedoFailure)
+	closeChan        chan struct{}
+	closed           bool
+}
+
+// NewRedoService create the redo service of the client, the failed tasks are retried every retryInterval,
+// 3 seconds is used if it's not positive.
+func NewRedoService(clientName string, retryInterval time.Duration) *RedoService {
+	if retryInterval <= 0 {
+		retryInterval = defaultRedoRetryInterval
+	}
+	return &RedoService{
+		clientName:    clientName,
+		retryInterval: retryInterval,
+		entries:       map[string]*redoEntry{},
+		closeChan:     make(chan struct{}),
+	}
+}
+
+// Register records the intent of the key, the one registered before with the same key is replaced. The intent is
+// supposed to be done already if the client is connected, otherwise it's replayed once the client connects.
+func (s *RedoService) Register(key string, task RedoTask) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.entries[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.entries[key] = &redoEntry{task: task, pending: !s.connected}
+}
+
+// Remove removes the intent of the key, it's not replayed any more.
+func (s *RedoService) Remove(key string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.entries[key]; !ok {
+		return
+	}
+	delete(s.entries, key)
+	for i, k := range s.keys {
+		if k == key {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// IsRegistered returns whether the intent of the key is recorded.
+func (s *RedoService) IsRegistered(key string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, ok := s.entries[key]
+	return ok
+}
+
+// IsPending returns whether the intent of the key is waiting to be replayed.
+func (s *RedoService) IsPending(key string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entry, ok := s.entries[key]
+	return ok && entry.pending
+}
+
+// OnRedoFailure adds the listener called with every failure of replaying the tasks.
+func (s *RedoService) OnRedoFailure(listener func(failure RedoFailure)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failureListeners = append(s.failureListeners, listener)
+}
+
+// OnConnected replays all the intents in the order of registering.
+func (s *RedoService) OnConnected() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return
+	}
+	s.connected = true
+	s.generation++
+	s.markAllPending()
+	go s.redo(s.generation)
+}
+
+// OnDisConnect stops replaying, the intents are replayed again once the client connects.
+func (s *RedoService) OnDisConnect() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.connected = false
+	s.generation++
+	s.markAllPending()
+}
+
+// Close stops replaying the intents.
+func (s *RedoService) Close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.connected = false
+		s.generation++
+		close(s.closeChan)
+	}
+}
+
+func (s *RedoService) markAllPending() {
+	for _, entry := range s.entries {
+		entry.pending = true
+		entry.attempts = 0
+	}
+}
+
+// redo replays the pending tasks until all of them succeed, it stops once the generation changes by the
+// connection events.
+func (s *RedoService) redo(generation uint64) {
+	for {
+		keys, entries := s.pendingEntries(generation)
+		if len(keys) == 0 {
+			return
+		}
+		for i, key := range keys {
+			if !s.isCurrent(key, entries[i]) {
+				continue
+			}
+			err := entries[i].task()
+			if !s.finish(generation, key, entries[i], err) {
+				return
+			}
+		}
+		select {
+		case <-s.closeChan:
+			return
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
+func (s *RedoService) pendingEntries(generation uint64) ([]string, []*redoEntry) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.generation != generation {
+		return nil, nil
+	}
+	var keys []string
+	var entries []*redoEntry
+	for _, key := range s.keys {
+		if entry := s.entries[key]; entry.pending {
+			keys = append(keys, key)
+			entries = append(entries, entry)
+		}
+	}
+	return keys, entries
+}
+
+// isCurrent returns whether the entry is still the intent of the key, it's removed or replaced while replaying
+// the entries before otherwise.
+func (s *RedoService) isCurrent(key string, entry *redoEntry) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.entries[key] == entry
+}
+
+// finish records the result of the task, and returns false if the generation has changed. The result is ignored
+// if the intent of the key has been removed or replaced meanwhile.
+func (s *RedoService) finish(generation uint64, key string, entry *redoEntry, err error) bool {
+	s.mux.Lock()
+	if s.generation != generation {
+		s.mux.Unlock()
+		return false
+	}
+	if s.entries[key] != entry {
+		s.mux.Unlock()
+		return true
+	}
+	if err == nil {
+		entry.pending = false
+		s.mux.Unlock()
+		logger.Infof("%s redo %s successfully", s.clientName, key)
+		monitor.GetRedoMonitor(s.clientName, monitor.ResultSuccess).Inc()
+		return true
+	}
+	entry.attempts++
+	failure := RedoFailure{ClientName: s.clientName, Key: key, Attempts: entry.attempts, Err: err}
+	listeners := s.failureListeners
+	s.mux.Unlock()
+	logger.Warnf("%s redo %s failed, attempts:%d, err:%v", s.clientName, key, failure.Attempts, err)
+	monitor.GetRedoMonitor(s.clientName, monitor.ResultFailure).Inc()
+	for _, listener := range listeners {
+		listener(failure)
+	}
+	return true
+}
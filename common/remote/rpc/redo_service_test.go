@@ -0,0 +1,152 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedoServiceReplaysOnConnected(t *testing.T) {
+	redo := NewRedoService("test-redo", 10*time.Millisecond)
+	defer redo.Close()
+	var mux sync.Mutex
+	var replayed []string
+	task := func(key string) RedoTask {
+		return func() error {
+			mux.Lock()
+			defer mux.Unlock()
+			replayed = append(replayed, key)
+			return nil
+		}
+	}
+	redo.Register("a", task("a"))
+	redo.Register("b", task("b"))
+	redo.Register("c", task("c"))
+	redo.Remove("b")
+	assert.True(t, redo.IsPending("a"))
+	assert.False(t, redo.IsRegistered("b"))
+
+	redo.OnConnected()
+	assert.Eventually(t, func() bool { return !redo.IsPending("a") && !redo.IsPending("c") }, time.Second, 5*time.Millisecond)
+	mux.Lock()
+	assert.Equal(t, []string{"a", "c"}, replayed)
+	mux.Unlock()
+
+	// the intent registered while connected is done already
+	redo.Register("d", task("d"))
+	assert.False(t, redo.IsPending("d"))
+
+	redo.OnDisConnect()
+	assert.True(t, redo.IsPending("a"))
+	assert.True(t, redo.IsPending("d"))
+	redo.OnConnected()
+	assert.Eventually(t, func() bool {
+		mux.Lock()
+		defer mux.Unlock()
+		return len(replayed) == 5
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRedoServiceRetriesFailures(t *testing.T) {
+	redo := NewRedoService("test-redo-failure", 10*time.Millisecond)
+	defer redo.Close()
+	var attempts int32
+	redo.Register("failing", func() error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("server unavailable")
+		}
+		return nil
+	})
+	failures := make(chan RedoFailure, 10)
+	redo.OnRedoFailure(func(failure RedoFailure) {
+		failures <- failure
+	})
+
+	redo.OnConnected()
+	assert.Eventually(t, func() bool { return !redo.IsPending("failing") }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, 2, len(failures))
+	failure := <-failures
+	assert.Equal(t, "test-redo-failure", failure.ClientName)
+	assert.Equal(t, "failing", failure.Key)
+	assert.Equal(t, 1, failure.Attempts)
+	assert.EqualError(t, failure.Err, "server unavailable")
+}
+
+func TestRedoServiceStopsOnDisConnect(t *testing.T) {
+	redo := NewRedoService("test-redo-stop", 10*time.Millisecond)
+	defer redo.Close()
+	var attempts int32
+	redo.Register("failing", func() error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("server unavailable")
+	})
+
+	redo.OnConnected()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&attempts) > 0 }, time.Second, 5*time.Millisecond)
+	redo.OnDisConnect()
+	time.Sleep(30 * time.Millisecond)
+	count := atomic.LoadInt32(&attempts)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, count, atomic.LoadInt32(&attempts))
+	assert.True(t, redo.IsPending("failing"))
+}
+
+func TestRedoServiceSkipsRemovedAndReplaced(t *testing.T) {
+	redo := NewRedoService("test-redo-skip", 10*time.Millisecond)
+	defer redo.Close()
+	var mux sync.Mutex
+	var replayed []string
+	task := func(key string) RedoTask {
+		return func() error {
+			mux.Lock()
+			defer mux.Unlock()
+			replayed = append(replayed, key)
+			return nil
+		}
+	}
+	done := make(chan struct{})
+	redo.Register("a", func() error {
+		defer close(done)
+		redo.Remove("a")
+		redo.Remove("b")
+		redo.Register("c", task("c-new"))
+		return errors.New("server unavailable")
+	})
+	redo.Register("b", task("b"))
+	redo.Register("c", task("c-old"))
+	failures := make(chan RedoFailure, 10)
+	redo.OnRedoFailure(func(failure RedoFailure) {
+		failures <- failure
+	})
+
+	redo.OnConnected()
+	<-done
+	time.Sleep(30 * time.Millisecond)
+	mux.Lock()
+	assert.Empty(t, replayed)
+	mux.Unlock()
+	// the failure of the removed intent is not reported.
+	assert.Empty(t, failures)
+	assert.True(t, redo.IsRegistered("c"))
+}
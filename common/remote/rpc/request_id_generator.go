@@ -0,0 +1,46 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/inner/uuid"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+// IRequestIdGenerator generates the id of the requests sent by RpcClient.
+type IRequestIdGenerator interface {
+	NextRequestId() string
+}
+
+// UuidRequestIdGenerator generates random uuid as request id, it's the default generator of RpcClient.
+type UuidRequestIdGenerator struct {
+	fallbackSequence int64
+}
+
+func (g *UuidRequestIdGenerator) NextRequestId() string {
+	uid, err := uuid.NewV4()
+	if err != nil {
+		// the random source is broken, fallback to a time based id which is still unique in this process.
+		logger.Warnf("generate uuid request id failed, fallback to sequence, error=%+v", err)
+		return strconv.FormatInt(util.CurrentMillis(), 10) + "-" + strconv.FormatInt(atomic.AddInt64(&g.fallbackSequence, 1), 10)
+	}
+	return uid.String()
+}
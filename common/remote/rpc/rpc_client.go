@@ -17,18 +17,23 @@
 package rpc
 
 import (
+	"context"
 	"math"
 	"reflect"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/common/tracing"
 	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
 	"github.com/allenliu88/xgrpc-client-go/util"
 )
@@ -96,6 +101,7 @@ type RpcClient struct {
 	Name                        string
 	labels                      map[string]string
 	currentConnection           IConnection
+	connectionMux               sync.RWMutex
 	rpcClientStatus             RpcClientStatus
 	eventChan                   chan ConnectionEvent
 	reconnectionChan            chan ReconnectContext
@@ -107,6 +113,13 @@ type RpcClient struct {
 	mux                         *sync.Mutex
 	clientAbilities             rpc_request.ClientAbilities
 	Tenant                      string
+	BiStreamRequest             bool
+	requestIdGenerator          IRequestIdGenerator
+	serverListChangeChan        chan struct{}
+	serverListVersion           uint64
+	unsubscribeServerList       func()
+	redoService                 *RedoService
+	circuitBreakers             *CircuitBreakers
 }
 
 type ServerRequestHandlerMapping struct {
@@ -157,6 +170,7 @@ func CreateClient(clientName string, connectionType ConnectionType, labels map[s
 			return nil, errors.New("unsupported connection type")
 		}
 		rpcClient.putAllLabels(labels)
+		rpcClient.GetRpcClient().reportStatus(INITIALIZED)
 		clientMap[clientName] = rpcClient
 		return rpcClient, nil
 	}
@@ -164,10 +178,18 @@ func CreateClient(clientName string, connectionType ConnectionType, labels map[s
 }
 
 func (r *RpcClient) Start() {
-	if ok := atomic.CompareAndSwapInt32((*int32)(&r.rpcClientStatus), (int32)(INITIALIZED), (int32)(STARTING)); !ok {
+	if ok := r.compareAndSetStatus(INITIALIZED, STARTING); !ok {
 		return
 	}
 	r.registerServerRequestHandlers()
+	_, r.serverListVersion = r.xgrpcServer.GetServerListWithVersion()
+	r.unsubscribeServerList = r.xgrpcServer.OnServerListChanged(func(event xgrpc_server.ServerListChangeEvent) {
+		// the latest server list is read when handling, so the pending signal covers the following changes.
+		select {
+		case r.serverListChangeChan <- struct{}{}:
+		default:
+		}
+	})
 	go func() {
 		for {
 			event := <-r.eventChan
@@ -198,7 +220,7 @@ func (r *RpcClient) Start() {
 				r.reconnect(rc.serverInfo, rc.onRequestFail)
 			case <-timer.C:
 				r.healthCheck(timer)
-			case <-r.xgrpcServer.ServerSrcChangeSignal:
+			case <-r.serverListChangeChan:
 				r.notifyServerSrvChange()
 			}
 		}
@@ -225,8 +247,8 @@ func (r *RpcClient) Start() {
 	if currentConnection != nil {
 		logger.Infof("%s success to connect to server %+v on start up, connectionId=%s", r.Name,
 			currentConnection.getServerInfo(), currentConnection.getConnectionId())
-		r.currentConnection = currentConnection
-		atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(RUNNING))
+		r.setCurrentConnection(currentConnection)
+		r.setStatus(RUNNING)
 		r.eventChan <- ConnectionEvent{eventType: CONNECTED}
 	} else {
 		r.switchServerAsync(ServerInfo{}, false)
@@ -234,13 +256,20 @@ func (r *RpcClient) Start() {
 }
 
 func (r *RpcClient) notifyServerSrvChange() {
-	if r.currentConnection == nil {
+	servers, version := r.xgrpcServer.GetServerListWithVersion()
+	if version <= r.serverListVersion {
+		return
+	}
+	logger.Infof("%s server list is changed from version %d to %d", r.Name, r.serverListVersion, version)
+	r.serverListVersion = version
+	currentConnection := r.getCurrentConnection()
+	if currentConnection == nil {
 		r.switchServerAsync(ServerInfo{}, false)
 		return
 	}
-	curServerInfo := r.currentConnection.getServerInfo()
+	curServerInfo := currentConnection.getServerInfo()
 	var found bool
-	for _, ele := range r.xgrpcServer.GetServerList() {
+	for _, ele := range servers {
 		if ele.IpAddr == curServerInfo.serverIp {
 			found = true
 		}
@@ -264,7 +293,11 @@ func (r *RpcClient) registerServerRequestHandlers() {
 }
 
 func (r *RpcClient) Shutdown() {
-	atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(SHUTDOWN))
+	r.setStatus(SHUTDOWN)
+	r.redoService.Close()
+	if r.unsubscribeServerList != nil {
+		r.unsubscribeServerList()
+	}
 	r.closeConnection()
 }
 
@@ -282,6 +315,31 @@ func (r *RpcClient) RegisterServerRequestHandler(request func() rpc_request.IReq
 	}
 }
 
+// SetRequestIdGenerator replace the generator used to fill the request id of outgoing requests.
+func (r *RpcClient) SetRequestIdGenerator(generator IRequestIdGenerator) {
+	if generator == nil {
+		return
+	}
+	r.requestIdGenerator = generator
+}
+
+// fillRequestId set a generated request id if the request does not carry one.
+func (r *RpcClient) fillRequestId(request rpc_request.IRequest) {
+	if request.GetRequestId() == "" && r.requestIdGenerator != nil {
+		request.SetRequestId(r.requestIdGenerator.NextRequestId())
+	}
+}
+
+// GetRedoService returns the redo service replaying the intents of the client after reconnecting.
+func (r *RpcClient) GetRedoService() *RedoService {
+	return r.redoService
+}
+
+// GetCircuitBreakers returns the circuit breakers of the servers and the request types of the client.
+func (r *RpcClient) GetCircuitBreakers() *CircuitBreakers {
+	return r.circuitBreakers
+}
+
 func (r *RpcClient) RegisterConnectionListener(listener IConnectionEventListener) {
 	logger.Debugf("%s register connection listener [%+v] to current client", r.Name, reflect.TypeOf(listener))
 	listeners := r.connectionEventListeners.Load()
@@ -295,9 +353,22 @@ func (r *RpcClient) switchServerAsync(recommendServerInfo ServerInfo, onRequestF
 }
 
 func (r *RpcClient) reconnect(serverInfo ServerInfo, onRequestFail bool) {
+	_, span := tracing.StartSpan(context.Background(), "xgrpc.reconnect", trace.SpanKindInternal,
+		tracing.ClientNameKey.String(r.Name), tracing.OnRequestFailKey.Bool(onRequestFail))
+	var spanErr error
+	defer func() {
+		tracing.EndSpan(span, spanErr)
+		result := monitor.ResultSuccess
+		if spanErr != nil {
+			result = monitor.ResultFailure
+		}
+		monitor.GetReconnectMonitor(r.Name, result).Inc()
+	}()
 	if onRequestFail && r.sendHealthCheck() {
-		logger.Infof("%s server check success, currentServer is %+v", r.Name, r.currentConnection.getServerInfo())
-		atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(RUNNING))
+		currentConnection := r.getCurrentConnection()
+		logger.Infof("%s server check success, currentServer is %+v", r.Name, currentConnection.getServerInfo())
+		r.setStatus(RUNNING)
+		span.SetAttributes(tracing.ConnectionIdKey.String(currentConnection.getConnectionId()))
 		return
 	}
 	var (
@@ -315,6 +386,7 @@ func (r *RpcClient) reconnect(serverInfo ServerInfo, onRequestFail bool) {
 			serverInfo, err = r.nextRpcServer()
 			if err != nil {
 				logger.Errorf("[RpcClient.nextRpcServer],err:%v", err)
+				spanErr = err
 				break
 			}
 		}
@@ -323,14 +395,17 @@ func (r *RpcClient) reconnect(serverInfo ServerInfo, onRequestFail bool) {
 			logger.Infof("%s success to connect a server %+v, connectionId=%s", r.Name, serverInfo,
 				connectionNew.getConnectionId())
 
-			if r.currentConnection != nil {
+			if currentConnection := r.getCurrentConnection(); currentConnection != nil {
 				logger.Infof("%s abandon prev connection, server is %+v, connectionId is %s", r.Name, serverInfo,
-					r.currentConnection.getConnectionId())
-				r.currentConnection.setAbandon(true)
+					currentConnection.getConnectionId())
+				currentConnection.setAbandon(true)
 				r.closeConnection()
 			}
-			r.currentConnection = connectionNew
-			atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(RUNNING))
+			r.setCurrentConnection(connectionNew)
+			r.setStatus(RUNNING)
+			span.SetAttributes(tracing.ReconnectTimesKey.Int(reConnectTimes),
+				tracing.ConnectionIdKey.String(connectionNew.getConnectionId()),
+				tracing.ServerAddressKey.String(serverInfo.serverIp+":"+strconv.FormatUint(serverInfo.serverPort, 10)))
 			r.eventChan <- ConnectionEvent{eventType: CONNECTED}
 			return
 		}
@@ -351,12 +426,27 @@ func (r *RpcClient) reconnect(serverInfo ServerInfo, onRequestFail bool) {
 	}
 	if r.isShutdown() {
 		logger.Warnf("%s client is shutdown, stop reconnect to server", r.Name)
+		spanErr = errors.New("client is shutdown")
 	}
+	span.SetAttributes(tracing.ReconnectTimesKey.Int(reConnectTimes))
+}
+
+// getCurrentConnection returns the connection in use, it's replaced by the reconnecting concurrently with the requests.
+func (r *RpcClient) getCurrentConnection() IConnection {
+	r.connectionMux.RLock()
+	defer r.connectionMux.RUnlock()
+	return r.currentConnection
+}
+
+func (r *RpcClient) setCurrentConnection(connection IConnection) {
+	r.connectionMux.Lock()
+	defer r.connectionMux.Unlock()
+	r.currentConnection = connection
 }
 
 func (r *RpcClient) closeConnection() {
-	if r.currentConnection != nil {
-		r.currentConnection.close()
+	if currentConnection := r.getCurrentConnection(); currentConnection != nil {
+		currentConnection.close()
 		r.eventChan <- ConnectionEvent{eventType: DISCONNECTED}
 	}
 }
@@ -389,22 +479,32 @@ func (r *RpcClient) healthCheck(timer *time.Timer) {
 		r.lastActiveTimestamp.Store(time.Now())
 		return
 	} else {
-		if r.currentConnection == nil {
+		currentConnection := r.getCurrentConnection()
+		if currentConnection == nil {
 			return
 		}
-		logger.Infof("%s server healthy check fail, currentConnection=%s", r.Name, r.currentConnection.getConnectionId())
-		atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(UNHEALTHY))
+		logger.Infof("%s server healthy check fail, currentConnection=%s", r.Name, currentConnection.getConnectionId())
+		r.setStatus(UNHEALTHY)
 		reconnectContext = ReconnectContext{onRequestFail: false}
 	}
 	r.reconnect(reconnectContext.serverInfo, reconnectContext.onRequestFail)
 }
 
-func (r *RpcClient) sendHealthCheck() bool {
-	if r.currentConnection == nil {
+func (r *RpcClient) sendHealthCheck() (healthy bool) {
+	currentConnection := r.getCurrentConnection()
+	if currentConnection == nil {
 		return false
 	}
-	response, err := r.currentConnection.request(rpc_request.NewHealthCheckRequest(),
-		constant.DEFAULT_TIMEOUT_MILLS, r)
+	defer func() {
+		result := monitor.ResultSuccess
+		if !healthy {
+			result = monitor.ResultFailure
+		}
+		monitor.GetHealthCheckMonitor(r.Name, result).Inc()
+	}()
+	healthCheckRequest := rpc_request.NewHealthCheckRequest()
+	r.fillRequestId(healthCheckRequest)
+	response, err := currentConnection.request(healthCheckRequest, constant.DEFAULT_TIMEOUT_MILLS, r)
 	if err != nil {
 		return false
 	}
@@ -440,6 +540,34 @@ func (c *ConnectionEvent) isDisConnected() bool {
 	return c.eventType == DISCONNECTED
 }
 
+func (r *RpcClient) getStatus() RpcClientStatus {
+	return RpcClientStatus(atomic.LoadInt32((*int32)(&r.rpcClientStatus)))
+}
+
+func (r *RpcClient) setStatus(status RpcClientStatus) {
+	atomic.StoreInt32((*int32)(&r.rpcClientStatus), (int32)(status))
+	r.reportStatus(status)
+}
+
+func (r *RpcClient) compareAndSetStatus(expect, update RpcClientStatus) bool {
+	if atomic.CompareAndSwapInt32((*int32)(&r.rpcClientStatus), (int32)(expect), (int32)(update)) {
+		r.reportStatus(update)
+		return true
+	}
+	return false
+}
+
+// reportStatus set the connection state gauge of current status to 1 and the others to 0.
+func (r *RpcClient) reportStatus(status RpcClientStatus) {
+	for s := INITIALIZED; s <= SHUTDOWN; s++ {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		monitor.GetConnectionStateMonitor(r.Name, s.getDesc()).Set(value)
+	}
+}
+
 //check is this client is shutdown.
 func (r *RpcClient) isShutdown() bool {
 	return atomic.LoadInt32((*int32)(&r.rpcClientStatus)) == (int32)(SHUTDOWN)
@@ -465,23 +593,43 @@ func (c *ConnectionEvent) toString() string {
 }
 
 func (r *RpcClient) Request(request rpc_request.IRequest, timeoutMills int64) (rpc_response.IResponse, error) {
+	return r.RequestWithContext(context.Background(), request, timeoutMills)
+}
+
+// RequestWithContext send the request, every attempt is traced as a child span of the span in ctx.
+func (r *RpcClient) RequestWithContext(ctx context.Context, request rpc_request.IRequest, timeoutMills int64) (rpc_response.IResponse, error) {
 	retryTimes := 0
 	start := util.CurrentMillis()
 	var currentErr error
+	r.fillRequestId(request)
 	for retryTimes < constant.REQUEST_DOMAIN_RETRY_TIME && util.CurrentMillis() < start+timeoutMills {
-		if r.currentConnection == nil || !r.IsRunning() {
+		if retryTimes > 0 {
+			monitor.GetRequestRetryMonitor(r.Name, r.Tenant, request.GetRequestType()).Inc()
+		}
+		currentConnection := r.getCurrentConnection()
+		if currentConnection == nil || !r.IsRunning() {
+			currentErr = waitReconnect(timeoutMills, &retryTimes, request,
+				errors.Errorf("client not connected, current status:%s", r.getStatus().getDesc()))
+			continue
+		}
+		response, err := r.requestThroughCircuit(ctx, currentConnection, request, timeoutMills, retryTimes)
+		if errors.Is(err, ErrCircuitOpen) {
+			// the server is still healthy for the other request types, so it's neither retried nor switched.
+			return r.circuitBreakers.fallback(request, err)
+		}
+		if err == nil && !response.IsSuccess() && xgrpc_server.IsAuthFailure(response.GetErrorCode()) {
+			r.refreshSecurityInfo(request)
 			currentErr = waitReconnect(timeoutMills, &retryTimes, request,
-				errors.Errorf("client not connected, current status:%s", r.rpcClientStatus.getDesc()))
+				errors.Errorf("auth failed, code:%d, message:%s", response.GetErrorCode(), response.GetMessage()))
 			continue
 		}
-		response, err := r.currentConnection.request(request, timeoutMills, r)
 		if err == nil {
 			if response, ok := response.(*rpc_response.ErrorResponse); ok {
 				if response.GetErrorCode() == constant.UN_REGISTER {
 					r.mux.Lock()
-					if atomic.CompareAndSwapInt32((*int32)(&r.rpcClientStatus), (int32)(RUNNING), (int32)(UNHEALTHY)) {
+					if r.compareAndSetStatus(RUNNING, UNHEALTHY) {
 						logger.Infof("Connection is unregistered, switch server, connectionId=%s, request=%s",
-							r.currentConnection.getConnectionId(), request.GetRequestType())
+							currentConnection.getConnectionId(), request.GetRequestType())
 						r.switchServerAsync(ServerInfo{}, false)
 					}
 					r.mux.Unlock()
@@ -489,6 +637,11 @@ func (r *RpcClient) Request(request rpc_request.IRequest, timeoutMills int64) (r
 				currentErr = waitReconnect(timeoutMills, &retryTimes, request, errors.New(response.GetMessage()))
 				continue
 			}
+			if response.GetRequestId() != "" && response.GetRequestId() != request.GetRequestId() {
+				currentErr = waitReconnect(timeoutMills, &retryTimes, request,
+					errors.Errorf("response requestId:%s not match, response type:%s", response.GetRequestId(), response.GetResponseType()))
+				continue
+			}
 			r.lastActiveTimestamp.Store(time.Now())
 			return response, nil
 		} else {
@@ -496,7 +649,7 @@ func (r *RpcClient) Request(request rpc_request.IRequest, timeoutMills int64) (r
 		}
 	}
 
-	if atomic.CompareAndSwapInt32((*int32)(&r.rpcClientStatus), int32(RUNNING), int32(UNHEALTHY)) {
+	if r.compareAndSetStatus(RUNNING, UNHEALTHY) {
 		r.switchServerAsync(ServerInfo{}, true)
 	}
 	if currentErr != nil {
@@ -505,8 +658,56 @@ func (r *RpcClient) Request(request rpc_request.IRequest, timeoutMills int64) (r
 	return nil, errors.New("request fail, unknown error")
 }
 
+// refreshSecurityInfo refresh the credential rejected by the server and inject the new one for the retry.
+func (r *RpcClient) refreshSecurityInfo(request rpc_request.IRequest) {
+	if r.xgrpcServer == nil {
+		return
+	}
+	if err := r.xgrpcServer.OnAuthFailure(request.GetHeaders()[constant.KEY_ACCESS_TOKEN]); err != nil {
+		logger.Errorf("%s refresh the rejected credential failed, requestId=%s, error=%+v", r.Name,
+			request.GetRequestId(), err)
+		return
+	}
+	r.xgrpcServer.InjectSecurityInfo(request.GetHeaders())
+}
+
+// requestThroughCircuit send the request unless the circuit of the server of the connection and the request type is
+// open, the errors and the ErrorResponse are counted as the failed calls except the auth failures, which are retried
+// with the refreshed credential.
+func (r *RpcClient) requestThroughCircuit(ctx context.Context, connection IConnection, request rpc_request.IRequest, timeoutMills int64, retryTimes int) (rpc_response.IResponse, error) {
+	if !r.circuitBreakers.Enabled() {
+		return r.requestOnce(ctx, connection, request, timeoutMills, retryTimes)
+	}
+	serverInfo := connection.getServerInfo()
+	breaker := r.circuitBreakers.Get(serverInfo.serverIp+":"+strconv.FormatUint(serverInfo.serverPort, 10),
+		request.GetRequestType())
+	permit, err := breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	response, err := r.requestOnce(ctx, connection, request, timeoutMills, retryTimes)
+	_, isErrorResponse := response.(*rpc_response.ErrorResponse)
+	failed := err != nil || (isErrorResponse && !xgrpc_server.IsAuthFailure(response.GetErrorCode()))
+	breaker.record(permit, failed, time.Since(start))
+	return response, err
+}
+
+// requestOnce send the request with the connection in a span of the attempt.
+func (r *RpcClient) requestOnce(ctx context.Context, connection IConnection, request rpc_request.IRequest, timeoutMills int64, retryTimes int) (rpc_response.IResponse, error) {
+	attrs := append(tracing.RequestAttributes(r.Name, request), tracing.RetryTimesKey.Int(retryTimes),
+		tracing.ConnectionIdKey.String(connection.getConnectionId()))
+	attemptCtx, span := tracing.StartSpan(ctx, "xgrpc.attempt "+request.GetRequestType(), trace.SpanKindClient, attrs...)
+	tracing.Inject(attemptCtx, request.GetHeaders())
+	response, err := connection.request(request, timeoutMills, r)
+	span.SetAttributes(tracing.ResponseAttributes(response)...)
+	tracing.EndSpan(span, err)
+	return response, err
+}
+
 func waitReconnect(timeoutMills int64, retryTimes *int, request rpc_request.IRequest, err error) error {
-	logger.Errorf("Send request fail, request=%s, body=%s, retryTimes=%v, error=%+v", request.GetRequestType(), request.GetBody(request), *retryTimes, err)
+	logger.Errorf("Send request fail, request=%s, requestId=%s, body=%s, retryTimes=%v, error=%+v", request.GetRequestType(),
+		request.GetRequestId(), request.GetBody(request), *retryTimes, err)
 	time.Sleep(time.Duration(math.Min(100, float64(timeoutMills/3))) * time.Millisecond)
 	*retryTimes++
 	return err
@@ -1,7 +1,180 @@
 package rpc
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/monitor"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+	"github.com/allenliu88/xgrpc-client-go/common/security"
+	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_server"
+)
 
 func TestHealthCheck(t *testing.T) {
 
 }
+
+type mockEchoConnection struct {
+	MockConnection
+	requestIds []string
+	mismatch   bool
+}
+
+func (m *mockEchoConnection) request(request rpc_request.IRequest, timeoutMills int64, client *RpcClient) (rpc_response.IResponse, error) {
+	m.requestIds = append(m.requestIds, request.GetRequestId())
+	requestId := request.GetRequestId()
+	if m.mismatch {
+		requestId = "mismatch"
+	}
+	return &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{RequestId: requestId, Success: true}}, nil
+}
+
+type mockRequestIdGenerator struct {
+}
+
+func (g *mockRequestIdGenerator) NextRequestId() string {
+	return "mock-request-id"
+}
+
+func newRunningRpcClient(connection IConnection) *RpcClient {
+	rpcClient := NewGrpcClient("test", nil).RpcClient
+	rpcClient.setCurrentConnection(connection)
+	rpcClient.rpcClientStatus = RUNNING
+	return rpcClient
+}
+
+func TestRequestFillRequestId(t *testing.T) {
+	connection := &mockEchoConnection{}
+	rpcClient := newRunningRpcClient(connection)
+
+	request := rpc_request.NewHealthCheckRequest()
+	response, err := rpcClient.Request(request, 1000)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, request.GetRequestId())
+	assert.Equal(t, request.GetRequestId(), response.GetRequestId())
+
+	request = rpc_request.NewHealthCheckRequest()
+	request.SetRequestId("caller-request-id")
+	_, err = rpcClient.Request(request, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, "caller-request-id", connection.requestIds[1])
+}
+
+func TestRequestWithCustomRequestIdGenerator(t *testing.T) {
+	rpcClient := newRunningRpcClient(&mockEchoConnection{})
+	rpcClient.SetRequestIdGenerator(&mockRequestIdGenerator{})
+
+	request := rpc_request.NewHealthCheckRequest()
+	_, err := rpcClient.Request(request, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, "mock-request-id", request.GetRequestId())
+}
+
+func TestRequestIdMismatch(t *testing.T) {
+	connection := &mockEchoConnection{mismatch: true}
+	rpcClient := newRunningRpcClient(connection)
+
+	_, err := rpcClient.Request(rpc_request.NewHealthCheckRequest(), 300)
+	assert.NotNil(t, err)
+	// retries keep the same request id.
+	for _, requestId := range connection.requestIds {
+		assert.Equal(t, connection.requestIds[0], requestId)
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	rpcClient := NewGrpcClient("test-status", nil).RpcClient
+	rpcClient.setStatus(RUNNING)
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitor.GetConnectionStateMonitor("test-status", "RUNNING")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(monitor.GetConnectionStateMonitor("test-status", "UNHEALTHY")))
+
+	assert.True(t, rpcClient.compareAndSetStatus(RUNNING, UNHEALTHY))
+	assert.False(t, rpcClient.compareAndSetStatus(RUNNING, UNHEALTHY))
+	assert.Equal(t, float64(0), testutil.ToFloat64(monitor.GetConnectionStateMonitor("test-status", "RUNNING")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitor.GetConnectionStateMonitor("test-status", "UNHEALTHY")))
+}
+
+func TestRequestRetryMetrics(t *testing.T) {
+	rpcClient := newRunningRpcClient(&mockEchoConnection{mismatch: true})
+	rpcClient.Name = "test-retry"
+	_, _ = rpcClient.Request(rpc_request.NewHealthCheckRequest(), 300)
+	assert.True(t, testutil.ToFloat64(monitor.GetRequestRetryMonitor("test-retry", "", "HealthCheckRequest")) > 0)
+}
+
+type mockAuthConnection struct {
+	MockConnection
+	tokens []string
+}
+
+func (m *mockAuthConnection) request(request rpc_request.IRequest, timeoutMills int64, client *RpcClient) (rpc_response.IResponse, error) {
+	token := request.GetHeaders()[constant.KEY_ACCESS_TOKEN]
+	m.tokens = append(m.tokens, token)
+	if token != "token-2" {
+		return &rpc_response.ErrorResponse{Response: &rpc_response.Response{RequestId: request.GetRequestId(),
+			ResultCode: 500, ErrorCode: constant.RESPONSE_CODE_FORBIDDEN, Message: "token expired"}}, nil
+	}
+	return &rpc_response.HealthCheckResponse{Response: &rpc_response.Response{RequestId: request.GetRequestId(), Success: true}}, nil
+}
+
+type mockRefreshableAuthProvider struct {
+	security.StaticTokenProvider
+	token string
+}
+
+func (p *mockRefreshableAuthProvider) GetSecurityInfo() map[string]string {
+	return map[string]string{constant.KEY_ACCESS_TOKEN: p.token}
+}
+
+func (p *mockRefreshableAuthProvider) Refresh(rejectedToken string) error {
+	p.token = "token-2"
+	return nil
+}
+
+func TestRequestRefreshTokenOnAuthFailure(t *testing.T) {
+	security.RegisterAuthProvider("mockRefreshable", func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig,
+		agent http_agent.IHttpAgent) security.AuthProvider {
+		return &mockRefreshableAuthProvider{token: "token-1"}
+	})
+	xgrpcServer, err := xgrpc_server.NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "mockRefreshable"}}, nil, 3000, "")
+	assert.Nil(t, err)
+
+	connection := &mockAuthConnection{}
+	rpcClient := newRunningRpcClient(connection)
+	rpcClient.xgrpcServer = xgrpcServer
+	request := rpc_request.NewHealthCheckRequest()
+	xgrpcServer.InjectSecurityInfo(request.GetHeaders())
+
+	response, err := rpcClient.Request(request, 3000)
+	assert.Nil(t, err)
+	assert.True(t, response.IsSuccess())
+	assert.Equal(t, []string{"token-1", "token-2"}, connection.tokens)
+}
+
+func TestNotifyServerSrvChange(t *testing.T) {
+	xgrpcServer, err := xgrpc_server.NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{}, nil, 3000, "")
+	assert.Nil(t, err)
+	rpcClient := newRunningRpcClient(&MockConnection{})
+	rpcClient.xgrpcServer = xgrpcServer
+
+	// the server list isn't changed since the client started
+	rpcClient.notifyServerSrvChange()
+	assert.Equal(t, 0, len(rpcClient.reconnectionChan))
+
+	_, changed := xgrpcServer.UpdateServerList([]constant.ServerConfig{{IpAddr: "127.0.0.2", Port: 8848}})
+	assert.True(t, changed)
+	rpcClient.notifyServerSrvChange()
+	assert.Equal(t, 1, len(rpcClient.reconnectionChan))
+	assert.Equal(t, uint64(1), rpcClient.serverListVersion)
+
+	// the handled version is ignored
+	<-rpcClient.reconnectionChan
+	rpcClient.notifyServerSrvChange()
+	assert.Equal(t, 0, len(rpcClient.reconnectionChan))
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc_request
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/model"
+)
+
+type ConfigRequest struct {
+	*Request
+	Group  string `json:"group"`
+	DataId string `json:"dataId"`
+	Tenant string `json:"tenant"`
+	Module string `json:"module"`
+}
+
+func NewConfigRequest(group, dataId, tenant string) *ConfigRequest {
+	request := Request{
+		Headers: make(map[string]string, 8),
+	}
+	return &ConfigRequest{
+		Request: &request,
+		Group:   group,
+		DataId:  dataId,
+		Tenant:  tenant,
+		Module:  "config",
+	}
+}
+
+func (r *ConfigRequest) GetDataId() string {
+	return r.DataId
+}
+
+func (r *ConfigRequest) GetGroup() string {
+	return r.Group
+}
+
+func (r *ConfigRequest) GetTenant() string {
+	return r.Tenant
+}
+
+// GetSignResource makes the config requests signable, the tenant and the group are signed.
+func (r *ConfigRequest) GetSignResource() string {
+	if r.Tenant != "" && r.Group != "" {
+		return r.Tenant + "+" + r.Group
+	}
+	return r.Group
+}
+
+type ConfigBatchListenRequest struct {
+	*ConfigRequest
+	Listen               bool                        `json:"listen"`
+	ConfigListenContexts []model.ConfigListenContext `json:"configListenContexts"`
+}
+
+func NewConfigBatchListenRequest(listen bool, contexts []model.ConfigListenContext) *ConfigBatchListenRequest {
+	return &ConfigBatchListenRequest{
+		ConfigRequest:        NewConfigRequest("", "", ""),
+		Listen:               listen,
+		ConfigListenContexts: contexts,
+	}
+}
+
+func (r *ConfigBatchListenRequest) GetRequestType() string {
+	return "ConfigBatchListenRequest"
+}
+
+type ConfigChangeNotifyRequest struct {
+	*ConfigRequest
+}
+
+func NewConfigChangeNotifyRequest(group, dataId, tenant string) *ConfigChangeNotifyRequest {
+	return &ConfigChangeNotifyRequest{
+		ConfigRequest: NewConfigRequest(group, dataId, tenant),
+	}
+}
+
+func (r *ConfigChangeNotifyRequest) GetRequestType() string {
+	return "ConfigChangeNotifyRequest"
+}
+
+type ConfigQueryRequest struct {
+	*ConfigRequest
+	Tag string `json:"tag"`
+}
+
+func NewConfigQueryRequest(group, dataId, tenant string) *ConfigQueryRequest {
+	return &ConfigQueryRequest{
+		ConfigRequest: NewConfigRequest(group, dataId, tenant),
+	}
+}
+
+func (r *ConfigQueryRequest) GetRequestType() string {
+	return "ConfigQueryRequest"
+}
+
+type ConfigPublishRequest struct {
+	*ConfigRequest
+	Content     string            `json:"content"`
+	CasMd5      string            `json:"casMd5"`
+	AdditionMap map[string]string `json:"additionMap"`
+}
+
+func NewConfigPublishRequest(group, dataId, tenant, content, casMd5 string) *ConfigPublishRequest {
+	return &ConfigPublishRequest{
+		ConfigRequest: NewConfigRequest(group, dataId, tenant),
+		Content:       content,
+		CasMd5:        casMd5,
+		AdditionMap:   make(map[string]string),
+	}
+}
+
+func (r *ConfigPublishRequest) GetRequestType() string {
+	return "ConfigPublishRequest"
+}
+
+type ConfigRemoveRequest struct {
+	*ConfigRequest
+	Tag string `json:"tag"`
+}
+
+func NewConfigRemoveRequest(group, dataId, tenant, tag string) *ConfigRemoveRequest {
+	return &ConfigRemoveRequest{
+		ConfigRequest: NewConfigRequest(group, dataId, tenant),
+		Tag:           tag,
+	}
+}
+
+func (r *ConfigRemoveRequest) GetRequestType() string {
+	return "ConfigRemoveRequest"
+}
+
+// ConfigSearchRequest searches the configs of the tenant, the dataId and the group are matched
+// exactly when Search is "accurate" and by the wildcard '*' when it is "blur".
+type ConfigSearchRequest struct {
+	*ConfigRequest
+	Search   string `json:"search"`
+	Tag      string `json:"tag"`
+	AppName  string `json:"appName"`
+	PageNo   int    `json:"pageNo"`
+	PageSize int    `json:"pageSize"`
+}
+
+func NewConfigSearchRequest(group, dataId, tenant, search string, pageNo, pageSize int) *ConfigSearchRequest {
+	return &ConfigSearchRequest{
+		ConfigRequest: NewConfigRequest(group, dataId, tenant),
+		Search:        search,
+		PageNo:        pageNo,
+		PageSize:      pageSize,
+	}
+}
+
+func (r *ConfigSearchRequest) GetRequestType() string {
+	return "ConfigSearchRequest"
+}
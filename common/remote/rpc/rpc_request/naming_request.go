@@ -0,0 +1,162 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc_request
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/model"
+	"github.com/allenliu88/xgrpc-client-go/util"
+)
+
+const (
+	REGISTER_INSTANCE   = "registerInstance"
+	DEREGISTER_INSTANCE = "deregisterInstance"
+	UPDATE_INSTANCE     = "updateInstance"
+)
+
+type NamingRequest struct {
+	*Request
+	Namespace   string `json:"namespace"`
+	ServiceName string `json:"serviceName"`
+	GroupName   string `json:"groupName"`
+	Module      string `json:"module"`
+}
+
+func NewNamingRequest(namespace, serviceName, groupName string) *NamingRequest {
+	request := Request{
+		Headers: make(map[string]string, 8),
+	}
+	return &NamingRequest{
+		Request:     &request,
+		Namespace:   namespace,
+		ServiceName: serviceName,
+		GroupName:   groupName,
+		Module:      "naming",
+	}
+}
+
+// GetSignResource makes the naming requests signable, the grouped service name is signed.
+func (r *NamingRequest) GetSignResource() string {
+	return util.GetGroupName(r.ServiceName, r.GroupName)
+}
+
+type InstanceRequest struct {
+	*NamingRequest
+	Type     string         `json:"type"`
+	Instance model.Instance `json:"instance"`
+}
+
+func NewInstanceRequest(namespace, serviceName, groupName, Type string, instance model.Instance) *InstanceRequest {
+	return &InstanceRequest{
+		NamingRequest: NewNamingRequest(namespace, serviceName, groupName),
+		Type:          Type,
+		Instance:      instance,
+	}
+}
+
+func (r *InstanceRequest) GetRequestType() string {
+	return "InstanceRequest"
+}
+
+type ServiceQueryRequest struct {
+	*NamingRequest
+	Cluster     string `json:"cluster"`
+	HealthyOnly bool   `json:"healthyOnly"`
+	UdpPort     int    `json:"udpPort"`
+}
+
+func NewServiceQueryRequest(namespace, serviceName, groupName, cluster string, healthyOnly bool) *ServiceQueryRequest {
+	return &ServiceQueryRequest{
+		NamingRequest: NewNamingRequest(namespace, serviceName, groupName),
+		Cluster:       cluster,
+		HealthyOnly:   healthyOnly,
+	}
+}
+
+func (r *ServiceQueryRequest) GetRequestType() string {
+	return "ServiceQueryRequest"
+}
+
+type SubscribeServiceRequest struct {
+	*NamingRequest
+	Subscribe bool   `json:"subscribe"`
+	Clusters  string `json:"clusters"`
+}
+
+func NewSubscribeServiceRequest(namespace, serviceName, groupName, clusters string, subscribe bool) *SubscribeServiceRequest {
+	return &SubscribeServiceRequest{
+		NamingRequest: NewNamingRequest(namespace, serviceName, groupName),
+		Subscribe:     subscribe,
+		Clusters:      clusters,
+	}
+}
+
+func (r *SubscribeServiceRequest) GetRequestType() string {
+	return "SubscribeServiceRequest"
+}
+
+type ServiceListRequest struct {
+	*NamingRequest
+	PageNo   int    `json:"pageNo"`
+	PageSize int    `json:"pageSize"`
+	Selector string `json:"selector"`
+}
+
+func NewServiceListRequest(namespace, groupName string, pageNo, pageSize int) *ServiceListRequest {
+	return &ServiceListRequest{
+		NamingRequest: NewNamingRequest(namespace, "", groupName),
+		PageNo:        pageNo,
+		PageSize:      pageSize,
+	}
+}
+
+func (r *ServiceListRequest) GetRequestType() string {
+	return "ServiceListRequest"
+}
+
+// NotifySubscriberRequest is pushed by the server when the subscribed service changes.
+type NotifySubscriberRequest struct {
+	*NamingRequest
+	ServiceInfo model.Service `json:"serviceInfo"`
+}
+
+func NewNotifySubscriberRequest(service model.Service) *NotifySubscriberRequest {
+	return &NotifySubscriberRequest{
+		NamingRequest: NewNamingRequest("", service.Name, service.GroupName),
+		ServiceInfo:   service,
+	}
+}
+
+func (r *NotifySubscriberRequest) GetRequestType() string {
+	return "NotifySubscriberRequest"
+}
+
+// InstanceBeatRequest is the heartbeat of the ephemeral instance.
+type InstanceBeatRequest struct {
+	*NamingRequest
+	BeatInfo model.BeatInfo `json:"beatInfo"`
+}
+
+func NewInstanceBeatRequest(namespace, serviceName, groupName string, beatInfo model.BeatInfo) *InstanceBeatRequest {
+	return &InstanceBeatRequest{
+		NamingRequest: NewNamingRequest(namespace, serviceName, groupName),
+		BeatInfo:      beatInfo,
+	}
+}
+
+func (r *InstanceBeatRequest) GetRequestType() string {
+	return "InstanceBeatRequest"
+}
@@ -29,9 +29,17 @@ type IRequest interface {
 	GetBody(request IRequest) string
 	PutAllHeaders(headers map[string]string)
 	GetRequestId() string
+	SetRequestId(requestId string)
 	GetStringToSign() string
 }
 
+// ISignableRequest is implemented by the requests which should be signed with the SecretKey,
+// the resource is signed together with the timestamp.
+type ISignableRequest interface {
+	IRequest
+	GetSignResource() string
+}
+
 type IConfigRequest interface {
 	GetDataId() string
 	GetGroup() string
@@ -59,6 +67,10 @@ func (r *Request) GetRequestId() string {
 	return r.RequestId
 }
 
+func (r *Request) SetRequestId(requestId string) {
+	r.RequestId = requestId
+}
+
 func (r *Request) GetStringToSign() string {
 	return ""
 }
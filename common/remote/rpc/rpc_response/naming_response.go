@@ -81,6 +81,16 @@ func (c *ServiceListResponse) GetResponseType() string {
 	return "ServiceListResponse"
 }
 
+// InstanceBeatResponse answers the beat with the interval of the next beat in milliseconds.
+type InstanceBeatResponse struct {
+	*Response
+	ClientBeatInterval int64 `json:"clientBeatInterval"`
+}
+
+func (c *InstanceBeatResponse) GetResponseType() string {
+	return "InstanceBeatResponse"
+}
+
 type NotifySubscriberResponse struct {
 	*Response
 }
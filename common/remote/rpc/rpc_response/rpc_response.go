@@ -33,6 +33,7 @@ func init() {
 type IResponse interface {
 	GetResponseType() string
 	SetRequestId(requestId string)
+	GetRequestId() string
 	GetBody() string
 	GetErrorCode() int
 	IsSuccess() bool
@@ -52,6 +53,10 @@ func (r *Response) SetRequestId(requestId string) {
 	r.RequestId = requestId
 }
 
+func (r *Response) GetRequestId() string {
+	return r.RequestId
+}
+
 func (r *Response) GetBody() string {
 	return util.ToJsonString(r)
 }
@@ -102,11 +107,46 @@ func registerClientResponses() {
 		return &ServiceListResponse{Response: &Response{}}
 	})
 
+	// register InstanceBeatResponse.
+	registerClientResponse(func() IResponse {
+		return &InstanceBeatResponse{Response: &Response{}}
+	})
+
 	// register NotifySubscriberResponse.
 	registerClientResponse(func() IResponse {
 		return &NotifySubscriberResponse{Response: &Response{}}
 	})
 
+	// register ConfigChangeBatchListenResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigChangeBatchListenResponse{Response: &Response{}}
+	})
+
+	// register ConfigQueryResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigQueryResponse{Response: &Response{}}
+	})
+
+	// register ConfigPublishResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigPublishResponse{Response: &Response{}}
+	})
+
+	// register ConfigRemoveResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigRemoveResponse{Response: &Response{}}
+	})
+
+	// register ConfigChangeNotifyResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigChangeNotifyResponse{Response: &Response{}}
+	})
+
+	// register ConfigSearchResponse.
+	registerClientResponse(func() IResponse {
+		return &ConfigSearchResponse{Response: &Response{}}
+	})
+
 	// register HealthCheckResponse.
 	registerClientResponse(func() IResponse {
 		return &HealthCheckResponse{Response: &Response{}}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/tracing"
+)
+
+func useInMemoryExporter() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter
+}
+
+func TestRequestAttemptSpan(t *testing.T) {
+	exporter := useInMemoryExporter()
+	defer tracing.SetTracerProvider(nil)
+
+	rpcClient := newRunningRpcClient(&mockEchoConnection{})
+	ctx, parent := tracing.StartSpan(context.Background(), "parent", trace.SpanKindInternal)
+	request := rpc_request.NewHealthCheckRequest()
+	_, err := rpcClient.RequestWithContext(ctx, request, 1000)
+	parent.End()
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	attempt := spans[0]
+	assert.Equal(t, "xgrpc.attempt HealthCheckRequest", attempt.Name)
+	assert.Equal(t, trace.SpanKindClient, attempt.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), attempt.Parent.SpanID())
+	assert.Contains(t, attempt.Attributes, tracing.RequestIdKey.String(request.GetRequestId()))
+
+	// the trace context of the attempt is propagated to the server by the headers.
+	propagated := trace.SpanContextFromContext(tracing.Extract(context.Background(), request.GetHeaders()))
+	assert.Equal(t, attempt.SpanContext.SpanID(), propagated.SpanID())
+}
+
+func TestHandleServerRequestSpan(t *testing.T) {
+	exporter := useInMemoryExporter()
+	defer tracing.SetTracerProvider(nil)
+
+	grpcClient := NewGrpcClient("test", nil)
+	grpcConn, biStreamClient := newMockGrpcConnection()
+	ctx, parent := tracing.StartSpan(context.Background(), "server", trace.SpanKindClient)
+	request := &rpc_request.ConnectResetRequest{InternalRequest: rpc_request.NewInternalRequest()}
+	tracing.Inject(ctx, request.GetHeaders())
+	parent.End()
+
+	grpcClient.handleServerRequest(convertRequest(request), grpcConn)
+	select {
+	case <-biStreamClient.sent:
+	default:
+	}
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	push := spans[1]
+	assert.Equal(t, "xgrpc.push ConnectResetRequest", push.Name)
+	assert.Equal(t, trace.SpanKindServer, push.SpanKind)
+	assert.Equal(t, parent.SpanContext().TraceID(), push.SpanContext.TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), push.Parent.SpanID())
+}
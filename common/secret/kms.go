@@ -0,0 +1,68 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secret
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KmsClient gets the secret stored in the key management service.
+type KmsClient interface {
+	GetSecret(regionId string, secretId string) (string, error)
+}
+
+// KmsSource resolves ${kms:secretId} by the KmsClient.
+type KmsSource struct {
+	client   KmsClient
+	regionId string
+}
+
+func NewKmsSource(client KmsClient, regionId string) *KmsSource {
+	return &KmsSource{client: client, regionId: regionId}
+}
+
+func (s *KmsSource) GetSecret(ref string) (string, error) {
+	return s.client.GetSecret(s.regionId, ref)
+}
+
+// FakeKms is a local KmsClient keeping the secrets in memory, it's used for testing.
+type FakeKms struct {
+	mux     sync.RWMutex
+	secrets map[string]string
+}
+
+func NewFakeKms() *FakeKms {
+	return &FakeKms{secrets: make(map[string]string)}
+}
+
+// PutSecret put or rotate the secret of the region.
+func (k *FakeKms) PutSecret(regionId string, secretId string, secret string) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.secrets[regionId+"/"+secretId] = secret
+}
+
+func (k *FakeKms) GetSecret(regionId string, secretId string) (string, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	secret, ok := k.secrets[regionId+"/"+secretId]
+	if !ok {
+		return "", errors.Errorf("secret %s not found in region %s", secretId, regionId)
+	}
+	return secret, nil
+}
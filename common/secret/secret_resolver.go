@@ -0,0 +1,147 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+const (
+	SCHEME_ENV  = "env"
+	SCHEME_FILE = "file"
+	SCHEME_KMS  = "kms"
+)
+
+// referencePattern matches the secret reference like ${env:XGRPC_SECRET_KEY}.
+var referencePattern = regexp.MustCompile(`^\$\{([a-zA-Z0-9_-]+):(.+)\}$`)
+
+// SecretSource returns the secret of the reference, the reference is the part after the scheme.
+type SecretSource interface {
+	GetSecret(ref string) (string, error)
+}
+
+// SecretSourceFunc is an adapter to use a func as SecretSource.
+type SecretSourceFunc func(ref string) (string, error)
+
+func (f SecretSourceFunc) GetSecret(ref string) (string, error) {
+	return f(ref)
+}
+
+// SecretResolver resolves the secret references by the source registered for the scheme,
+// the value which is not a reference is returned as is.
+type SecretResolver struct {
+	mux     sync.RWMutex
+	sources map[string]SecretSource
+}
+
+// NewSecretResolver create a resolver with the env and file sources.
+func NewSecretResolver() *SecretResolver {
+	return &SecretResolver{sources: map[string]SecretSource{
+		SCHEME_ENV:  SecretSourceFunc(getEnvSecret),
+		SCHEME_FILE: SecretSourceFunc(getFileSecret),
+	}}
+}
+
+func (r *SecretResolver) RegisterSource(scheme string, source SecretSource) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.sources[scheme] = source
+}
+
+func (r *SecretResolver) Resolve(value string) (string, error) {
+	scheme, ref, ok := parseReference(value)
+	if !ok {
+		return value, nil
+	}
+	r.mux.RLock()
+	source, ok := r.sources[scheme]
+	r.mux.RUnlock()
+	if !ok {
+		return "", errors.Errorf("no secret source registered for scheme:%s", scheme)
+	}
+	secret, err := source.GetSecret(ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "resolve secret of scheme:%s failed", scheme)
+	}
+	return secret, nil
+}
+
+// ResolveClientConfig returns a copy of the config with AccessKey, SecretKey and Password resolved,
+// the kms references are only resolved when OpenKMS is true.
+func (r *SecretResolver) ResolveClientConfig(clientCfg constant.ClientConfig) (constant.ClientConfig, error) {
+	fields := []*string{&clientCfg.AccessKey, &clientCfg.SecretKey, &clientCfg.Password}
+	names := []string{"AccessKey", "SecretKey", "Password"}
+	for i, field := range fields {
+		if scheme, _, ok := parseReference(*field); ok && scheme == SCHEME_KMS && !clientCfg.OpenKMS {
+			return clientCfg, errors.Errorf("%s refers to kms, but OpenKMS is false", names[i])
+		}
+		resolved, err := r.Resolve(*field)
+		if err != nil {
+			return clientCfg, errors.Wrapf(err, "resolve %s failed", names[i])
+		}
+		*field = resolved
+	}
+	return clientCfg, nil
+}
+
+var defaultResolver = NewSecretResolver()
+
+// RegisterSource register the source of the scheme to the default resolver.
+func RegisterSource(scheme string, source SecretSource) {
+	defaultResolver.RegisterSource(scheme, source)
+}
+
+// Resolve resolve the value by the default resolver.
+func Resolve(value string) (string, error) {
+	return defaultResolver.Resolve(value)
+}
+
+// ResolveClientConfig resolve the secrets of the config by the default resolver.
+func ResolveClientConfig(clientCfg constant.ClientConfig) (constant.ClientConfig, error) {
+	return defaultResolver.ResolveClientConfig(clientCfg)
+}
+
+func parseReference(value string) (scheme string, ref string, ok bool) {
+	matches := referencePattern.FindStringSubmatch(value)
+	if matches == nil {
+		return "", "", false
+	}
+	return matches[1], matches[2], true
+}
+
+func getEnvSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.Errorf("env %s is not set", name)
+	}
+	return value, nil
+}
+
+func getFileSecret(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
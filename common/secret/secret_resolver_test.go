@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+func TestResolve(t *testing.T) {
+	resolver := NewSecretResolver()
+
+	value, err := resolver.Resolve("plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", value)
+
+	assert.Nil(t, os.Setenv("XGRPC_TEST_SECRET", "env-secret"))
+	defer os.Unsetenv("XGRPC_TEST_SECRET")
+	value, err = resolver.Resolve("${env:XGRPC_TEST_SECRET}")
+	assert.Nil(t, err)
+	assert.Equal(t, "env-secret", value)
+
+	_, err = resolver.Resolve("${env:XGRPC_TEST_SECRET_NOT_EXIST}")
+	assert.NotNil(t, err)
+
+	dir, err := ioutil.TempDir("", "secret")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secret")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("file-secret\n"), 0600))
+	value, err = resolver.Resolve("${file:" + path + "}")
+	assert.Nil(t, err)
+	assert.Equal(t, "file-secret", value)
+
+	_, err = resolver.Resolve("${unknown:ref}")
+	assert.NotNil(t, err)
+}
+
+func TestResolveClientConfig(t *testing.T) {
+	kms := NewFakeKms()
+	kms.PutSecret("cn-hangzhou", "sk", "kms-secret")
+	resolver := NewSecretResolver()
+	resolver.RegisterSource(SCHEME_KMS, NewKmsSource(kms, "cn-hangzhou"))
+
+	clientCfg := constant.ClientConfig{AccessKey: "ak", SecretKey: "${kms:sk}", Password: "password"}
+	_, err := resolver.ResolveClientConfig(clientCfg)
+	assert.NotNil(t, err)
+
+	clientCfg.OpenKMS = true
+	resolved, err := resolver.ResolveClientConfig(clientCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "ak", resolved.AccessKey)
+	assert.Equal(t, "kms-secret", resolved.SecretKey)
+	assert.Equal(t, "password", resolved.Password)
+	// the raw config is kept for refreshing.
+	assert.Equal(t, "${kms:sk}", clientCfg.SecretKey)
+
+	kms.PutSecret("cn-hangzhou", "sk", "rotated-secret")
+	resolved, err = resolver.ResolveClientConfig(clientCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "rotated-secret", resolved.SecretKey)
+
+	clientCfg.SecretKey = "${kms:not-exist}"
+	_, err = resolver.ResolveClientConfig(clientCfg)
+	assert.NotNil(t, err)
+}
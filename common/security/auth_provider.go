@@ -0,0 +1,97 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+)
+
+// AuthProvider provides the security info injected into the http params and grpc headers of the requests.
+type AuthProvider interface {
+	// Login obtains the credential, it's called once when the client is created.
+	Login() (bool, error)
+	// AutoRefresh keeps the credential fresh in background.
+	AutoRefresh()
+	// GetSecurityInfo returns the key-values injected into the requests.
+	GetSecurityInfo() map[string]string
+}
+
+// RefreshableAuthProvider is implemented by the AuthProvider which can renew the credential rejected by the server.
+type RefreshableAuthProvider interface {
+	AuthProvider
+	// Refresh renews the credential if the rejected token is still in use, the concurrent calls share one renewal.
+	Refresh(rejectedToken string) error
+}
+
+// AuthProviderFactory creates the AuthProvider of a client.
+type AuthProviderFactory func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider
+
+var (
+	factoryMux sync.RWMutex
+	factories  = map[string]AuthProviderFactory{
+		constant.AUTH_TYPE_LOGIN: func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider {
+			return NewAuthClient(clientCfg, serverCfgs, agent)
+		},
+		constant.AUTH_TYPE_STATIC: func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider {
+			return NewStaticTokenProvider(clientCfg.AuthCfg.AccessToken)
+		},
+		constant.AUTH_TYPE_FILE: func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider {
+			return NewFileTokenProvider(clientCfg.AuthCfg.TokenFile, clientCfg.AuthCfg.TokenFileInterval)
+		},
+		constant.AUTH_TYPE_OAUTH2: func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider {
+			return NewOAuth2Provider(clientCfg.AuthCfg.OAuth2, agent, clientCfg.TimeoutMs)
+		},
+	}
+)
+
+// RegisterAuthProvider register a custom AuthProvider, which is used when AuthConfig.Type is authType.
+func RegisterAuthProvider(authType string, factory AuthProviderFactory) {
+	factoryMux.Lock()
+	defer factoryMux.Unlock()
+	factories[authType] = factory
+}
+
+// NewAuthProvider create the AuthProvider configured by ClientConfig.AuthCfg.
+func NewAuthProvider(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) (AuthProvider, error) {
+	authType := getAuthType(clientCfg.AuthCfg)
+	factoryMux.RLock()
+	factory, ok := factories[authType]
+	factoryMux.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown auth type:%s", authType)
+	}
+	return factory(clientCfg, serverCfgs, agent), nil
+}
+
+func getAuthType(authCfg constant.AuthConfig) string {
+	switch {
+	case authCfg.Type != "":
+		return authCfg.Type
+	case authCfg.AccessToken != "":
+		return constant.AUTH_TYPE_STATIC
+	case authCfg.TokenFile != "":
+		return constant.AUTH_TYPE_FILE
+	case authCfg.OAuth2.TokenUrl != "":
+		return constant.AUTH_TYPE_OAUTH2
+	default:
+		return constant.AUTH_TYPE_LOGIN
+	}
+}
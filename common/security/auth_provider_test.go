@@ -0,0 +1,116 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+)
+
+type customAuthProvider struct {
+	StaticTokenProvider
+}
+
+func TestNewAuthProvider(t *testing.T) {
+	provider, err := NewAuthProvider(constant.ClientConfig{}, nil, nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &AuthClient{}, provider)
+
+	provider, err = NewAuthProvider(constant.ClientConfig{AuthCfg: constant.AuthConfig{AccessToken: "token"}}, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{constant.KEY_ACCESS_TOKEN: "token"}, provider.GetSecurityInfo())
+
+	_, err = NewAuthProvider(constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "unknown"}}, nil, nil)
+	assert.NotNil(t, err)
+
+	RegisterAuthProvider("custom", func(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthProvider {
+		return &customAuthProvider{}
+	})
+	provider, err = NewAuthProvider(constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "custom"}}, nil, nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &customAuthProvider{}, provider)
+}
+
+func TestFileTokenProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+
+	provider := NewFileTokenProvider(path, 10)
+	_, err = provider.Login()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("token1\n"), 0600))
+	ok, err := provider.Login()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "token1", provider.GetSecurityInfo()[constant.KEY_ACCESS_TOKEN])
+
+	provider.AutoRefresh()
+	assert.Nil(t, ioutil.WriteFile(path, []byte("token-two"), 0600))
+	assert.Eventually(t, func() bool {
+		return provider.GetSecurityInfo()[constant.KEY_ACCESS_TOKEN] == "token-two"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOAuth2Provider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+		_, _ = w.Write([]byte(`{"access_token":"oauth2-token","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	cfg := constant.OAuth2Config{TokenUrl: server.URL, ClientId: "client", ClientSecret: "secret", Scopes: []string{"read", "write"}}
+	provider := NewOAuth2Provider(cfg, &http_agent.HttpAgent{}, 3000)
+	ok, err := provider.Login()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "oauth2-token", provider.GetSecurityInfo()[constant.KEY_ACCESS_TOKEN])
+	assert.Equal(t, 3240*time.Second, provider.nextRefreshInterval())
+
+	cfg.ClientSecret = "wrong"
+	_, err = NewOAuth2Provider(cfg, &http_agent.HttpAgent{}, 3000).Login()
+	assert.NotNil(t, err)
+}
+
+func TestPerRPCCredentials(t *testing.T) {
+	creds := NewPerRPCCredentials(NewStaticTokenProvider("token"), func() string {
+		return "ak"
+	}, false)
+	metadata, err := creds.GetRequestMetadata(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{constant.KEY_ACCESS_TOKEN: "token", "Spas-AccessKey": "ak"}, metadata)
+	assert.False(t, creds.RequireTransportSecurity())
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+)
+
+const defaultTokenFileInterval = 1000
+
+// FileTokenProvider reads the token from a file, such as a projected service account token,
+// and re-reads it when the file is changed.
+type FileTokenProvider struct {
+	path         string
+	interval     time.Duration
+	accessToken  atomic.Value
+	mux          sync.Mutex
+	modTime      time.Time
+	size         int64
+	refreshOnce  sync.Once
+	refreshGroup singleFlight
+}
+
+// NewFileTokenProvider create the provider of the token in path, the file is checked every intervalMs milliseconds.
+func NewFileTokenProvider(path string, intervalMs uint64) *FileTokenProvider {
+	if intervalMs == 0 {
+		intervalMs = defaultTokenFileInterval
+	}
+	return &FileTokenProvider{path: path, interval: time.Duration(intervalMs) * time.Millisecond}
+}
+
+func (p *FileTokenProvider) Login() (bool, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, err
+	}
+	if err = p.load(info); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *FileTokenProvider) AutoRefresh() {
+	p.refreshOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(p.interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				p.reloadIfChanged()
+			}
+		}()
+	})
+}
+
+// Refresh re-read the token file, the token may be rotated before the file is checked.
+func (p *FileTokenProvider) Refresh(rejectedToken string) error {
+	return p.refreshGroup.Do(func() error {
+		if rejectedToken != "" && rejectedToken != p.getAccessToken() {
+			return nil
+		}
+		_, err := p.Login()
+		return err
+	})
+}
+
+func (p *FileTokenProvider) GetSecurityInfo() map[string]string {
+	return tokenSecurityInfo(p.getAccessToken())
+}
+
+func (p *FileTokenProvider) getAccessToken() string {
+	if v, ok := p.accessToken.Load().(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (p *FileTokenProvider) reloadIfChanged() {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		logger.Warnf("stat token file %s failed, keep the current token, error=%+v", p.path, err)
+		return
+	}
+	p.mux.Lock()
+	unchanged := info.ModTime().Equal(p.modTime) && info.Size() == p.size
+	p.mux.Unlock()
+	if unchanged {
+		return
+	}
+	if err = p.load(info); err != nil {
+		logger.Warnf("reload token file %s failed, keep the current token, error=%+v", p.path, err)
+		return
+	}
+	logger.Infof("token file %s is changed, token reloaded", p.path)
+}
+
+func (p *FileTokenProvider) load(info os.FileInfo) error {
+	content, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return errors.Errorf("token file %s is empty", p.path)
+	}
+	p.accessToken.Store(token)
+	p.mux.Lock()
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.mux.Unlock()
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/logger"
+)
+
+const (
+	oauth2RetryInterval   = 5 * time.Second
+	oauth2RefreshInterval = 30 * time.Minute
+)
+
+// OAuth2Provider obtains the token by the OAuth2 client credentials grant and refreshes it before expiration.
+type OAuth2Provider struct {
+	cfg          constant.OAuth2Config
+	agent        http_agent.IHttpAgent
+	timeoutMs    uint64
+	accessToken  atomic.Value
+	expiresIn    int64
+	refreshOnce  sync.Once
+	refreshGroup singleFlight
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewOAuth2Provider(cfg constant.OAuth2Config, agent http_agent.IHttpAgent, timeoutMs uint64) *OAuth2Provider {
+	return &OAuth2Provider{cfg: cfg, agent: agent, timeoutMs: timeoutMs}
+}
+
+func (p *OAuth2Provider) Login() (bool, error) {
+	header := http.Header{
+		"Content-Type":  []string{"application/x-www-form-urlencoded"},
+		"Authorization": []string{"Basic " + basicAuth(p.cfg.ClientId, p.cfg.ClientSecret)},
+	}
+	params := map[string]string{"grant_type": "client_credentials"}
+	if len(p.cfg.Scopes) > 0 {
+		params["scope"] = strings.Join(p.cfg.Scopes, " ")
+	}
+	resp, err := p.agent.Post(p.cfg.TokenUrl, header, p.timeoutMs, params)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != constant.RESPONSE_CODE_SUCCESS {
+		return false, errors.Errorf("oauth2 token request failed, status:%d, body:%s", resp.StatusCode, string(bytes))
+	}
+	var token oauth2TokenResponse
+	if err = json.Unmarshal(bytes, &token); err != nil {
+		return false, err
+	}
+	if token.AccessToken == "" {
+		return false, errors.New("oauth2 token response without access_token")
+	}
+	p.accessToken.Store(token.AccessToken)
+	atomic.StoreInt64(&p.expiresIn, token.ExpiresIn)
+	return true, nil
+}
+
+func (p *OAuth2Provider) AutoRefresh() {
+	p.refreshOnce.Do(func() {
+		go func() {
+			timer := time.NewTimer(p.nextRefreshInterval())
+			for range timer.C {
+				if _, err := p.Login(); err != nil {
+					logger.Errorf("refresh oauth2 token has error %+v", err)
+					timer.Reset(oauth2RetryInterval)
+					continue
+				}
+				timer.Reset(p.nextRefreshInterval())
+			}
+		}()
+	})
+}
+
+func (p *OAuth2Provider) Refresh(rejectedToken string) error {
+	return p.refreshGroup.Do(func() error {
+		if rejectedToken != "" && rejectedToken != p.getAccessToken() {
+			return nil
+		}
+		_, err := p.Login()
+		return err
+	})
+}
+
+func (p *OAuth2Provider) GetSecurityInfo() map[string]string {
+	return tokenSecurityInfo(p.getAccessToken())
+}
+
+func (p *OAuth2Provider) getAccessToken() string {
+	if v, ok := p.accessToken.Load().(string); ok {
+		return v
+	}
+	return ""
+}
+
+// nextRefreshInterval refresh the token when 90% of the lifetime passed,
+// the token without expires_in is refreshed every 30 minutes.
+func (p *OAuth2Provider) nextRefreshInterval() time.Duration {
+	if _, ok := p.accessToken.Load().(string); !ok {
+		return oauth2RetryInterval
+	}
+	expiresIn := atomic.LoadInt64(&p.expiresIn)
+	if expiresIn <= 0 {
+		return oauth2RefreshInterval
+	}
+	return time.Duration(expiresIn-expiresIn/10) * time.Second
+}
+
+func basicAuth(clientId, clientSecret string) string {
+	return base64.StdEncoding.EncodeToString([]byte(url.QueryEscape(clientId) + ":" + url.QueryEscape(clientSecret)))
+}
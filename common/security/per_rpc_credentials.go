@@ -0,0 +1,56 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+const accessKeyMetadata = "Spas-AccessKey"
+
+// PerRPCCredentials attaches the security info of the AuthProvider to the metadata of every grpc call,
+// the info is read on every call so the refreshed token is used by the new calls at once.
+type PerRPCCredentials struct {
+	provider   AuthProvider
+	accessKey  func() string
+	requireTLS bool
+}
+
+// NewPerRPCCredentials create the credentials, the access key is got on every call so the rotated one is used.
+func NewPerRPCCredentials(provider AuthProvider, accessKey func() string, requireTLS bool) credentials.PerRPCCredentials {
+	return &PerRPCCredentials{provider: provider, accessKey: accessKey, requireTLS: requireTLS}
+}
+
+func (c *PerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if c.provider != nil {
+		for k, v := range c.provider.GetSecurityInfo() {
+			metadata[k] = v
+		}
+	}
+	if c.accessKey != nil {
+		if accessKey := c.accessKey(); accessKey != "" {
+			metadata[accessKeyMetadata] = accessKey
+		}
+	}
+	return metadata, nil
+}
+
+func (c *PerRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
@@ -19,9 +19,11 @@ package security
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -30,28 +32,45 @@ import (
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
 	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/secret"
+)
+
+const (
+	minLoginBackoff = time.Second
+	maxLoginBackoff = time.Minute
 )
 
 type AuthClient struct {
-	username           string
-	password           string
-	accessToken        *atomic.Value
-	tokenTtl           int64
-	lastRefreshTime    int64
-	tokenRefreshWindow int64
-	agent              http_agent.IHttpAgent
-	clientCfg          constant.ClientConfig
-	serverCfgs         []constant.ServerConfig
+	username     string
+	password     string
+	accessToken  *atomic.Value
+	tokenTtl     int64
+	agent        http_agent.IHttpAgent
+	clientCfg    constant.ClientConfig
+	serverCfgs   []constant.ServerConfig
+	stateMux     sync.Mutex
+	serverStates map[string]*serverLoginState
+	failures     int
+	refreshGroup singleFlight
+	refreshOnce  sync.Once
 }
 
-func NewAuthClient(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) AuthClient {
-	client := AuthClient{
-		username:    clientCfg.Username,
-		password:    clientCfg.Password,
-		serverCfgs:  serverCfgs,
-		clientCfg:   clientCfg,
-		agent:       agent,
-		accessToken: &atomic.Value{},
+// serverLoginState is the login backoff state of one server, the server failed to login is skipped until
+// nextLoginTime. The token got from any server is shared by all servers.
+type serverLoginState struct {
+	failures      int
+	nextLoginTime time.Time
+}
+
+func NewAuthClient(clientCfg constant.ClientConfig, serverCfgs []constant.ServerConfig, agent http_agent.IHttpAgent) *AuthClient {
+	client := &AuthClient{
+		username:     clientCfg.Username,
+		password:     clientCfg.Password,
+		serverCfgs:   serverCfgs,
+		clientCfg:    clientCfg,
+		agent:        agent,
+		accessToken:  &atomic.Value{},
+		serverStates: make(map[string]*serverLoginState),
 	}
 
 	return client
@@ -65,6 +84,10 @@ func (ac *AuthClient) GetAccessToken() string {
 	return v.(string)
 }
 
+func (ac *AuthClient) GetSecurityInfo() map[string]string {
+	return tokenSecurityInfo(ac.GetAccessToken())
+}
+
 func (ac *AuthClient) AutoRefresh() {
 
 	// If the username is not set, the automatic refresh Token is not enabled
@@ -73,34 +96,119 @@ func (ac *AuthClient) AutoRefresh() {
 		return
 	}
 
-	go func() {
-		timer := time.NewTimer(time.Second * time.Duration(ac.tokenTtl-ac.tokenRefreshWindow))
-
-		for {
-			select {
-			case <-timer.C:
+	ac.refreshOnce.Do(func() {
+		go func() {
+			timer := time.NewTimer(ac.nextRefreshInterval())
+			for range timer.C {
 				_, err := ac.Login()
 				if err != nil {
 					logger.Errorf("login has error %+v", err)
 				}
-				timer.Reset(time.Second * time.Duration(ac.tokenTtl-ac.tokenRefreshWindow))
+				timer.Reset(ac.nextRefreshInterval())
 			}
+		}()
+	})
+}
+
+// Refresh login again if the rejected token is still in use, the concurrent refreshes share one login.
+func (ac *AuthClient) Refresh(rejectedToken string) error {
+	if ac.username == "" {
+		return nil
+	}
+	return ac.refreshGroup.Do(func() error {
+		if rejectedToken != "" && rejectedToken != ac.GetAccessToken() {
+			// the token is already refreshed by the others.
+			return nil
 		}
-	}()
+		_, err := ac.Login()
+		return err
+	})
+}
+
+// nextRefreshInterval refresh the token when the refresh window is reached,
+// or retry with backoff if there's no valid token.
+func (ac *AuthClient) nextRefreshInterval() time.Duration {
+	ac.stateMux.Lock()
+	defer ac.stateMux.Unlock()
+	if ac.failures > 0 || ac.tokenTtl <= 0 {
+		return loginBackoff(ac.failures)
+	}
+	return time.Duration(ac.tokenTtl-ac.tokenTtl/10) * time.Second
 }
 
 func (ac *AuthClient) Login() (bool, error) {
+	if ac.username == "" {
+		return true, nil
+	}
 	var throwable error = nil
+	now := time.Now()
+	tried := 0
 	for i := 0; i < len(ac.serverCfgs); i++ {
-		result, err := ac.login(ac.serverCfgs[i])
+		server := ac.serverCfgs[i]
+		state := ac.getServerState(server)
+		if now.Before(state.nextLoginTime) {
+			continue
+		}
+		tried++
+		result, err := ac.login(server)
+		ac.updateServerState(server, result, err)
 		throwable = err
 		if result {
 			return true, nil
 		}
 	}
+	if tried == 0 && len(ac.serverCfgs) > 0 {
+		throwable = errors.New("all servers are in login backoff")
+	}
+	ac.stateMux.Lock()
+	ac.failures++
+	ac.stateMux.Unlock()
 	return false, throwable
 }
 
+func (ac *AuthClient) getServerState(server constant.ServerConfig) serverLoginState {
+	ac.stateMux.Lock()
+	defer ac.stateMux.Unlock()
+	if state, ok := ac.serverStates[serverKey(server)]; ok {
+		return *state
+	}
+	return serverLoginState{}
+}
+
+func (ac *AuthClient) updateServerState(server constant.ServerConfig, result bool, err error) {
+	ac.stateMux.Lock()
+	defer ac.stateMux.Unlock()
+	key := serverKey(server)
+	state, ok := ac.serverStates[key]
+	if !ok {
+		state = &serverLoginState{}
+		ac.serverStates[key] = state
+	}
+	if result {
+		state.failures = 0
+		state.nextLoginTime = time.Time{}
+		ac.failures = 0
+		return
+	}
+	state.failures++
+	state.nextLoginTime = time.Now().Add(loginBackoff(state.failures))
+	logger.Warnf("login to server %s failed %d times, retry after %s, error=%+v", key, state.failures,
+		state.nextLoginTime.Format(time.RFC3339), err)
+}
+
+// loginBackoff doubles the interval on every failure, from 1s to 1min.
+func loginBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return minLoginBackoff
+	}
+	backoff := float64(minLoginBackoff) * math.Pow(2, float64(failures-1))
+	return time.Duration(math.Min(backoff, float64(maxLoginBackoff)))
+}
+
+func serverKey(server constant.ServerConfig) string {
+	return server.IpAddr + ":" + strconv.FormatUint(server.Port, 10)
+}
+
 func (ac *AuthClient) login(server constant.ServerConfig) (bool, error) {
 	if ac.username != "" {
 		contextPath := server.ContextPath
@@ -122,9 +230,14 @@ func (ac *AuthClient) login(server constant.ServerConfig) (bool, error) {
 		header := http.Header{
 			"content-type": []string{"application/x-www-form-urlencoded"},
 		}
+		// the password is resolved on every login, so the rotated one is used.
+		password, err := secret.Resolve(ac.password)
+		if err != nil {
+			return false, err
+		}
 		resp, err := ac.agent.Post(reqUrl, header, ac.clientCfg.TimeoutMs, map[string]string{
 			"username": ac.username,
-			"password": ac.password,
+			"password": password,
 		})
 
 		if err != nil {
@@ -151,10 +264,12 @@ func (ac *AuthClient) login(server constant.ServerConfig) (bool, error) {
 			return false, err
 		}
 
-		if val, ok := result[constant.KEY_ACCESS_TOKEN]; ok {
+		if val, ok := result[constant.KEY_ACCESS_TOKEN].(string); ok {
 			ac.accessToken.Store(val)
-			ac.tokenTtl = int64(result[constant.KEY_TOKEN_TTL].(float64))
-			ac.tokenRefreshWindow = ac.tokenTtl / 10
+			ttl, _ := result[constant.KEY_TOKEN_TTL].(float64)
+			ac.stateMux.Lock()
+			ac.tokenTtl = int64(ttl)
+			ac.stateMux.Unlock()
 		}
 	}
 	return true, nil
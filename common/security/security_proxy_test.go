@@ -0,0 +1,120 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+)
+
+func newLoginServer(loginTimes *int32, status int) (*httptest.Server, constant.ServerConfig) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times := atomic.AddInt32(loginTimes, 1)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"accessToken":"token-` + strconv.Itoa(int(times)) + `","tokenTtl":18000}`))
+	}))
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	return server, constant.ServerConfig{IpAddr: addr.IP.String(), Port: uint64(addr.Port)}
+}
+
+func TestAuthClientRefresh(t *testing.T) {
+	var loginTimes int32
+	server, serverCfg := newLoginServer(&loginTimes, http.StatusOK)
+	defer server.Close()
+
+	client := NewAuthClient(constant.ClientConfig{Username: "xgrpc", Password: "xgrpc", TimeoutMs: 3000},
+		[]constant.ServerConfig{serverCfg}, &http_agent.HttpAgent{})
+	ok, err := client.Login()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-1", client.GetAccessToken())
+	assert.Equal(t, 16200*time.Second, client.nextRefreshInterval())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, client.Refresh("token-1"))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loginTimes))
+	assert.Equal(t, "token-2", client.GetAccessToken())
+
+	// the rejected token is already refreshed.
+	assert.Nil(t, client.Refresh("token-1"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loginTimes))
+}
+
+func TestAuthClientLoginBackoff(t *testing.T) {
+	var loginTimes int32
+	server, serverCfg := newLoginServer(&loginTimes, http.StatusInternalServerError)
+	defer server.Close()
+
+	client := NewAuthClient(constant.ClientConfig{Username: "xgrpc", Password: "xgrpc", TimeoutMs: 3000},
+		[]constant.ServerConfig{serverCfg}, &http_agent.HttpAgent{})
+	ok, err := client.Login()
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	// the failed server is skipped in backoff.
+	ok, err = client.Login()
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loginTimes))
+	// retry with backoff instead of spinning when there's no token.
+	assert.Equal(t, 2*time.Second, client.nextRefreshInterval())
+}
+
+func TestLoginBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, loginBackoff(0))
+	assert.Equal(t, time.Second, loginBackoff(1))
+	assert.Equal(t, 4*time.Second, loginBackoff(3))
+	assert.Equal(t, time.Minute, loginBackoff(10))
+}
+
+func TestSingleFlight(t *testing.T) {
+	var group singleFlight
+	var calls int32
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_ = group.Do(func() error {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+	assert.True(t, atomic.LoadInt32(&calls) < 5)
+}
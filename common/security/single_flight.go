@@ -0,0 +1,48 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import "sync"
+
+// singleFlight makes the concurrent calls share the result of one execution.
+type singleFlight struct {
+	mux  sync.Mutex
+	call *flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (g *singleFlight) Do(fn func() error) error {
+	g.mux.Lock()
+	if call := g.call; call != nil {
+		g.mux.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.call = call
+	g.mux.Unlock()
+
+	call.err = fn()
+	g.mux.Lock()
+	g.call = nil
+	g.mux.Unlock()
+	close(call.done)
+	return call.err
+}
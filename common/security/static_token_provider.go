@@ -0,0 +1,47 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package security
+
+import (
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+// StaticTokenProvider injects a fixed bearer token, the token is never refreshed.
+type StaticTokenProvider struct {
+	accessToken string
+}
+
+func NewStaticTokenProvider(accessToken string) *StaticTokenProvider {
+	return &StaticTokenProvider{accessToken: accessToken}
+}
+
+func (p *StaticTokenProvider) Login() (bool, error) {
+	return p.accessToken != "", nil
+}
+
+func (p *StaticTokenProvider) AutoRefresh() {
+}
+
+func (p *StaticTokenProvider) GetSecurityInfo() map[string]string {
+	return tokenSecurityInfo(p.accessToken)
+}
+
+func tokenSecurityInfo(accessToken string) map[string]string {
+	if accessToken == "" {
+		return map[string]string{}
+	}
+	return map[string]string{constant.KEY_ACCESS_TOKEN: accessToken}
+}
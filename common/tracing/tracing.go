@@ -0,0 +1,140 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_response"
+)
+
+const instrumentationName = "github.com/allenliu88/xgrpc-client-go"
+
+const (
+	RpcSystemKey      = attribute.Key("rpc.system")
+	ClientNameKey     = attribute.Key("xgrpc.client.name")
+	RequestTypeKey    = attribute.Key("xgrpc.request.type")
+	RequestIdKey      = attribute.Key("xgrpc.request.id")
+	RetryTimesKey     = attribute.Key("xgrpc.retry.times")
+	ConnectionIdKey   = attribute.Key("xgrpc.connection.id")
+	ServerAddressKey  = attribute.Key("xgrpc.server.address")
+	ResponseCodeKey   = attribute.Key("xgrpc.response.code")
+	OnRequestFailKey  = attribute.Key("xgrpc.reconnect.on_request_fail")
+	ReconnectTimesKey = attribute.Key("xgrpc.reconnect.times")
+)
+
+var (
+	tracerProvider atomic.Value
+	propagator     atomic.Value
+)
+
+type tracerProviderHolder struct {
+	trace.TracerProvider
+}
+
+type propagatorHolder struct {
+	propagation.TextMapPropagator
+}
+
+// SetTracerProvider set the provider of the spans created by xgrpc client,
+// the global provider of otel is used if it's not set.
+func SetTracerProvider(provider trace.TracerProvider) {
+	tracerProvider.Store(tracerProviderHolder{provider})
+}
+
+// SetPropagator set the propagator used to inject and extract the trace context in metadata headers,
+// W3C traceparent and baggage are used if it's not set.
+func SetPropagator(textMapPropagator propagation.TextMapPropagator) {
+	propagator.Store(propagatorHolder{textMapPropagator})
+}
+
+func getTracer() trace.Tracer {
+	if holder, ok := tracerProvider.Load().(tracerProviderHolder); ok && holder.TracerProvider != nil {
+		return holder.Tracer(instrumentationName, trace.WithInstrumentationVersion(constant.CLIENT_VERSION))
+	}
+	return otel.GetTracerProvider().Tracer(instrumentationName, trace.WithInstrumentationVersion(constant.CLIENT_VERSION))
+}
+
+func getPropagator() propagation.TextMapPropagator {
+	if holder, ok := propagator.Load().(propagatorHolder); ok && holder.TextMapPropagator != nil {
+		return holder
+	}
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// StartSpan start a span of xgrpc client, the span must be ended by EndSpan.
+func StartSpan(ctx context.Context, spanName string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs = append(attrs, RpcSystemKey.String("xgrpc"))
+	return getTracer().Start(ctx, spanName, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// EndSpan record the error if any and end the span.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject write the trace context of ctx into the metadata headers.
+func Inject(ctx context.Context, headers map[string]string) {
+	if headers == nil {
+		return
+	}
+	getPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract read the trace context from the metadata headers.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if headers == nil {
+		return ctx
+	}
+	return getPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// RequestAttributes returns the span attributes describing the request.
+func RequestAttributes(clientName string, request rpc_request.IRequest) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		ClientNameKey.String(clientName),
+		RequestTypeKey.String(request.GetRequestType()),
+	}
+	if request.GetRequestId() != "" {
+		attrs = append(attrs, RequestIdKey.String(request.GetRequestId()))
+	}
+	return attrs
+}
+
+// ResponseAttributes returns the span attributes describing the response.
+func ResponseAttributes(response rpc_response.IResponse) []attribute.KeyValue {
+	return []attribute.KeyValue{ResponseCodeKey.String(rpc_response.GetGrpcResponseStatusCode(response))}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xgrpc_server
+
+import (
+	"sync"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+// ServerListChangeEvent describes a change of the server list, Version increases by one on every change.
+type ServerListChangeEvent struct {
+	Version uint64
+	Servers []constant.ServerConfig
+	Added   []constant.ServerConfig
+	Removed []constant.ServerConfig
+}
+
+// ServerListChangeListener is called synchronously by the goroutine updating the server list, so it shouldn't block.
+type ServerListChangeListener func(event ServerListChangeEvent)
+
+// ServerListStore holds the server list, it's safe for concurrent use.
+type ServerListStore struct {
+	mux            sync.RWMutex
+	servers        []constant.ServerConfig
+	version        uint64
+	notifyMux      sync.Mutex
+	listeners      map[uint64]ServerListChangeListener
+	nextListenerId uint64
+}
+
+func NewServerListStore(servers []constant.ServerConfig) *ServerListStore {
+	return &ServerListStore{
+		servers:   copyServers(servers),
+		listeners: map[uint64]ServerListChangeListener{},
+	}
+}
+
+// Get returns a copy of the current server list.
+func (s *ServerListStore) Get() []constant.ServerConfig {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return copyServers(s.servers)
+}
+
+// GetWithVersion returns a copy of the current server list and its version.
+func (s *ServerListStore) GetWithVersion() ([]constant.ServerConfig, uint64) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return copyServers(s.servers), s.version
+}
+
+func (s *ServerListStore) Version() uint64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.version
+}
+
+// Update replaces the server list and notifies the listeners in the order of versions if any server is added or removed,
+// the order of the servers alone isn't a change.
+func (s *ServerListStore) Update(servers []constant.ServerConfig) (ServerListChangeEvent, bool) {
+	s.notifyMux.Lock()
+	defer s.notifyMux.Unlock()
+
+	s.mux.Lock()
+	added, removed := diffServers(s.servers, servers)
+	if len(added) == 0 && len(removed) == 0 {
+		s.mux.Unlock()
+		return ServerListChangeEvent{}, false
+	}
+	s.servers = copyServers(servers)
+	s.version++
+	event := ServerListChangeEvent{Version: s.version, Servers: copyServers(servers), Added: added, Removed: removed}
+	listeners := make([]ServerListChangeListener, 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.mux.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return event, true
+}
+
+// Subscribe adds the listener of the following changes, the returned func removes it.
+func (s *ServerListStore) Subscribe(listener ServerListChangeListener) (unsubscribe func()) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.nextListenerId++
+	id := s.nextListenerId
+	s.listeners[id] = listener
+	return func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		delete(s.listeners, id)
+	}
+}
+
+func diffServers(oldServers, newServers []constant.ServerConfig) (added, removed []constant.ServerConfig) {
+	oldSet := make(map[constant.ServerConfig]struct{}, len(oldServers))
+	for _, srv := range oldServers {
+		oldSet[srv] = struct{}{}
+	}
+	newSet := make(map[constant.ServerConfig]struct{}, len(newServers))
+	for _, srv := range newServers {
+		newSet[srv] = struct{}{}
+		if _, ok := oldSet[srv]; !ok {
+			added = append(added, srv)
+		}
+	}
+	for _, srv := range oldServers {
+		if _, ok := newSet[srv]; !ok {
+			removed = append(removed, srv)
+		}
+	}
+	return
+}
+
+func copyServers(servers []constant.ServerConfig) []constant.ServerConfig {
+	if servers == nil {
+		return nil
+	}
+	result := make([]constant.ServerConfig, len(servers))
+	copy(result, servers)
+	return result
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xgrpc_server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/allenliu88/xgrpc-client-go/common/constant"
+)
+
+func TestServerListStoreUpdate(t *testing.T) {
+	srv1 := constant.ServerConfig{IpAddr: "127.0.0.1", Port: 8848}
+	srv2 := constant.ServerConfig{IpAddr: "127.0.0.2", Port: 8848}
+	srv3 := constant.ServerConfig{IpAddr: "127.0.0.3", Port: 8848}
+	store := NewServerListStore([]constant.ServerConfig{srv1, srv2})
+
+	var events []ServerListChangeEvent
+	unsubscribe := store.Subscribe(func(event ServerListChangeEvent) {
+		events = append(events, event)
+	})
+
+	_, changed := store.Update([]constant.ServerConfig{srv2, srv1})
+	assert.False(t, changed)
+	assert.Equal(t, uint64(0), store.Version())
+
+	event, changed := store.Update([]constant.ServerConfig{srv2, srv3})
+	assert.True(t, changed)
+	assert.Equal(t, uint64(1), event.Version)
+	assert.Equal(t, []constant.ServerConfig{srv3}, event.Added)
+	assert.Equal(t, []constant.ServerConfig{srv1}, event.Removed)
+	assert.Equal(t, []constant.ServerConfig{srv2, srv3}, store.Get())
+	assert.Equal(t, []ServerListChangeEvent{event}, events)
+
+	unsubscribe()
+	event, changed = store.Update([]constant.ServerConfig{srv1})
+	assert.True(t, changed)
+	assert.Equal(t, uint64(2), event.Version)
+	assert.Equal(t, 1, len(events))
+
+	// the returned list is a copy
+	servers, version := store.GetWithVersion()
+	servers[0].IpAddr = "127.0.0.4"
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, "127.0.0.1", store.Get()[0].IpAddr)
+}
+
+func TestServerListStoreConcurrentUpdate(t *testing.T) {
+	store := NewServerListStore(nil)
+	var mux sync.Mutex
+	var versions []uint64
+	store.Subscribe(func(event ServerListChangeEvent) {
+		mux.Lock()
+		defer mux.Unlock()
+		versions = append(versions, event.Version)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Update([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: uint64(8000 + i)}})
+			_ = store.Get()
+		}(i)
+	}
+	wg.Wait()
+
+	// the listeners see every version in order
+	assert.Equal(t, 50, len(versions))
+	for i, version := range versions {
+		assert.Equal(t, uint64(i+1), version)
+	}
+}
@@ -17,21 +17,22 @@
 package xgrpc_server
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
-	"reflect"
 	"strconv"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
 	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
 	"github.com/allenliu88/xgrpc-client-go/common/logger"
+	"github.com/allenliu88/xgrpc-client-go/common/secret"
 	"github.com/allenliu88/xgrpc-client-go/common/security"
 	"github.com/allenliu88/xgrpc-client-go/common/xgrpc_error"
 	"github.com/allenliu88/xgrpc-client-go/inner/uuid"
@@ -47,9 +49,11 @@ import (
 )
 
 type XgrpcServer struct {
-	sync.RWMutex
-	securityLogin         security.AuthClient
-	serverList            []constant.ServerConfig
+	authProvider          security.AuthProvider
+	perRPCCredentials     credentials.PerRPCCredentials
+	rawClientCfg          constant.ClientConfig
+	clientCfg             atomic.Value
+	serverListStore       *ServerListStore
 	httpAgent             http_agent.IHttpAgent
 	timeoutMs             uint64
 	endpoint              string
@@ -57,20 +61,24 @@ type XgrpcServer struct {
 	vipSrvRefInterMills   int64
 	contextPath           string
 	currentIndex          int32
-	ServerSrcChangeSignal chan struct{}
+	ServerSrcChangeSignal chan struct{} // Deprecated: use OnServerListChanged, the signal is dropped if the previous one isn't received
 }
 
 func NewXgrpcServer(serverList []constant.ServerConfig, clientCfg constant.ClientConfig, httpAgent http_agent.IHttpAgent, timeoutMs uint64, endpoint string) (*XgrpcServer, error) {
 	severLen := len(serverList)
 	if severLen == 0 && endpoint == "" {
-		return &XgrpcServer{}, errors.New("both serverlist  and  endpoint are empty")
+		return nil, errors.New("both serverlist  and  endpoint are empty")
 	}
 
-	securityLogin := security.NewAuthClient(clientCfg, serverList, httpAgent)
+	authProvider, err := security.NewAuthProvider(clientCfg, serverList, httpAgent)
+	if err != nil {
+		return nil, err
+	}
 
 	ns := XgrpcServer{
-		serverList:            serverList,
-		securityLogin:         securityLogin,
+		serverListStore:       NewServerListStore(serverList),
+		authProvider:          authProvider,
+		rawClientCfg:          clientCfg,
 		httpAgent:             httpAgent,
 		timeoutMs:             timeoutMs,
 		endpoint:              endpoint,
@@ -81,15 +89,20 @@ func NewXgrpcServer(serverList []constant.ServerConfig, clientCfg constant.Clien
 	if severLen > 0 {
 		ns.currentIndex = rand.Int31n(int32(severLen))
 	}
+	if _, err = ns.RefreshSecrets(); err != nil {
+		return nil, err
+	}
+	ns.perRPCCredentials = security.NewPerRPCCredentials(authProvider, func() string {
+		return ns.GetClientConfig().AccessKey
+	}, false)
 
 	ns.initRefreshSrvIfNeed()
-	_, err := securityLogin.Login()
-
-	if err != nil {
-		return &ns, err
+	// the auth server may be unavailable for a while, the failed login is retried with backoff by AutoRefresh.
+	if _, err = authProvider.Login(); err != nil {
+		logger.Errorf("login has error %+v", err)
 	}
 
-	securityLogin.AutoRefresh()
+	authProvider.AutoRefresh()
 	return &ns, nil
 }
 
@@ -128,7 +141,7 @@ func (server *XgrpcServer) callConfigServer(api string, params map[string]string
 
 	var response *http.Response
 	response, err = server.httpAgent.Request(method, url, headers, timeoutMS, params)
-	monitor.GetConfigRequestMonitor(method, url, util.GetStatusCode(response)).Observe(float64(time.Now().Nanosecond() - start.Nanosecond()))
+	monitor.GetConfigRequestMonitor(method, url, util.GetStatusCode(response)).Observe(time.Since(start).Seconds())
 	if err != nil {
 		return
 	}
@@ -142,6 +155,7 @@ func (server *XgrpcServer) callConfigServer(api string, params map[string]string
 	if response.StatusCode == constant.RESPONSE_CODE_SUCCESS {
 		return
 	} else {
+		server.refreshIfAuthFailure(response.StatusCode, params[constant.KEY_ACCESS_TOKEN])
 		err = xgrpc_error.NewXgrpcError(strconv.Itoa(response.StatusCode), string(bytes), nil)
 		return
 	}
@@ -182,18 +196,19 @@ func (server *XgrpcServer) callServer(api string, params map[string]string, meth
 		return
 	}
 	result = string(bytes)
-	monitor.GetNamingRequestMonitor(method, api, util.GetStatusCode(response)).Observe(float64(time.Now().Nanosecond() - start.Nanosecond()))
+	monitor.GetNamingRequestMonitor(method, api, util.GetStatusCode(response)).Observe(time.Since(start).Seconds())
 	if response.StatusCode == constant.RESPONSE_CODE_SUCCESS {
 		return
 	} else {
+		server.refreshIfAuthFailure(response.StatusCode, params[constant.KEY_ACCESS_TOKEN])
 		err = errors.New(fmt.Sprintf("request return error code %d", response.StatusCode))
 		return
 	}
 }
 
 func (server *XgrpcServer) ReqConfigApi(api string, params map[string]string, headers map[string]string, method string, timeoutMS uint64) (string, error) {
-	srvs := server.serverList
-	if srvs == nil || len(srvs) == 0 {
+	srvs := server.GetServerList()
+	if len(srvs) == 0 {
 		return "", errors.New("server list is empty")
 	}
 
@@ -226,8 +241,8 @@ func (server *XgrpcServer) ReqConfigApi(api string, params map[string]string, he
 }
 
 func (server *XgrpcServer) ReqApi(api string, params map[string]string, method string) (string, error) {
-	srvs := server.serverList
-	if srvs == nil || len(srvs) == 0 {
+	srvs := server.GetServerList()
+	if len(srvs) == 0 {
 		return "", errors.New("server list is empty")
 	}
 
@@ -259,6 +274,42 @@ func (server *XgrpcServer) ReqApi(api string, params map[string]string, method s
 	return "", errors.Wrapf(err, "retry %d times request failed!", constant.REQUEST_DOMAIN_RETRY_TIME)
 }
 
+// CallServerApi calls the api of the given server with the security info, the api is relative to the context path
+// of the server. The response with a status code other than 2xx fails with *http_agent.HttpError.
+func (server *XgrpcServer) CallServerApi(ctx context.Context, srv constant.ServerConfig, method string, api string, params map[string]string) ([]byte, error) {
+	start := time.Now()
+	contextPath := srv.ContextPath
+	if contextPath == "" {
+		contextPath = constant.WEB_CONTEXT
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	server.InjectSecurityInfo(params)
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	request := http_agent.NewFormRequest(method, getAddress(srv)+contextPath+api, params)
+	request.Header.Set("Client-Version", constant.CLIENT_VERSION)
+	request.Header.Set("User-Agent", constant.CLIENT_VERSION)
+	request.Header.Set("RequestId", uid.String())
+	resp, err := http_agent.AsHttpAgentV2(server.httpAgent).Do(ctx, request)
+	code := "NA"
+	if httpErr, ok := http_agent.IsHttpError(err); ok {
+		code = strconv.Itoa(httpErr.StatusCode)
+		server.refreshIfAuthFailure(httpErr.StatusCode, params[constant.KEY_ACCESS_TOKEN])
+	} else if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	monitor.GetHistogramWithLabels("admin", method, api, code).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
 func (server *XgrpcServer) initRefreshSrvIfNeed() {
 	if server.endpoint == "" {
 		return
@@ -274,14 +325,25 @@ func (server *XgrpcServer) initRefreshSrvIfNeed() {
 }
 
 func (server *XgrpcServer) refreshServerSrvIfNeed() {
-	if util.CurrentMillis()-server.lastSrvRefTime < server.vipSrvRefInterMills && len(server.serverList) > 0 {
+	if util.CurrentMillis()-server.lastSrvRefTime < server.vipSrvRefInterMills && len(server.GetServerList()) > 0 {
 		return
 	}
 
-	var list []string
 	urlString := "http://" + server.endpoint + "/xgrpc/serverlist"
-	result := server.httpAgent.RequestOnlyResult(http.MethodGet, urlString, nil, server.timeoutMs, nil)
-	list = strings.Split(result, "\n")
+	ctx := context.Background()
+	if server.timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(server.timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	resp, err := http_agent.AsHttpAgentV2(server.httpAgent).Do(ctx, http_agent.NewFormRequest(http.MethodGet, urlString, nil))
+	if err != nil {
+		// keep the current server list, an empty result must not be taken as an empty cluster
+		logger.Errorf("get xgrpc server list from endpoint:<%s> error:%+v", server.endpoint, err)
+		return
+	}
+	result := string(resp.Body)
+	list := strings.Split(result, "\n")
 	logger.Infof("http xgrpc server list: <%s>", result)
 
 	var servers []constant.ServerConfig
@@ -306,30 +368,113 @@ func (server *XgrpcServer) refreshServerSrvIfNeed() {
 		}
 	}
 	if len(servers) > 0 {
-		if !reflect.DeepEqual(server.serverList, servers) {
-			server.Lock()
-			logger.Infof("server list is updated, old: <%v>,new:<%v>", server.serverList, servers)
-			server.serverList = servers
-			server.ServerSrcChangeSignal <- struct{}{}
-			server.lastSrvRefTime = util.CurrentMillis()
-			server.Unlock()
-		}
+		server.lastSrvRefTime = util.CurrentMillis()
+		server.UpdateServerList(servers)
+	}
+}
 
+// UpdateServerList replaces the server list and notifies the listeners if any server is added or removed.
+func (server *XgrpcServer) UpdateServerList(servers []constant.ServerConfig) (ServerListChangeEvent, bool) {
+	event, changed := server.serverListStore.Update(servers)
+	if changed {
+		logger.Infof("server list is updated to version %d, added: <%v>, removed:<%v>", event.Version, event.Added, event.Removed)
+		select {
+		case server.ServerSrcChangeSignal <- struct{}{}:
+		default:
+		}
 	}
-	return
+	return event, changed
 }
 
+// GetServerList returns a copy of the current server list.
 func (server *XgrpcServer) GetServerList() []constant.ServerConfig {
-	return server.serverList
+	return server.serverListStore.Get()
+}
+
+// GetServerListWithVersion returns a copy of the current server list and its version.
+func (server *XgrpcServer) GetServerListWithVersion() ([]constant.ServerConfig, uint64) {
+	return server.serverListStore.GetWithVersion()
+}
+
+// OnServerListChanged adds the listener of the server list changes, the returned func removes it.
+func (server *XgrpcServer) OnServerListChanged(listener ServerListChangeListener) (unsubscribe func()) {
+	return server.serverListStore.Subscribe(listener)
 }
 
 func (server *XgrpcServer) InjectSecurityInfo(param map[string]string) {
-	accessToken := server.securityLogin.GetAccessToken()
-	if accessToken != "" {
-		param[constant.KEY_ACCESS_TOKEN] = accessToken
+	if server.authProvider == nil {
+		return
+	}
+	for k, v := range server.authProvider.GetSecurityInfo() {
+		param[k] = v
 	}
 }
 
+// RefreshSecrets resolve the secret references of AccessKey, SecretKey and Password again,
+// the rotated secrets are used by the following requests.
+func (server *XgrpcServer) RefreshSecrets() (constant.ClientConfig, error) {
+	clientCfg, err := secret.ResolveClientConfig(server.rawClientCfg)
+	if err != nil {
+		return clientCfg, err
+	}
+	server.clientCfg.Store(clientCfg)
+	return clientCfg, nil
+}
+
+// GetClientConfig returns the client config with the secrets resolved.
+func (server *XgrpcServer) GetClientConfig() constant.ClientConfig {
+	if clientCfg, ok := server.clientCfg.Load().(constant.ClientConfig); ok {
+		return clientCfg
+	}
+	return server.rawClientCfg
+}
+
+// IsAuthFailure returns whether the code means the security info is rejected by the server.
+func IsAuthFailure(code int) bool {
+	return code == constant.RESPONSE_CODE_UNAUTHORIZED || code == constant.RESPONSE_CODE_FORBIDDEN
+}
+
+// OnAuthFailure refresh the credential rejected by the server if the AuthProvider supports, the retried requests
+// are injected with the refreshed credential.
+func (server *XgrpcServer) OnAuthFailure(rejectedToken string) error {
+	if _, err := server.RefreshSecrets(); err != nil {
+		logger.Errorf("refresh secrets failed, error=%+v", err)
+	}
+	provider, ok := server.authProvider.(security.RefreshableAuthProvider)
+	if !ok {
+		return nil
+	}
+	return provider.Refresh(rejectedToken)
+}
+
+func (server *XgrpcServer) refreshIfAuthFailure(code int, rejectedToken string) {
+	if !IsAuthFailure(code) {
+		return
+	}
+	if err := server.OnAuthFailure(rejectedToken); err != nil {
+		logger.Errorf("refresh the rejected credential failed, error=%+v", err)
+	}
+}
+
+// GetPerRPCCredentials returns the credentials which attach the security info to the metadata of grpc calls.
+func (server *XgrpcServer) GetPerRPCCredentials() credentials.PerRPCCredentials {
+	return server.perRPCCredentials
+}
+
+// SignRequest sign the resource of the signable request with the SecretKey and put the signature into the headers.
+func (server *XgrpcServer) SignRequest(request rpc_request.IRequest, clientConfig constant.ClientConfig) {
+	signable, ok := request.(rpc_request.ISignableRequest)
+	if !ok || clientConfig.SecretKey == "" {
+		return
+	}
+	request.PutAllHeaders(GetSignHeadersFromSignableRequest(signable, clientConfig.SecretKey, clientConfig.SignatureMethod))
+}
+
+// GetAuthProvider returns the AuthProvider which provides the security info of the requests.
+func (server *XgrpcServer) GetAuthProvider() security.AuthProvider {
+	return server.authProvider
+}
+
 func (server *XgrpcServer) InjectSign(request rpc_request.IRequest, param map[string]string, clientConfig constant.ClientConfig) {
 	if clientConfig.AccessKey == "" || clientConfig.SecretKey == "" {
 		return
@@ -378,6 +523,24 @@ func GetSignHeadersFromRequest(cr rpc_request.IConfigRequest, secretKey string)
 	return headers
 }
 
+// GetSignHeadersFromSignableRequest sign the resource and the timestamp with the signature method,
+// HmacSHA256 is used if the method is empty.
+func GetSignHeadersFromSignableRequest(request rpc_request.ISignableRequest, secretKey string, signatureMethod string) map[string]string {
+	if signatureMethod == "" {
+		signatureMethod = constant.SIGN_METHOD_HMAC_SHA256
+	}
+	timeStamp := strconv.FormatInt(util.CurrentMillis(), 10)
+	signText := timeStamp
+	if resource := request.GetSignResource(); resource != "" {
+		signText = resource + "+" + timeStamp
+	}
+	return map[string]string{
+		"Timestamp":                 timeStamp,
+		"Spas-Signature":            sign(signText, secretKey, signatureMethod),
+		constant.SIGN_METHOD_HEADER: signatureMethod,
+	}
+}
+
 func GetSignHeaders(params map[string]string, secretKey string) map[string]string {
 	resource := ""
 
@@ -405,6 +568,20 @@ func GetSignHeaders(params map[string]string, secretKey string) map[string]strin
 	return headers
 }
 
+func sign(encryptText, encryptKey, signatureMethod string) string {
+	if signatureMethod == constant.SIGN_METHOD_HMAC_SHA1 {
+		return signWithhmacSHA1Encrypt(encryptText, encryptKey)
+	}
+	return signWithhmacSHA256Encrypt(encryptText, encryptKey)
+}
+
+func signWithhmacSHA256Encrypt(encryptText, encryptKey string) string {
+	mac := hmac.New(sha256.New, []byte(encryptKey))
+	mac.Write([]byte(encryptText))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func signWithhmacSHA1Encrypt(encryptText, encryptKey string) string {
 	//hmac ,use sha1
 	key := []byte(encryptKey)
@@ -415,12 +592,12 @@ func signWithhmacSHA1Encrypt(encryptText, encryptKey string) string {
 }
 
 func (server *XgrpcServer) GetNextServer() (constant.ServerConfig, error) {
-	serverLen := len(server.GetServerList())
-	if serverLen == 0 {
+	servers := server.GetServerList()
+	if len(servers) == 0 {
 		return constant.ServerConfig{}, errors.New("server is empty")
 	}
-	index := atomic.AddInt32(&server.currentIndex, 1) % int32(serverLen)
-	return server.GetServerList()[index], nil
+	index := atomic.AddInt32(&server.currentIndex, 1) % int32(len(servers))
+	return servers[index], nil
 }
 
 func (server *XgrpcServer) InjectSkAk(params map[string]string, clientConfig constant.ClientConfig) {
@@ -17,9 +17,24 @@
 package xgrpc_server
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
+	"github.com/allenliu88/xgrpc-client-go/common/http_agent"
+	"github.com/allenliu88/xgrpc-client-go/common/remote/rpc/rpc_request"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,3 +61,175 @@ func Test_getAddressWithoutScheme(t *testing.T) {
 	assert.Equal(t, "https://console.xgrpc.io:80", getAddress(serverConfigTest))
 
 }
+
+func TestInjectSecurityInfo(t *testing.T) {
+	server, err := NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{AuthCfg: constant.AuthConfig{AccessToken: "token"}}, nil, 3000, "")
+	assert.Nil(t, err)
+	params := map[string]string{}
+	server.InjectSecurityInfo(params)
+	assert.Equal(t, "token", params[constant.KEY_ACCESS_TOKEN])
+
+	server, err = NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{AuthCfg: constant.AuthConfig{Type: "unknown"}}, nil, 3000, "")
+	assert.NotNil(t, err)
+	assert.Nil(t, server)
+}
+
+// newLoginServer starts an auth server failing the first failures logins, the token of the nth login is token-n.
+func newLoginServer(loginTimes *int32, failures int32) (*httptest.Server, constant.ServerConfig) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times := atomic.AddInt32(loginTimes, 1)
+		if times <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"accessToken":"token-` + strconv.Itoa(int(times)) + `","tokenTtl":18000}`))
+	}))
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	return server, constant.ServerConfig{IpAddr: addr.IP.String(), Port: uint64(addr.Port)}
+}
+
+func accessToken(server *XgrpcServer) string {
+	params := map[string]string{}
+	server.InjectSecurityInfo(params)
+	return params[constant.KEY_ACCESS_TOKEN]
+}
+
+func TestNewXgrpcServerLoginFailed(t *testing.T) {
+	var loginTimes int32
+	loginServer, serverCfg := newLoginServer(&loginTimes, 1)
+	defer loginServer.Close()
+
+	// the unavailable auth server doesn't fail creating the server.
+	server, err := NewXgrpcServer([]constant.ServerConfig{serverCfg},
+		constant.ClientConfig{Username: "xgrpc", Password: "xgrpc", TimeoutMs: 3000}, &http_agent.HttpAgent{}, 3000, "")
+	assert.Nil(t, err)
+	assert.NotNil(t, server)
+	assert.Empty(t, accessToken(server))
+
+	assert.Eventually(t, func() bool { return accessToken(server) == "token-2" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNewXgrpcServerLoginBackoff(t *testing.T) {
+	var loginTimes int32
+	loginServer, serverCfg := newLoginServer(&loginTimes, 2)
+	defer loginServer.Close()
+
+	server, err := NewXgrpcServer([]constant.ServerConfig{serverCfg},
+		constant.ClientConfig{Username: "xgrpc", Password: "xgrpc", TimeoutMs: 3000}, &http_agent.HttpAgent{}, 3000, "")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loginTimes))
+
+	// the failed login is retried after 1s, and after 2s more when failing again.
+	assert.Never(t, func() bool { return atomic.LoadInt32(&loginTimes) > 1 }, 800*time.Millisecond, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&loginTimes) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool { return atomic.LoadInt32(&loginTimes) > 2 }, 1500*time.Millisecond, 10*time.Millisecond)
+	assert.Empty(t, accessToken(server))
+	assert.Eventually(t, func() bool { return accessToken(server) == "token-3" }, 2*time.Second, 10*time.Millisecond)
+}
+
+type signableRequest struct {
+	*rpc_request.Request
+}
+
+func (r *signableRequest) GetRequestType() string {
+	return "SignableRequest"
+}
+
+func (r *signableRequest) GetSignResource() string {
+	return "tenant+group"
+}
+
+func expectedSignature(text, key string, h func() hash.Hash) string {
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(text))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestGetSignHeadersFromSignableRequest(t *testing.T) {
+	request := &signableRequest{Request: &rpc_request.Request{Headers: map[string]string{}}}
+
+	headers := GetSignHeadersFromSignableRequest(request, "secret", "")
+	assert.Equal(t, constant.SIGN_METHOD_HMAC_SHA256, headers[constant.SIGN_METHOD_HEADER])
+	assert.Equal(t, expectedSignature("tenant+group+"+headers["Timestamp"], "secret", sha256.New), headers["Spas-Signature"])
+
+	headers = GetSignHeadersFromSignableRequest(request, "secret", constant.SIGN_METHOD_HMAC_SHA1)
+	assert.Equal(t, constant.SIGN_METHOD_HMAC_SHA1, headers[constant.SIGN_METHOD_HEADER])
+	assert.Equal(t, expectedSignature("tenant+group+"+headers["Timestamp"], "secret", sha1.New), headers["Spas-Signature"])
+}
+
+func TestSignRequest(t *testing.T) {
+	server := &XgrpcServer{}
+	request := &signableRequest{Request: &rpc_request.Request{Headers: map[string]string{}}}
+	server.SignRequest(request, constant.ClientConfig{})
+	assert.Empty(t, request.GetHeaders())
+
+	server.SignRequest(request, constant.ClientConfig{SecretKey: "secret"})
+	assert.NotEmpty(t, request.GetHeaders()["Spas-Signature"])
+
+	healthCheckRequest := rpc_request.NewHealthCheckRequest()
+	server.SignRequest(healthCheckRequest, constant.ClientConfig{SecretKey: "secret"})
+	assert.Empty(t, healthCheckRequest.GetHeaders()["Spas-Signature"])
+}
+
+func TestRefreshSecrets(t *testing.T) {
+	assert.Nil(t, os.Setenv("XGRPC_TEST_SECRET_KEY", "sk1"))
+	defer os.Unsetenv("XGRPC_TEST_SECRET_KEY")
+	server, err := NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{SecretKey: "${env:XGRPC_TEST_SECRET_KEY}"}, nil, 3000, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "sk1", server.GetClientConfig().SecretKey)
+
+	assert.Nil(t, os.Setenv("XGRPC_TEST_SECRET_KEY", "sk2"))
+	_, err = server.RefreshSecrets()
+	assert.Nil(t, err)
+	assert.Equal(t, "sk2", server.GetClientConfig().SecretKey)
+
+	_, err = NewXgrpcServer([]constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}},
+		constant.ClientConfig{SecretKey: "${env:XGRPC_TEST_SECRET_KEY_NOT_EXIST}"}, nil, 3000, "")
+	assert.NotNil(t, err)
+}
+
+func TestRefreshServerSrvKeepListOnError(t *testing.T) {
+	var fail int32 = 1
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("127.0.0.2:9848\n"))
+	}))
+	defer endpoint.Close()
+
+	oldList := []constant.ServerConfig{{IpAddr: "127.0.0.1", Port: 8848}}
+	server := &XgrpcServer{
+		serverListStore:       NewServerListStore(oldList),
+		httpAgent:             &http_agent.HttpAgent{},
+		timeoutMs:             3000,
+		endpoint:              strings.TrimPrefix(endpoint.URL, "http://"),
+		ServerSrcChangeSignal: make(chan struct{}, 1),
+	}
+	server.refreshServerSrvIfNeed()
+	assert.Equal(t, oldList, server.GetServerList())
+
+	var events []ServerListChangeEvent
+	server.OnServerListChanged(func(event ServerListChangeEvent) {
+		events = append(events, event)
+	})
+	atomic.StoreInt32(&fail, 0)
+	server.refreshServerSrvIfNeed()
+	assert.Equal(t, "127.0.0.2", server.GetServerList()[0].IpAddr)
+	assert.Equal(t, uint64(9848), server.GetServerList()[0].Port)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, oldList, events[0].Removed)
+	assert.Equal(t, server.GetServerList(), events[0].Added)
+
+	// the refresher isn't blocked by the signal which isn't received
+	server.lastSrvRefTime = 0
+	server.UpdateServerList(oldList)
+	server.refreshServerSrvIfNeed()
+	assert.Equal(t, 3, len(events))
+	_, version := server.GetServerListWithVersion()
+	assert.Equal(t, uint64(3), version)
+}
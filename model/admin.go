@@ -0,0 +1,42 @@
+/*
+ * Copyright 1999-2020 Xgrpc Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+type ConnectionInfo struct {
+	ConnectionId   string            `json:"connectionId"`
+	ClientIp       string            `json:"clientIp"`
+	LocalPort      int               `json:"localPort"`
+	Version        string            `json:"version"`
+	AppName        string            `json:"appName"`
+	Tenant         string            `json:"tenant"`
+	Labels         map[string]string `json:"labels"`
+	CreateTime     int64             `json:"createTime"`
+	LastActiveTime int64             `json:"lastActiveTime"`
+}
+
+type ClusterNode struct {
+	Ip         string                 `json:"ip"`
+	Port       int                    `json:"port"`
+	State      string                 `json:"state"`
+	Address    string                 `json:"address"`
+	ExtendInfo map[string]interface{} `json:"extendInfo"`
+}
+
+type HealthStatus struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message"`
+}
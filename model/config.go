@@ -17,19 +17,19 @@
 package model
 
 type ConfigItem struct {
-	Id      string `param:"id"`
-	DataId  string `param:"dataId"`
-	Group   string `param:"group"`
-	Content string `param:"content"`
-	Md5     string `param:"md5"`
-	Tenant  string `param:"tenant"`
-	Appname string `param:"appname"`
+	Id      string `param:"id" json:"id"`
+	DataId  string `param:"dataId" json:"dataId"`
+	Group   string `param:"group" json:"group"`
+	Content string `param:"content" json:"content"`
+	Md5     string `param:"md5" json:"md5"`
+	Tenant  string `param:"tenant" json:"tenant"`
+	Appname string `param:"appname" json:"appname"`
 }
 type ConfigPage struct {
-	TotalCount     int          `param:"totalCount"`
-	PageNumber     int          `param:"pageNumber"`
-	PagesAvailable int          `param:"pagesAvailable"`
-	PageItems      []ConfigItem `param:"pageItems"`
+	TotalCount     int          `param:"totalCount" json:"totalCount"`
+	PageNumber     int          `param:"pageNumber" json:"pageNumber"`
+	PagesAvailable int          `param:"pagesAvailable" json:"pagesAvailable"`
+	PageItems      []ConfigItem `param:"pageItems" json:"pageItems"`
 }
 
 type ConfigListenContext struct {
@@ -52,6 +52,18 @@ type Service struct {
 	ReachProtectionThreshold bool       `json:"reachProtectionThreshold"`
 }
 
+// InstanceChangeEvent is the change of the subscribed service, the instances are identified by
+// the InstanceId, or by the ip, port and cluster if the id is empty.
+type InstanceChangeEvent struct {
+	ServiceName string     `json:"serviceName"`
+	GroupName   string     `json:"groupName"`
+	Clusters    string     `json:"clusters"`
+	Hosts       []Instance `json:"hosts"`
+	Added       []Instance `json:"added"`
+	Removed     []Instance `json:"removed"`
+	Modified    []Instance `json:"modified"`
+}
+
 type ServiceDetail struct {
 	Service  ServiceInfo `json:"service"`
 	Clusters []Cluster   `json:"clusters"`
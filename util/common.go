@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/allenliu88/xgrpc-client-go/common/constant"
@@ -55,6 +56,14 @@ func GetGroupName(serviceName string, groupName string) string {
 	return groupName + constant.SERVICE_INFO_SPLITER + serviceName
 }
 
+// ParseGroupedName splits the name of GetGroupName, the group is DEFAULT_GROUP if the name has no group.
+func ParseGroupedName(groupedName string) (serviceName string, groupName string) {
+	if i := strings.Index(groupedName, constant.SERVICE_INFO_SPLITER); i >= 0 {
+		return groupedName[i+len(constant.SERVICE_INFO_SPLITER):], groupedName[:i]
+	}
+	return groupedName, constant.DEFAULT_GROUP
+}
+
 func GetServiceCacheKey(serviceName string, clusters string) string {
 	if clusters == "" {
 		return serviceName
@@ -21,3 +21,9 @@ type XgrpcClientParam struct {
 	ClientConfig  *constant.ClientConfig  // optional
 	ServerConfigs []constant.ServerConfig // optional
 }
+
+type ClusterParam struct {
+	Name          string                  // required
+	ClientConfig  *constant.ClientConfig  // optional
+	ServerConfigs []constant.ServerConfig // optional
+}
@@ -67,10 +67,11 @@ type GetAllServiceInfoParam struct {
 }
 
 type SubscribeParam struct {
-	ServiceName       string                                     `param:"serviceName"` //required
-	Clusters          []string                                   `param:"clusters"`    //optional,default:DEFAULT
-	GroupName         string                                     `param:"groupName"`   //optional,default:DEFAULT_GROUP
-	SubscribeCallback func(services []model.Instance, err error) //required
+	ServiceName            string                                     `param:"serviceName"` //required
+	Clusters               []string                                   `param:"clusters"`    //optional,default:DEFAULT
+	GroupName              string                                     `param:"groupName"`   //optional,default:DEFAULT_GROUP
+	SubscribeCallback      func(services []model.Instance, err error) //required if InstanceChangeCallback is nil
+	InstanceChangeCallback func(event model.InstanceChangeEvent)      //optional,called with the added, removed and modified instances
 }
 
 type SelectAllInstancesParam struct {